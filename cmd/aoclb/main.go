@@ -1,12 +1,19 @@
 package main
 
 import (
+	"errors"
 	"log"
 	"os"
+	"slices"
 	"strconv"
+	"strings"
 	"time"
 
+	"uocsclub.net/aoclb/internal/auth"
+	"uocsclub.net/aoclb/internal/events"
 	"uocsclub.net/aoclb/internal/fetcher"
+	"uocsclub.net/aoclb/internal/notify"
+	"uocsclub.net/aoclb/internal/types"
 	"uocsclub.net/aoclb/internal/web"
 
 	"github.com/go-co-op/gocron/v2"
@@ -31,29 +38,107 @@ func main() {
 		return
 	}
 
-	j, err := s.NewJob(
-		gocron.DurationJob(time.Minute/2),
-		gocron.NewTask(func(db *database.DatabaseInst) {
-			// return // disable fetching for now
+	years := trackedYears(os.Getenv("YEAR"), os.Getenv("YEARS"))
+	privateLeaderboards := configuredLeaderboards(years, os.Getenv("LEADERBOARDS"), os.Getenv("LEADERBOARD_ID"))
 
-			fetcherConfig := fetcher.AOCFetcherConfig{
-				SessionCookie: os.Getenv("SESSION_ID"),
-				LeaderboardId: os.Getenv("LEADERBOARD_ID"),
-				Year:          os.Getenv("YEAR"),
-			}
+	for _, privateLeaderboard := range privateLeaderboards {
+		err = db.StorePrivateLeaderboard(privateLeaderboard)
+		if err != nil {
+			log.Println(err)
+			return
+		}
+	}
 
-			data, err := fetcher.FetchAOCLeaderboard(&fetcherConfig)
+	// the entries stored before LEADERBOARDS existed came from the LEADERBOARD_ID board
+	legacyLeaderboardId := os.Getenv("LEADERBOARD_ID")
+	if len(legacyLeaderboardId) == 0 && len(privateLeaderboards) != 0 {
+		legacyLeaderboardId = privateLeaderboards[0].Id
+	}
+	if len(legacyLeaderboardId) != 0 {
+		err = db.AdoptLegacyEntries(legacyLeaderboardId)
+		if err != nil {
+			log.Println(err)
+			return
+		}
+	}
 
-			if err != nil {
-				log.Println(err)
-			}
+	admins := adminIdentities(os.Getenv("ADMIN_GITHUB_IDS"), os.Getenv("ADMIN_IDENTITIES"))
+	err = db.BootstrapAdmins(admins)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	for _, year := range years {
+		err = db.EnsureModifierSet(year)
+		if err != nil {
+			log.Println(err)
+			return
+		}
+	}
 
-			_, err = db.StoreLeaderboard(data)
-			if err != nil {
-				log.Println(err)
+	for year, mode := range scoringModes(os.Getenv("SCORING_MODES")) {
+		err = db.SetScoringMode(year, mode)
+		if errors.Is(err, database.ErrUnknownYear) {
+			log.Printf("WARN: Ignoring the scoring mode of %s, it isn't a tracked year\n", year)
+			continue
+		}
+		if err != nil {
+			log.Println(err)
+			return
+		}
+	}
+
+	// the fetch job publishes the changes it stores, the server streams them to open pages
+	hub := events.NewHub()
+
+	// GITHUB_OAUTH_REDIRECT_URI predates the other providers
+	baseUrl := os.Getenv("BASE_URL")
+	if len(baseUrl) == 0 {
+		baseUrl = os.Getenv("GITHUB_OAUTH_REDIRECT_URI")
+	}
+
+	// the ranks are seeded before the first fetch so it can be compared to them
+	tracker := notify.NewRankTracker(db, hub, privateLeaderboards)
+	err = tracker.Seed()
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	tracker.Start()
+
+	if discord, ok := discordConfig(baseUrl); ok {
+		go notify.NewDiscord(discord, hub).Run()
+	}
+
+	// the webhooks are managed by the admins, the worker runs even when there are none yet
+	notify.NewWebhooks(db, hub).Start()
+
+	minInterval := durationEnv("AOC_FETCH_INTERVAL", fetcher.DefaultMinInterval)
+	maxBackoff := durationEnv("AOC_MAX_BACKOFF", fetcher.DefaultMaxBackoff)
+
+	// the job only checks if a fetch is due, the fetch interval is enforced by fetcher.NextFetch
+	j, err := s.NewJob(
+		gocron.DurationJob(time.Minute),
+		gocron.NewTask(func(db *database.DatabaseInst, hub *events.Hub) {
+			// return // disable fetching for now
+
+			for _, privateLeaderboard := range privateLeaderboards {
+				fetcherConfig := fetcher.AOCFetcherConfig{
+					BaseUrl:       os.Getenv("AOC_BASE_URL"),
+					SessionCookie: os.Getenv(privateLeaderboard.SessionEnv),
+					LeaderboardId: privateLeaderboard.Id,
+					Year:          privateLeaderboard.Year,
+					UserAgent:     os.Getenv("AOC_USER_AGENT"),
+					MinInterval:   minInterval,
+					MaxBackoff:    maxBackoff,
+				}
+
+				fetchPrivateLeaderboard(db, hub, &fetcherConfig, privateLeaderboard)
 			}
 		},
 			db,
+			hub,
 		),
 	)
 
@@ -71,12 +156,13 @@ func main() {
 	}
 
 	web.InitServer(web.ServerConfig{
-		Port:                    iport,
-		Year: os.Getenv("YEAR"),
-		OAuth2GithubClientId:    os.Getenv("GITHUB_OAUTH_ID"),
-		OAuth2GithubRedirectURI: os.Getenv("GITHUB_OAUTH_REDIRECT_URI"),
-		OAuth2GithubSecret:      os.Getenv("GITHUB_OAUTH_SECRET"),
-	}, db)
+		Port:            iport,
+		Year:            os.Getenv("YEAR"),
+		BaseURL:         baseUrl,
+		Providers:       loginProviders(),
+		AdminIdentities: admins,
+		CorsOrigins:     os.Getenv("CORS_ORIGINS"),
+	}, db, hub)
 
 	log.Println("Started!")
 
@@ -84,3 +170,309 @@ func main() {
 	}
 
 }
+
+// fetchPrivateLeaderboard fetches the private leaderboard when a fetch is due and stores it. The ETag and
+// Last-Modified of the response are only kept once everything is stored, AOC would answer the next fetches
+// with a 304 otherwise and the leaderboard wouldn't be stored until it changes
+func fetchPrivateLeaderboard(db *database.DatabaseInst, hub *events.Hub, config *fetcher.AOCFetcherConfig, privateLeaderboard *types.AOCPrivateLeaderboard) {
+	status, err := db.GetFetchStatus(privateLeaderboard.Id, privateLeaderboard.Year)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	if time.Now().Before(fetcher.NextFetch(config, status)) {
+		return
+	}
+
+	defer func() {
+		err := db.StoreFetchStatus(status)
+		if err != nil {
+			log.Println(err)
+		}
+	}()
+
+	leaderboard, err := fetcher.FetchAOCLeaderboard(config, status)
+	if errors.Is(err, fetcher.ErrNotModified) {
+		return
+	}
+	if err != nil {
+		log.Printf("ERROR: Failed to fetch %s (%s): %s\n", privateLeaderboard.Name, privateLeaderboard.Year, err)
+		return
+	}
+
+	err = storeLeaderboard(db, hub, privateLeaderboard, status, leaderboard)
+	if err != nil {
+		log.Println(err)
+		status.ETag = ""
+		status.LastModified = ""
+	}
+}
+
+// storeLeaderboard archives the fetched leaderboard and stores everything derived from it, the changes are
+// published to the hub
+func storeLeaderboard(db *database.DatabaseInst, hub *events.Hub, privateLeaderboard *types.AOCPrivateLeaderboard, status *types.AOCFetchStatus, leaderboard *fetcher.AOCResponseLeaderboard) error {
+	_, err := db.StoreSnapshot(privateLeaderboard, status.LastSuccess, leaderboard.Raw)
+	if err != nil {
+		return err
+	}
+
+	event := leaderboard.ToAOCEvent()
+	err = db.StoreEvent(event)
+	if err != nil {
+		return err
+	}
+
+	// past seasons keep the modifiers they were scored with
+	if event.Ended(time.Now()) {
+		err = db.LockModifierSet(event.Year)
+		if err != nil {
+			log.Println(err)
+		}
+	}
+
+	data := leaderboard.ToAOCData()
+	verifyLocalScores(privateLeaderboard, data, leaderboard.NumDays)
+
+	changes, err := db.StoreLeaderboard(privateLeaderboard, data, status.LastSuccess)
+	if err != nil {
+		return err
+	}
+	if changes.Changed() {
+		hub.Publish(events.Event{
+			Type:          events.LeaderboardUpdated,
+			Year:          privateLeaderboard.Year,
+			LeaderboardId: privateLeaderboard.Id,
+			Data:          changes,
+		})
+	}
+
+	linked, err := db.ConfirmLinkRequests(data)
+	if err != nil {
+		log.Println(err)
+	}
+	for _, user := range linked {
+		log.Printf("Linked AoC user %d to %s\n", user.UserId, user.Identity)
+	}
+
+	return nil
+}
+
+// trackedYears combines the current year with the comma separated list of past years to keep fetching
+func trackedYears(year string, years string) []string {
+	tracked := []string{year}
+
+	for y := range strings.SplitSeq(years, ",") {
+		y = strings.TrimSpace(y)
+		if len(y) == 0 || slices.Contains(tracked, y) {
+			continue
+		}
+		tracked = append(tracked, y)
+	}
+
+	return tracked
+}
+
+// configuredLeaderboards parses the comma separated list of private leaderboards, each one
+// formatted as <id>:<display name>:<session cookie env variable>:<years>, the session defaults to SESSION_ID
+// and the years, separated by |, to every tracked year. Without any, the single LEADERBOARD_ID board is used
+func configuredLeaderboards(years []string, leaderboards string, leaderboardId string) []*types.AOCPrivateLeaderboard {
+	if len(strings.TrimSpace(leaderboards)) == 0 {
+		leaderboards = leaderboardId + ":Leaderboard"
+	}
+
+	configured := []*types.AOCPrivateLeaderboard{}
+
+	for leaderboard := range strings.SplitSeq(leaderboards, ",") {
+		fields := strings.Split(strings.TrimSpace(leaderboard), ":")
+		if len(fields[0]) == 0 {
+			continue
+		}
+
+		name := fields[0]
+		if len(fields) > 1 && len(fields[1]) != 0 {
+			name = fields[1]
+		}
+		sessionEnv := "SESSION_ID"
+		if len(fields) > 2 && len(fields[2]) != 0 {
+			sessionEnv = fields[2]
+		}
+
+		boardYears := years
+		if len(fields) > 3 && len(fields[3]) != 0 {
+			boardYears = []string{}
+			for year := range strings.SplitSeq(fields[3], "|") {
+				if slices.Contains(years, strings.TrimSpace(year)) {
+					boardYears = append(boardYears, strings.TrimSpace(year))
+				}
+			}
+		}
+
+		for _, year := range boardYears {
+			configured = append(configured, &types.AOCPrivateLeaderboard{
+				Id:         fields[0],
+				Year:       year,
+				Name:       name,
+				SessionEnv: sessionEnv,
+			})
+		}
+	}
+
+	return configured
+}
+
+// durationEnv parses a duration env variable like "15m", falling back to the default when it's unset or invalid
+func durationEnv(name string, fallback time.Duration) time.Duration {
+	value := os.Getenv(name)
+	if len(value) == 0 {
+		return fallback
+	}
+
+	duration, err := time.ParseDuration(value)
+	if err != nil || duration <= 0 {
+		log.Printf("WARN: Invalid %s env variable, using %s\n", name, fallback)
+		return fallback
+	}
+
+	return duration
+}
+
+// adminIdentities parses the comma separated list of github user ids and the comma separated list
+// of identities of any provider, formatted as <provider>:<subject>
+func adminIdentities(githubIds string, identities string) []types.AOCIdentity {
+	parsed := []types.AOCIdentity{}
+
+	for id := range strings.SplitSeq(githubIds, ",") {
+		id = strings.TrimSpace(id)
+		if len(id) == 0 {
+			continue
+		}
+
+		_, err := strconv.Atoi(id)
+		if err != nil {
+			log.Printf("WARN: Invalid github id %q\n", id)
+			continue
+		}
+		parsed = append(parsed, types.AOCIdentity{Provider: auth.GithubProviderName, Subject: id})
+	}
+
+	for identity := range strings.SplitSeq(identities, ",") {
+		identity = strings.TrimSpace(identity)
+		if len(identity) == 0 {
+			continue
+		}
+
+		provider, subject, ok := strings.Cut(identity, ":")
+		if !ok || len(provider) == 0 || len(subject) == 0 {
+			log.Printf("WARN: Invalid admin identity %q\n", identity)
+			continue
+		}
+		parsed = append(parsed, types.AOCIdentity{Provider: provider, Subject: subject})
+	}
+
+	return parsed
+}
+
+// loginProviders returns the configured identity providers, Github and/or an OpenID Connect one
+func loginProviders() []auth.Provider {
+	providers := []auth.Provider{}
+
+	if len(os.Getenv("GITHUB_OAUTH_ID")) != 0 {
+		providers = append(providers, auth.NewGithubProvider(os.Getenv("GITHUB_OAUTH_ID"), os.Getenv("GITHUB_OAUTH_SECRET")))
+	}
+
+	if len(os.Getenv("OIDC_ISSUER")) != 0 {
+		name := os.Getenv("OIDC_NAME")
+		if len(name) == 0 {
+			name = "oidc"
+		}
+		if name == auth.GithubProviderName {
+			log.Println("WARN: OIDC_NAME can't be github, the OpenID Connect provider is disabled")
+			return providers
+		}
+
+		providers = append(providers, auth.NewOIDCProvider(auth.OIDCConfig{
+			Name:         name,
+			DisplayName:  os.Getenv("OIDC_DISPLAY_NAME"),
+			Issuer:       os.Getenv("OIDC_ISSUER"),
+			ClientId:     os.Getenv("OIDC_CLIENT_ID"),
+			ClientSecret: os.Getenv("OIDC_CLIENT_SECRET"),
+			Scopes:       strings.Fields(os.Getenv("OIDC_SCOPES")),
+		}))
+	}
+
+	if len(providers) == 0 {
+		log.Println("WARN: No login provider configured, set GITHUB_OAUTH_ID or OIDC_ISSUER")
+	}
+
+	return providers
+}
+
+// discordConfig returns the configuration of the Discord notifications, ok is false without any webhook.
+// DISCORD_TEST_SINK sends them to a local sink instead of the webhooks
+func discordConfig(baseUrl string) (notify.DiscordConfig, bool) {
+	config := notify.DiscordConfig{
+		WebhookUrls: []string{},
+		Events:      []events.EventType{},
+		BaseURL:     baseUrl,
+	}
+
+	for url := range strings.SplitSeq(os.Getenv("DISCORD_WEBHOOK_URLS"), ",") {
+		url = strings.TrimSpace(url)
+		if len(url) != 0 {
+			config.WebhookUrls = append(config.WebhookUrls, url)
+		}
+	}
+	if sink := os.Getenv("DISCORD_TEST_SINK"); len(sink) != 0 {
+		log.Printf("Discord notifications go to the test sink %s\n", sink)
+		config.WebhookUrls = []string{sink}
+	}
+
+	for event := range strings.SplitSeq(os.Getenv("DISCORD_EVENTS"), ",") {
+		eventType := events.EventType(strings.TrimSpace(event))
+		if len(eventType) == 0 {
+			continue
+		}
+		if !slices.Contains(notify.DiscordEvents, eventType) {
+			log.Printf("WARN: Unknown Discord event %q\n", eventType)
+			continue
+		}
+		config.Events = append(config.Events, eventType)
+	}
+	if len(strings.TrimSpace(os.Getenv("DISCORD_EVENTS"))) == 0 {
+		config.Events = notify.DiscordEvents
+	}
+
+	return config, len(config.WebhookUrls) != 0 && len(config.Events) != 0
+}
+
+// verifyLocalScores warns when our implementation of the AOC scoring disagrees with AOC, the merged
+// view and the history depend on it
+func verifyLocalScores(leaderboard *types.AOCPrivateLeaderboard, data types.AOCData, numDays int) {
+	for id, score := range types.ComputeLocalScores(data, numDays, nil) {
+		if data[id].Score != score {
+			log.Printf("WARN: Computed local score of %d for user %d in %s (%s), AOC says %d\n", score, id, leaderboard.Name, leaderboard.Year, data[id].Score)
+		}
+	}
+}
+
+// scoringModes parses the comma separated list of <year>:<scoring mode>
+func scoringModes(modes string) map[string]types.AOCScoringMode {
+	parsed := map[string]types.AOCScoringMode{}
+
+	for entry := range strings.SplitSeq(modes, ",") {
+		year, mode, found := strings.Cut(strings.TrimSpace(entry), ":")
+		if !found {
+			continue
+		}
+
+		scoringMode, ok := types.ParseScoringMode(mode)
+		if !ok {
+			log.Printf("WARN: Invalid scoring mode %q for %s\n", mode, year)
+			continue
+		}
+		parsed[year] = scoringMode
+	}
+
+	return parsed
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"uocsclub.net/aoclb/internal/aocstub"
+	"uocsclub.net/aoclb/internal/database"
+	"uocsclub.net/aoclb/internal/events"
+	"uocsclub.net/aoclb/internal/fetcher"
+	"uocsclub.net/aoclb/internal/types"
+)
+
+func TestConfiguredLeaderboards(t *testing.T) {
+	years := []string{"2025", "2024", "2023"}
+
+	type board struct{ id, year, name, sessionEnv string }
+
+	tests := []struct {
+		name         string
+		leaderboards string
+		want         []board
+	}{
+		{
+			name: "defaults to LEADERBOARD_ID",
+			want: []board{
+				{"42", "2025", "Leaderboard", "SESSION_ID"},
+				{"42", "2024", "Leaderboard", "SESSION_ID"},
+				{"42", "2023", "Leaderboard", "SESSION_ID"},
+			},
+		},
+		{
+			name:         "every tracked year by default",
+			leaderboards: "1:Undergrad,2:Alumni:ALUMNI_SESSION_ID",
+			want: []board{
+				{"1", "2025", "Undergrad", "SESSION_ID"},
+				{"1", "2024", "Undergrad", "SESSION_ID"},
+				{"1", "2023", "Undergrad", "SESSION_ID"},
+				{"2", "2025", "Alumni", "ALUMNI_SESSION_ID"},
+				{"2", "2024", "Alumni", "ALUMNI_SESSION_ID"},
+				{"2", "2023", "Alumni", "ALUMNI_SESSION_ID"},
+			},
+		},
+		{
+			name:         "only the listed years that are tracked",
+			leaderboards: "1:Undergrad,2:Alumni::2024|2025|2019",
+			want: []board{
+				{"1", "2025", "Undergrad", "SESSION_ID"},
+				{"1", "2024", "Undergrad", "SESSION_ID"},
+				{"1", "2023", "Undergrad", "SESSION_ID"},
+				{"2", "2024", "Alumni", "SESSION_ID"},
+				{"2", "2025", "Alumni", "SESSION_ID"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		got := []board{}
+		for _, leaderboard := range configuredLeaderboards(years, tt.leaderboards, "42") {
+			got = append(got, board{leaderboard.Id, leaderboard.Year, leaderboard.Name, leaderboard.SessionEnv})
+		}
+
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestFetchPrivateLeaderboardKeepsValidatorsUntilStored(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "data.sqlite3")
+	db, err := database.InitDatabase(dbPath, "../../migrations")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	privateLeaderboard := &types.AOCPrivateLeaderboard{Id: "123456", Year: "2024", Name: "Club", SessionEnv: "SESSION_ID"}
+	err = db.StorePrivateLeaderboard(privateLeaderboard)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stub := aocstub.NewHandler(aocstub.Generate(1, privateLeaderboard.Year, privateLeaderboard.Id, 5, 25))
+	notModified := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			notModified++
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		stub.ServeHTTP(w, r)
+	}))
+	defer server.Close()
+
+	config := &fetcher.AOCFetcherConfig{
+		BaseUrl:       server.URL,
+		LeaderboardId: privateLeaderboard.Id,
+		Year:          privateLeaderboard.Year,
+		MinInterval:   time.Nanosecond,
+	}
+
+	// a second connection makes the store fail without going through the app
+	conn, err := sql.Open("sqlite3", dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	_, err = conn.Exec("CREATE TRIGGER fail_store BEFORE INSERT ON leaderboard_entry BEGIN SELECT RAISE(ABORT, 'store failed'); END;")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fetchPrivateLeaderboard(db, events.NewHub(), config, privateLeaderboard)
+
+	status, err := db.GetFetchStatus(privateLeaderboard.Id, privateLeaderboard.Year)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(status.ETag) != 0 || len(status.LastModified) != 0 {
+		t.Fatalf("kept the validators of a leaderboard that wasn't stored: %+v", status)
+	}
+
+	_, err = conn.Exec("DROP TRIGGER fail_store;")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fetchPrivateLeaderboard(db, events.NewHub(), config, privateLeaderboard)
+
+	if notModified != 0 {
+		t.Fatalf("got %d not modified responses before the leaderboard was stored", notModified)
+	}
+	data, err := db.GetLeaderboard(privateLeaderboard.Year, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data) != 5 {
+		t.Fatalf("stored %d members, want 5", len(data))
+	}
+
+	status, err = db.GetFetchStatus(privateLeaderboard.Id, privateLeaderboard.Year)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.ETag != `"v1"` {
+		t.Fatalf("got etag %q once stored", status.ETag)
+	}
+
+	fetchPrivateLeaderboard(db, events.NewHub(), config, privateLeaderboard)
+
+	if notModified != 1 {
+		t.Fatalf("got %d not modified responses once stored, want 1", notModified)
+	}
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"flag"
+	"log"
+
+	"uocsclub.net/aoclb/internal/database"
+	"uocsclub.net/aoclb/internal/fetcher"
+	"uocsclub.net/aoclb/internal/types"
+)
+
+// Rebuilds the leaderboard tables from the archived AOC snapshots, run it with the server stopped
+// after a migration or a scoring change
+func main() {
+	dbPath := flag.String("db", "./data.sqlite3", "Path to the sqlite database")
+	migrationDir := flag.String("migrations", "./migrations", "Path to the migrations")
+	flag.Parse()
+
+	db, err := database.InitDatabase(*dbPath, *migrationDir)
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	// nothing is changed if the replay fails halfway
+	replayed, total, err := db.ReplaySnapshots(parseSnapshot)
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	log.Printf("Replayed %d/%d snapshots\n", replayed, total)
+}
+
+func parseSnapshot(payload []byte) (*types.AOCEvent, types.AOCData, error) {
+	leaderboard, err := fetcher.ParseAOCLeaderboard(payload)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return leaderboard.ToAOCEvent(), leaderboard.ToAOCData(), nil
+}
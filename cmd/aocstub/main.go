@@ -0,0 +1,51 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+
+	"uocsclub.net/aoclb/internal/aocstub"
+)
+
+// Serves a fake AOC private leaderboard so the app can run without a real session cookie,
+// point AOC_BASE_URL at it (http://localhost:7072 by default)
+func main() {
+	port := flag.Int("port", 7072, "Port to serve the stub on")
+	configPath := flag.String("config", "", "JSON file describing the leaderboard, overrides the generation flags")
+	year := flag.String("year", "2025", "Event year")
+	leaderboardId := flag.String("leaderboard", "123456", "Private leaderboard id")
+	members := flag.Int("members", 10, "Number of generated members")
+	days := flag.Int("days", 12, "Number of days in the event")
+	seed := flag.Uint64("seed", 1, "Seed for the generated members and stars")
+	session := flag.String("session", "", "Require this session cookie, empty accepts any")
+	flag.Parse()
+
+	config := aocstub.Generate(*seed, *year, *leaderboardId, *members, *days)
+
+	if len(*configPath) != 0 {
+		file, err := os.ReadFile(*configPath)
+		if err != nil {
+			log.Fatalln(err)
+		}
+
+		config = &aocstub.Config{}
+		err = json.Unmarshal(file, config)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		if config.Day1Timestamp == 0 {
+			config.Day1Timestamp = aocstub.DefaultDay1Timestamp(config.Year)
+		}
+	}
+
+	if len(*session) != 0 {
+		config.SessionCookie = *session
+	}
+
+	log.Printf("Serving leaderboard %s for %s on :%d\n", config.LeaderboardId, config.Year, *port)
+	log.Fatalln(http.ListenAndServe(fmt.Sprintf(":%d", *port), aocstub.NewHandler(config)))
+}
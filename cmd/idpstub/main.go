@@ -0,0 +1,46 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+
+	"uocsclub.net/aoclb/internal/idpstub"
+)
+
+// Serves a fake OpenID Connect provider so the OIDC login can be tried without a real one,
+// point OIDC_ISSUER at it (http://localhost:7073 by default)
+func main() {
+	port := flag.Int("port", 7073, "Port to serve the stub on")
+	configPath := flag.String("config", "", "JSON file describing the provider, overrides the generation flags")
+	issuer := flag.String("issuer", "", "Issuer url, defaults to http://localhost:<port>")
+	clientId := flag.String("client-id", "aoclb", "Client id the app logs in with")
+	clientSecret := flag.String("client-secret", "secret", "Client secret the app logs in with")
+	users := flag.Int("users", 5, "Number of generated users")
+	flag.Parse()
+
+	if len(*issuer) == 0 {
+		*issuer = fmt.Sprintf("http://localhost:%d", *port)
+	}
+
+	config := idpstub.Generate(*issuer, *clientId, *clientSecret, *users)
+
+	if len(*configPath) != 0 {
+		file, err := os.ReadFile(*configPath)
+		if err != nil {
+			log.Fatalln(err)
+		}
+
+		config = &idpstub.Config{}
+		err = json.Unmarshal(file, config)
+		if err != nil {
+			log.Fatalln(err)
+		}
+	}
+
+	log.Printf("Serving OpenID Connect provider %s on :%d\n", config.Issuer, *port)
+	log.Fatalln(http.ListenAndServe(fmt.Sprintf(":%d", *port), idpstub.NewHandler(config)))
+}
@@ -0,0 +1,21 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+
+	"uocsclub.net/aoclb/internal/webhooksink"
+)
+
+// Logs the webhooks posted to it so the notifications can be tried without posting anywhere,
+// point DISCORD_TEST_SINK at it (http://localhost:7074 by default)
+func main() {
+	port := flag.Int("port", 7074, "Port to serve the sink on")
+	status := flag.Int("status", http.StatusNoContent, "Status to answer the webhooks with")
+	flag.Parse()
+
+	log.Printf("Logging webhooks posted to :%d\n", *port)
+	log.Fatalln(http.ListenAndServe(fmt.Sprintf(":%d", *port), webhooksink.NewHandler(*status)))
+}
@@ -0,0 +1,227 @@
+package aocstub
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math/rand/v2"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"uocsclub.net/aoclb/internal/fetcher"
+)
+
+// Config describes the private leaderboard served by the stub
+type Config struct {
+	Year          string          `json:"year"`
+	LeaderboardId string          `json:"leaderboard_id"`
+	NumDays       int             `json:"num_days"`
+	Day1Timestamp int             `json:"day1_ts"`
+	SessionCookie string          `json:"session_cookie"` // if set, requests without this session get redirected like AOC does
+	Members       []*MemberConfig `json:"members"`
+}
+
+type MemberConfig struct {
+	Id    int           `json:"id"`
+	Name  string        `json:"name"`
+	Stars []*StarConfig `json:"stars"`
+}
+
+type StarConfig struct {
+	Day       int `json:"day"`
+	Star      int `json:"star"`
+	Timestamp int `json:"ts"`
+}
+
+// DefaultDay1Timestamp is midnight EST on December 1st, when AOC unlocks the first day
+func DefaultDay1Timestamp(year string) int {
+	y, err := strconv.Atoi(year)
+	if err != nil {
+		return 0
+	}
+	return int(time.Date(y, time.December, 1, 5, 0, 0, 0, time.UTC).Unix())
+}
+
+// Generate creates a deterministic leaderboard, the same seed always yields the same members and stars
+func Generate(seed uint64, year string, leaderboardId string, memberCount int, numDays int) *Config {
+	rng := rand.New(rand.NewPCG(seed, seed))
+
+	config := &Config{
+		Year:          year,
+		LeaderboardId: leaderboardId,
+		NumDays:       numDays,
+		Day1Timestamp: DefaultDay1Timestamp(year),
+		Members:       make([]*MemberConfig, 0, memberCount),
+	}
+
+	for i := range memberCount {
+		member := &MemberConfig{
+			Id:    1000000 + i,
+			Name:  fmt.Sprintf("Stub User %d", i+1),
+			Stars: []*StarConfig{},
+		}
+		// the first member is anonymous, like people who never set a display name
+		if i == 0 {
+			member.Name = ""
+		}
+
+		dedication := rng.Float64()
+		for day := 1; day <= numDays; day++ {
+			if rng.Float64() > dedication {
+				continue
+			}
+			unlock := config.Day1Timestamp + (day-1)*int(24*time.Hour/time.Second)
+			star1 := unlock + 600 + rng.IntN(20*60*60)
+			member.Stars = append(member.Stars, &StarConfig{Day: day, Star: 1, Timestamp: star1})
+
+			if rng.Float64() > dedication {
+				continue
+			}
+			star2 := star1 + 60 + rng.IntN(4*60*60)
+			member.Stars = append(member.Stars, &StarConfig{Day: day, Star: 2, Timestamp: star2})
+		}
+
+		config.Members = append(config.Members, member)
+	}
+
+	return config
+}
+
+// Leaderboard builds the payload AOC would return for this config, including local scores
+func (c *Config) Leaderboard() *fetcher.AOCResponseLeaderboard {
+	ownerId := 0
+	if len(c.Members) != 0 {
+		ownerId = c.Members[0].Id
+	}
+
+	response := &fetcher.AOCResponseLeaderboard{
+		OwnerId:        ownerId,
+		NumDays:        c.NumDays,
+		StartTimestamp: c.Day1Timestamp,
+		Year:           c.Year,
+		Members:        map[string]*fetcher.AOCLeaderboardMember{},
+	}
+
+	// star_index is a global counter in AOC, ordering every star by when it was obtained works just as well
+	allStars := []*StarConfig{}
+	for _, member := range c.Members {
+		allStars = append(allStars, member.Stars...)
+	}
+	slices.SortStableFunc(allStars, func(a, b *StarConfig) int {
+		return cmp.Compare(a.Timestamp, b.Timestamp)
+	})
+	starIndex := map[*StarConfig]int{}
+	for i, star := range allStars {
+		starIndex[star] = i
+	}
+
+	for _, member := range c.Members {
+		entry := &fetcher.AOCLeaderboardMember{
+			Id:             member.Id,
+			Name:           member.Name,
+			DayCompletions: map[int]*fetcher.AOCLeaderboardDayCompletion{},
+		}
+
+		for _, star := range member.Stars {
+			if entry.DayCompletions[star.Day] == nil {
+				entry.DayCompletions[star.Day] = &fetcher.AOCLeaderboardDayCompletion{}
+			}
+			completion := &fetcher.AOCLeaderboardStarCompletion{
+				Index:  starIndex[star],
+				StarTS: star.Timestamp,
+			}
+			switch star.Star {
+			case 1:
+				entry.DayCompletions[star.Day].Star1 = completion
+			case 2:
+				entry.DayCompletions[star.Day].Star2 = completion
+			default:
+				continue
+			}
+			entry.Stars += 1
+			entry.LastStarTimestamp = max(entry.LastStarTimestamp, star.Timestamp)
+		}
+
+		response.Members[strconv.Itoa(member.Id)] = entry
+	}
+
+	c.scoreMembers(response)
+
+	return response
+}
+
+// scoreMembers applies the AOC local score rules, the first member to get a star gets
+// one point per member, the second one gets one less, and so on
+func (c *Config) scoreMembers(response *fetcher.AOCResponseLeaderboard) {
+	memberCount := len(response.Members)
+
+	for day := 1; day <= c.NumDays; day++ {
+		for star := 1; star <= 2; star++ {
+			solvers := []*fetcher.AOCLeaderboardMember{}
+			completions := map[*fetcher.AOCLeaderboardMember]*fetcher.AOCLeaderboardStarCompletion{}
+
+			for _, member := range response.Members {
+				dayCompletion := member.DayCompletions[day]
+				if dayCompletion == nil {
+					continue
+				}
+				completion := dayCompletion.Star1
+				if star == 2 {
+					completion = dayCompletion.Star2
+				}
+				if completion == nil {
+					continue
+				}
+				solvers = append(solvers, member)
+				completions[member] = completion
+			}
+
+			slices.SortFunc(solvers, func(a, b *fetcher.AOCLeaderboardMember) int {
+				return cmp.Or(
+					cmp.Compare(completions[a].StarTS, completions[b].StarTS),
+					cmp.Compare(completions[a].Index, completions[b].Index),
+				)
+			})
+
+			for rank, member := range solvers {
+				member.LocalScore += memberCount - rank
+			}
+		}
+	}
+}
+
+// NewHandler serves the leaderboard on the same path as AOC's private leaderboard API
+func NewHandler(config *Config) http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("GET /{year}/leaderboard/private/view/{file}", func(w http.ResponseWriter, r *http.Request) {
+		year := r.PathValue("year")
+		leaderboardId, isJson := strings.CutSuffix(r.PathValue("file"), ".json")
+
+		if year != config.Year || !isJson || leaderboardId != config.LeaderboardId {
+			http.NotFound(w, r)
+			return
+		}
+
+		if len(config.SessionCookie) != 0 {
+			session, err := r.Cookie("session")
+			if err != nil || session.Value != config.SessionCookie {
+				// AOC sends logged out users back to the leaderboard page
+				http.Redirect(w, r, fmt.Sprintf("/%s/leaderboard/private", year), http.StatusFound)
+				return
+			}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		err := json.NewEncoder(w).Encode(config.Leaderboard())
+		if err != nil {
+			log.Println(err)
+		}
+	})
+
+	return mux
+}
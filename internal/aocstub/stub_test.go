@@ -0,0 +1,26 @@
+package aocstub
+
+import (
+	"testing"
+
+	"uocsclub.net/aoclb/internal/types"
+)
+
+// the stub scores the members on its own, both implementations of the AoC rules must agree
+func TestLeaderboardScoresMatchComputeLocalScores(t *testing.T) {
+	for seed := range uint64(20) {
+		config := Generate(seed, "2025", "123456", 15, 12)
+		// the same timestamp for several members, ties are broken by star_index
+		config.Members[1].Stars = append(config.Members[1].Stars, &StarConfig{Day: 12, Star: 1, Timestamp: config.Day1Timestamp + 11*86400 + 60})
+		config.Members[2].Stars = append(config.Members[2].Stars, &StarConfig{Day: 12, Star: 1, Timestamp: config.Day1Timestamp + 11*86400 + 60})
+
+		leaderboard := config.Leaderboard()
+		scores := types.ComputeLocalScores(leaderboard.ToAOCData(), config.NumDays, nil)
+
+		for _, member := range leaderboard.Members {
+			if scores[member.Id] != member.LocalScore {
+				t.Errorf("seed %d, member %d: computed %d, stub has %d", seed, member.Id, scores[member.Id], member.LocalScore)
+			}
+		}
+	}
+}
@@ -0,0 +1,142 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"uocsclub.net/aoclb/internal/types"
+)
+
+const GithubProviderName = "github"
+
+// GithubProvider logs in with a Github OAuth app, Github doesn't do OpenID Connect
+type GithubProvider struct {
+	ClientId     string
+	ClientSecret string
+	client       *http.Client
+}
+
+func NewGithubProvider(clientId string, clientSecret string) *GithubProvider {
+	return &GithubProvider{
+		ClientId:     clientId,
+		ClientSecret: clientSecret,
+		client:       &http.Client{},
+	}
+}
+
+func (p *GithubProvider) Name() string {
+	return GithubProviderName
+}
+
+func (p *GithubProvider) DisplayName() string {
+	return "Github"
+}
+
+func (p *GithubProvider) AuthURL(redirectUri string, login *LoginState) (string, error) {
+	authUrl, err := url.Parse("https://github.com/login/oauth/authorize")
+	if err != nil {
+		return "", err
+	}
+
+	query := authUrl.Query()
+	query.Set("client_id", p.ClientId)
+	query.Set("redirect_uri", redirectUri)
+	query.Set("state", login.State)
+	query.Set("code_challenge", login.Challenge())
+	query.Set("code_challenge_method", "S256")
+	authUrl.RawQuery = query.Encode()
+
+	return authUrl.String(), nil
+}
+
+func (p *GithubProvider) Exchange(ctx context.Context, code string, redirectUri string, login *LoginState) (*types.AOCIdentity, error) {
+	body := url.Values{}
+	body.Add("client_id", p.ClientId)
+	body.Add("client_secret", p.ClientSecret)
+	body.Add("code", code)
+	body.Add("redirect_uri", redirectUri)
+	body.Add("code_verifier", login.Verifier)
+
+	req, err := http.NewRequestWithContext(
+		ctx,
+		"POST",
+		"https://github.com/login/oauth/access_token",
+		strings.NewReader(body.Encode()),
+	)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Add("Accept", "application/json")
+	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch github access_token: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch github access_token, status: %d", resp.StatusCode)
+	}
+
+	parsedBody := struct {
+		AccessToken string `json:"access_token"`
+		Error       string `json:"error"`
+	}{}
+	err = json.NewDecoder(resp.Body).Decode(&parsedBody)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse github access_token: %w", err)
+	}
+
+	// github answers 200 with an error for bad codes
+	if len(parsedBody.AccessToken) == 0 {
+		return nil, ErrLoginDenied
+	}
+
+	return p.fetchUser(ctx, parsedBody.AccessToken)
+}
+
+func (p *GithubProvider) fetchUser(ctx context.Context, accessToken string) (*types.AOCIdentity, error) {
+	req, err := http.NewRequestWithContext(
+		ctx,
+		"GET",
+		"https://api.github.com/user",
+		nil,
+	)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Add("Accept", "application/json")
+	req.Header.Add("Authorization", "Bearer "+accessToken)
+	req.Header.Add("X-GitHub-Api-Version", "2022-11-28")
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch github user endpoint: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch github user endpoint, status: %d", resp.StatusCode)
+	}
+
+	parsedBody := struct {
+		AvatarUrl    string `json:"avatar_url"`
+		GithubUserId int    `json:"id"`
+	}{}
+	err = json.NewDecoder(resp.Body).Decode(&parsedBody)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse github user endpoint: %w", err)
+	}
+
+	return &types.AOCIdentity{
+		Provider:  GithubProviderName,
+		Subject:   strconv.Itoa(parsedBody.GithubUserId),
+		AvatarUrl: parsedBody.AvatarUrl,
+	}, nil
+}
@@ -0,0 +1,358 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"uocsclub.net/aoclb/internal/types"
+)
+
+var (
+	ErrInvalidIDToken = errors.New("invalid id token")
+)
+
+// clockSkew is how far the clocks of the provider and ours may drift apart
+const clockSkew = time.Minute
+
+type OIDCConfig struct {
+	Name         string // stored with the identities, e.g. gitlab or sso
+	DisplayName  string
+	Issuer       string // the discovery document is served under <issuer>/.well-known/openid-configuration
+	ClientId     string
+	ClientSecret string
+	Scopes       []string // openid is always requested
+}
+
+// OIDCProvider logs in with any OpenID Connect provider (Gitlab, Google Workspace, Keycloak...),
+// using the authorization code flow with PKCE. Only RS256 signed id tokens are accepted
+type OIDCProvider struct {
+	config OIDCConfig
+	client *http.Client
+
+	lock      sync.Mutex
+	discovery *oidcDiscovery // fetched on the first login so a provider being down doesn't stop the app
+	keys      map[string]*rsa.PublicKey
+}
+
+type oidcDiscovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JwksUri               string `json:"jwks_uri"`
+}
+
+type idTokenClaims struct {
+	Issuer   string   `json:"iss"`
+	Subject  string   `json:"sub"`
+	Audience audience `json:"aud"`
+	Azp      string   `json:"azp"`
+	Expiry   int64    `json:"exp"`
+	IssuedAt int64    `json:"iat"`
+	Nonce    string   `json:"nonce"`
+	Picture  string   `json:"picture"`
+}
+
+// audience is either a string or a list of strings in id tokens
+type audience []string
+
+func (a *audience) UnmarshalJSON(data []byte) error {
+	var single string
+	if json.Unmarshal(data, &single) == nil {
+		*a = audience{single}
+		return nil
+	}
+
+	var list []string
+	err := json.Unmarshal(data, &list)
+	if err != nil {
+		return err
+	}
+	*a = list
+
+	return nil
+}
+
+func NewOIDCProvider(config OIDCConfig) *OIDCProvider {
+	if !slices.Contains(config.Scopes, "openid") {
+		config.Scopes = append([]string{"openid"}, config.Scopes...)
+	}
+	if len(config.DisplayName) == 0 {
+		config.DisplayName = config.Name
+	}
+	config.Issuer = strings.TrimSuffix(config.Issuer, "/")
+
+	return &OIDCProvider{
+		config: config,
+		client: &http.Client{Timeout: 10 * time.Second},
+		keys:   map[string]*rsa.PublicKey{},
+	}
+}
+
+func (p *OIDCProvider) Name() string {
+	return p.config.Name
+}
+
+func (p *OIDCProvider) DisplayName() string {
+	return p.config.DisplayName
+}
+
+func (p *OIDCProvider) AuthURL(redirectUri string, login *LoginState) (string, error) {
+	discovery, err := p.discover(context.Background())
+	if err != nil {
+		return "", err
+	}
+
+	authUrl, err := url.Parse(discovery.AuthorizationEndpoint)
+	if err != nil {
+		return "", err
+	}
+
+	query := authUrl.Query()
+	query.Set("response_type", "code")
+	query.Set("client_id", p.config.ClientId)
+	query.Set("redirect_uri", redirectUri)
+	query.Set("state", login.State)
+	query.Set("scope", strings.Join(p.config.Scopes, " "))
+	query.Set("nonce", login.Nonce)
+	query.Set("code_challenge", login.Challenge())
+	query.Set("code_challenge_method", "S256")
+	authUrl.RawQuery = query.Encode()
+
+	return authUrl.String(), nil
+}
+
+func (p *OIDCProvider) Exchange(ctx context.Context, code string, redirectUri string, login *LoginState) (*types.AOCIdentity, error) {
+	discovery, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	body := url.Values{}
+	body.Add("grant_type", "authorization_code")
+	body.Add("code", code)
+	body.Add("redirect_uri", redirectUri)
+	body.Add("client_id", p.config.ClientId)
+	body.Add("client_secret", p.config.ClientSecret)
+	body.Add("code_verifier", login.Verifier)
+
+	req, err := http.NewRequestWithContext(ctx, "POST", discovery.TokenEndpoint, strings.NewReader(body.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Add("Accept", "application/json")
+	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch %s token: %w", p.config.Name, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusBadRequest || resp.StatusCode == http.StatusUnauthorized {
+		return nil, ErrLoginDenied
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch %s token, status: %d", p.config.Name, resp.StatusCode)
+	}
+
+	parsedBody := struct {
+		IdToken string `json:"id_token"`
+	}{}
+	err = json.NewDecoder(resp.Body).Decode(&parsedBody)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s token: %w", p.config.Name, err)
+	}
+
+	claims, err := p.verifyIDToken(ctx, discovery, parsedBody.IdToken, login.Nonce)
+	if err != nil {
+		return nil, err
+	}
+
+	return &types.AOCIdentity{
+		Provider:  p.config.Name,
+		Subject:   claims.Subject,
+		AvatarUrl: claims.Picture,
+	}, nil
+}
+
+func (p *OIDCProvider) discover(ctx context.Context) (*oidcDiscovery, error) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if p.discovery != nil {
+		return p.discovery, nil
+	}
+
+	discovery := &oidcDiscovery{}
+	err := p.getJSON(ctx, p.config.Issuer+"/.well-known/openid-configuration", discovery)
+	if err != nil {
+		return nil, err
+	}
+
+	if discovery.Issuer != p.config.Issuer {
+		return nil, fmt.Errorf("%s discovery issuer %q doesn't match %q", p.config.Name, discovery.Issuer, p.config.Issuer)
+	}
+	if len(discovery.AuthorizationEndpoint) == 0 || len(discovery.TokenEndpoint) == 0 || len(discovery.JwksUri) == 0 {
+		return nil, fmt.Errorf("%s discovery is missing endpoints", p.config.Name)
+	}
+
+	p.discovery = discovery
+	return discovery, nil
+}
+
+// verifyIDToken checks the signature and the claims of the id token
+func (p *OIDCProvider) verifyIDToken(ctx context.Context, discovery *oidcDiscovery, idToken string, nonce string) (*idTokenClaims, error) {
+	parts := strings.Split(idToken, ".")
+	if len(parts) != 3 {
+		return nil, ErrInvalidIDToken
+	}
+
+	header := struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}{}
+	err := decodeSegment(parts[0], &header)
+	if err != nil {
+		return nil, ErrInvalidIDToken
+	}
+	if header.Alg != "RS256" {
+		return nil, fmt.Errorf("%w: unsupported alg %q", ErrInvalidIDToken, header.Alg)
+	}
+
+	key, err := p.key(ctx, discovery, header.Kid)
+	if err != nil {
+		return nil, err
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, ErrInvalidIDToken
+	}
+	hash := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	err = rsa.VerifyPKCS1v15(key, crypto.SHA256, hash[:], signature)
+	if err != nil {
+		return nil, fmt.Errorf("%w: bad signature", ErrInvalidIDToken)
+	}
+
+	claims := &idTokenClaims{}
+	err = decodeSegment(parts[1], claims)
+	if err != nil {
+		return nil, ErrInvalidIDToken
+	}
+
+	now := time.Now()
+	switch {
+	case claims.Issuer != discovery.Issuer:
+		return nil, fmt.Errorf("%w: wrong issuer", ErrInvalidIDToken)
+	case !slices.Contains(claims.Audience, p.config.ClientId):
+		return nil, fmt.Errorf("%w: wrong audience", ErrInvalidIDToken)
+	case len(claims.Audience) > 1 && claims.Azp != p.config.ClientId:
+		return nil, fmt.Errorf("%w: wrong authorized party", ErrInvalidIDToken)
+	case now.Add(-clockSkew).After(time.Unix(claims.Expiry, 0)):
+		return nil, fmt.Errorf("%w: expired", ErrInvalidIDToken)
+	case now.Add(clockSkew).Before(time.Unix(claims.IssuedAt, 0)):
+		return nil, fmt.Errorf("%w: issued in the future", ErrInvalidIDToken)
+	case claims.Nonce != nonce:
+		return nil, fmt.Errorf("%w: wrong nonce", ErrInvalidIDToken)
+	case len(claims.Subject) == 0:
+		return nil, fmt.Errorf("%w: missing subject", ErrInvalidIDToken)
+	}
+
+	return claims, nil
+}
+
+// key returns the signing key of the provider, the keys are fetched again when the kid is unknown
+// since providers rotate them
+func (p *OIDCProvider) key(ctx context.Context, discovery *oidcDiscovery, kid string) (*rsa.PublicKey, error) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if key, ok := p.keys[kid]; ok {
+		return key, nil
+	}
+
+	jwks := struct {
+		Keys []struct {
+			Kty string `json:"kty"`
+			Kid string `json:"kid"`
+			Use string `json:"use"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+		} `json:"keys"`
+	}{}
+	err := p.getJSON(ctx, discovery.JwksUri, &jwks)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := map[string]*rsa.PublicKey{}
+	for _, jwk := range jwks.Keys {
+		if jwk.Kty != "RSA" || (len(jwk.Use) != 0 && jwk.Use != "sig") {
+			continue
+		}
+
+		n, err := base64.RawURLEncoding.DecodeString(jwk.N)
+		if err != nil {
+			continue
+		}
+		e, err := base64.RawURLEncoding.DecodeString(jwk.E)
+		if err != nil {
+			continue
+		}
+
+		keys[jwk.Kid] = &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}
+	}
+	p.keys = keys
+
+	key, ok := keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown key %q", ErrInvalidIDToken, kid)
+	}
+
+	return key, nil
+}
+
+func (p *OIDCProvider) getJSON(ctx context.Context, target string, value any) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", target, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Add("Accept", "application/json")
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to fetch %s: %w", target, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to fetch %s, status: %d", target, resp.StatusCode)
+	}
+
+	return json.NewDecoder(resp.Body).Decode(value)
+}
+
+func decodeSegment(segment string, value any) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(data, value)
+}
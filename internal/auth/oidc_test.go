@@ -0,0 +1,205 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testIdP is an OpenID Connect provider signing its id tokens with a single RS256 key
+type testIdP struct {
+	server *httptest.Server
+	key    *rsa.PrivateKey
+	token  map[string]any // claims of the id token the token endpoint returns
+	form   map[string]string
+}
+
+func newTestIdP(t *testing.T) *testIdP {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	idp := &testIdP{key: key}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 idp.server.URL,
+			"authorization_endpoint": idp.server.URL + "/authorize",
+			"token_endpoint":         idp.server.URL + "/token",
+			"jwks_uri":               idp.server.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{
+			"keys": []map[string]string{{
+				"kty": "RSA",
+				"kid": "key1",
+				"use": "sig",
+				"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			}},
+		})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		idp.form = map[string]string{}
+		for name := range r.PostForm {
+			idp.form[name] = r.PostForm.Get(name)
+		}
+
+		if idp.form["code"] != "code" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{"id_token": idp.sign(t, "RS256", "key1", idp.token)})
+	})
+	idp.server = httptest.NewServer(mux)
+	t.Cleanup(idp.server.Close)
+
+	return idp
+}
+
+func (idp *testIdP) sign(t *testing.T, alg string, kid string, claims map[string]any) string {
+	t.Helper()
+
+	segment := func(value any) string {
+		data, err := json.Marshal(value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return base64.RawURLEncoding.EncodeToString(data)
+	}
+
+	unsigned := segment(map[string]string{"alg": alg, "kid": kid, "typ": "JWT"}) + "." + segment(claims)
+	hash := sha256.Sum256([]byte(unsigned))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, idp.key, crypto.SHA256, hash[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return unsigned + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+// claims are valid id token claims for the client, the overrides replace or remove (nil) some of them
+func (idp *testIdP) claims(overrides map[string]any) map[string]any {
+	now := time.Now().Unix()
+	claims := map[string]any{
+		"iss":     idp.server.URL,
+		"sub":     "1234",
+		"aud":     "client",
+		"exp":     now + 300,
+		"iat":     now,
+		"nonce":   "nonce",
+		"picture": "https://example.com/avatar.png",
+	}
+	for name, value := range overrides {
+		if value == nil {
+			delete(claims, name)
+		} else {
+			claims[name] = value
+		}
+	}
+
+	return claims
+}
+
+func TestVerifyIDToken(t *testing.T) {
+	idp := newTestIdP(t)
+	provider := NewOIDCProvider(OIDCConfig{Name: "sso", Issuer: idp.server.URL, ClientId: "client"})
+
+	discovery, err := provider.discover(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other := &testIdP{key: otherKey}
+
+	now := time.Now().Unix()
+	token := idp.sign(t, "RS256", "key1", idp.claims(nil))
+	forged := strings.Split(idp.sign(t, "RS256", "key1", idp.claims(map[string]any{"sub": "admin"})), ".")
+	parts := strings.Split(token, ".")
+
+	tests := []struct {
+		name  string
+		token string
+		ok    bool
+	}{
+		{"valid", token, true},
+		{"audience list", idp.sign(t, "RS256", "key1", idp.claims(map[string]any{"aud": []string{"client"}})), true},
+		{"audiences with azp", idp.sign(t, "RS256", "key1", idp.claims(map[string]any{"aud": []string{"client", "other"}, "azp": "client"})), true},
+		{"within the clock skew", idp.sign(t, "RS256", "key1", idp.claims(map[string]any{"exp": now - 30, "iat": now + 30})), true},
+		{"not a jwt", "not.a-jwt", false},
+		{"unsupported alg", idp.sign(t, "HS256", "key1", idp.claims(nil)), false},
+		{"unknown key", idp.sign(t, "RS256", "key2", idp.claims(nil)), false},
+		{"other signer", other.sign(t, "RS256", "key1", idp.claims(nil)), false},
+		{"tampered payload", parts[0] + "." + forged[1] + "." + parts[2], false},
+		{"wrong issuer", idp.sign(t, "RS256", "key1", idp.claims(map[string]any{"iss": "https://evil.example.com"})), false},
+		{"wrong audience", idp.sign(t, "RS256", "key1", idp.claims(map[string]any{"aud": "other"})), false},
+		{"audiences without azp", idp.sign(t, "RS256", "key1", idp.claims(map[string]any{"aud": []string{"client", "other"}})), false},
+		{"expired", idp.sign(t, "RS256", "key1", idp.claims(map[string]any{"exp": now - 120})), false},
+		{"issued in the future", idp.sign(t, "RS256", "key1", idp.claims(map[string]any{"iat": now + 120})), false},
+		{"wrong nonce", idp.sign(t, "RS256", "key1", idp.claims(map[string]any{"nonce": "replayed"})), false},
+		{"missing subject", idp.sign(t, "RS256", "key1", idp.claims(map[string]any{"sub": nil})), false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			claims, err := provider.verifyIDToken(context.Background(), discovery, test.token, "nonce")
+			if test.ok && (err != nil || claims.Subject != "1234") {
+				t.Errorf("got %v, want the claims", err)
+			}
+			if !test.ok && !errors.Is(err, ErrInvalidIDToken) {
+				t.Errorf("got %v, want ErrInvalidIDToken", err)
+			}
+		})
+	}
+}
+
+func TestOIDCExchange(t *testing.T) {
+	idp := newTestIdP(t)
+	provider := NewOIDCProvider(OIDCConfig{Name: "sso", Issuer: idp.server.URL + "/", ClientId: "client", ClientSecret: "secret"})
+
+	login := &LoginState{Provider: "sso", State: "state", Verifier: "verifier", Nonce: "nonce"}
+	idp.token = idp.claims(nil)
+
+	identity, err := provider.Exchange(context.Background(), "code", "https://aoclb.example.com/oauth2", login)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if identity.Provider != "sso" || identity.Subject != "1234" || identity.AvatarUrl != "https://example.com/avatar.png" {
+		t.Errorf("got identity %+v", identity)
+	}
+	if idp.form["code_verifier"] != "verifier" || idp.form["client_secret"] != "secret" {
+		t.Errorf("got token request %v", idp.form)
+	}
+
+	// the nonce of another login
+	idp.token = idp.claims(map[string]any{"nonce": "other"})
+	_, err = provider.Exchange(context.Background(), "code", "https://aoclb.example.com/oauth2", login)
+	if !errors.Is(err, ErrInvalidIDToken) {
+		t.Errorf("got %v, want ErrInvalidIDToken", err)
+	}
+
+	_, err = provider.Exchange(context.Background(), "bad code", "https://aoclb.example.com/oauth2", login)
+	if !errors.Is(err, ErrLoginDenied) {
+		t.Errorf("got %v, want ErrLoginDenied", err)
+	}
+}
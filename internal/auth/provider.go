@@ -0,0 +1,92 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+
+	"uocsclub.net/aoclb/internal/types"
+)
+
+var (
+	ErrLoginDenied = errors.New("the identity provider refused the login")
+)
+
+// Provider is an identity provider users log in with, the identities it returns are linked to aoc users
+type Provider interface {
+	// Name identifies the provider in the database, changing it unlinks its users
+	Name() string
+	// DisplayName is shown on the login button
+	DisplayName() string
+	// AuthURL is where the user is sent to log in, the provider then redirects them to redirectUri with a code
+	AuthURL(redirectUri string, login *LoginState) (string, error)
+	// Exchange trades the code for the identity of the user
+	Exchange(ctx context.Context, code string, redirectUri string, login *LoginState) (*types.AOCIdentity, error)
+}
+
+// LoginState is kept in the session between the redirect to the provider and its callback
+type LoginState struct {
+	Provider string
+	State    string // sent back by the provider, ties the callback to the session that started the login
+	Verifier string // PKCE code verifier
+	Nonce    string
+}
+
+func NewLoginState(provider string) (*LoginState, error) {
+	state, err := randomString(16)
+	if err != nil {
+		return nil, err
+	}
+
+	verifier, err := randomString(32)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce, err := randomString(16)
+	if err != nil {
+		return nil, err
+	}
+
+	return &LoginState{
+		Provider: provider,
+		State:    state,
+		Verifier: verifier,
+		Nonce:    nonce,
+	}, nil
+}
+
+// Matches reports whether the state sent back by the provider is the one of this login
+func (l *LoginState) Matches(state string) bool {
+	return len(l.State) != 0 && subtle.ConstantTimeCompare([]byte(l.State), []byte(state)) == 1
+}
+
+// Challenge is the S256 PKCE challenge of the verifier
+func (l *LoginState) Challenge() string {
+	sum := sha256.Sum256([]byte(l.Verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// Find returns the provider with the name, nil if it isn't configured
+func Find(providers []Provider, name string) Provider {
+	for _, provider := range providers {
+		if provider.Name() == name {
+			return provider
+		}
+	}
+
+	return nil
+}
+
+func randomString(size int) (string, error) {
+	b := make([]byte, size)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
@@ -0,0 +1,105 @@
+package database
+
+import (
+	"database/sql"
+	"time"
+
+	"uocsclub.net/aoclb/internal/types"
+)
+
+// CreateApiToken stores the token under its hash, the token itself is never stored
+func (d *DatabaseInst) CreateApiToken(token *types.AOCApiToken, hash string) error {
+	d.dbLock.Lock()
+	defer d.dbLock.Unlock()
+
+	result, err := d.db.Exec(
+		"INSERT INTO api_token (aoc_id, name, token_hash, prefix, created_ts) VALUES (?, ?, ?, ?, ?);",
+		token.AocId,
+		token.Name,
+		hash,
+		token.Prefix,
+		token.CreatedAt,
+	)
+	if err != nil {
+		return err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+	token.Id = int(id)
+
+	return nil
+}
+
+func (d *DatabaseInst) GetApiTokens(aocId int) ([]*types.AOCApiToken, error) {
+	d.dbLock.Lock()
+	defer d.dbLock.Unlock()
+
+	return getApiTokensByFilter(d.db, "aoc_id = ?", aocId)
+}
+
+// DeleteApiToken revokes a token of the user, tokens of other users are left alone
+func (d *DatabaseInst) DeleteApiToken(aocId int, id int) error {
+	d.dbLock.Lock()
+	defer d.dbLock.Unlock()
+
+	_, err := d.db.Exec("DELETE FROM api_token WHERE id = ? AND aoc_id = ?;", id, aocId)
+	return err
+}
+
+// GetUserByApiToken returns the user owning the token, nil if it doesn't exist
+func (d *DatabaseInst) GetUserByApiToken(hash string) (*types.AOCUser, error) {
+	d.dbLock.Lock()
+	defer d.dbLock.Unlock()
+
+	tokens, err := getApiTokensByFilter(d.db, "token_hash = ?", hash)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) == 0 {
+		return nil, nil
+	}
+
+	_, err = d.db.Exec("UPDATE api_token SET last_used_ts = ? WHERE id = ?;", time.Now().Unix(), tokens[0].Id)
+	if err != nil {
+		return nil, err
+	}
+
+	users, err := getUsersByFilter(d.db, "u.aoc_id = ?", tokens[0].AocId)
+	if err != nil {
+		return nil, err
+	}
+	if len(users) == 0 {
+		return nil, nil
+	}
+
+	return users[0], nil
+}
+
+func getApiTokensByFilter(db *sql.DB, filter string, args ...any) ([]*types.AOCApiToken, error) {
+	query := "SELECT id, aoc_id, name, prefix, created_ts, last_used_ts FROM api_token"
+	if len(filter) != 0 {
+		query += " WHERE " + filter
+	}
+	query += " ORDER BY created_ts DESC, id DESC;"
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	output := []*types.AOCApiToken{}
+	for rows.Next() {
+		token := &types.AOCApiToken{}
+		err = rows.Scan(&token.Id, &token.AocId, &token.Name, &token.Prefix, &token.CreatedAt, &token.LastUsedAt)
+		if err != nil {
+			return nil, err
+		}
+		output = append(output, token)
+	}
+
+	return output, rows.Err()
+}
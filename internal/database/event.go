@@ -0,0 +1,114 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+
+	"uocsclub.net/aoclb/internal/types"
+)
+
+var ErrUnknownYear = errors.New("Year isn't tracked by any leaderboard")
+
+func (d *DatabaseInst) GetEvent(year string) (*types.AOCEvent, error) {
+	d.dbLock.Lock()
+	defer d.dbLock.Unlock()
+
+	return getEvent(d.db, year)
+}
+
+func getEvent(db *sql.DB, year string) (*types.AOCEvent, error) {
+	row := db.QueryRow("SELECT year, num_days, day1_ts, scoring_mode FROM event WHERE year = ?;", year)
+
+	event := &types.AOCEvent{}
+	err := row.Scan(&event.Year, &event.NumDays, &event.Day1Timestamp, &event.ScoringMode)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return event, nil
+}
+
+func (d *DatabaseInst) StoreEvent(event *types.AOCEvent) error {
+	if event == nil {
+		return nil
+	}
+
+	d.dbLock.Lock()
+	defer d.dbLock.Unlock()
+
+	return storeEvent(d.db, event)
+}
+
+func storeEvent(db dbtx, event *types.AOCEvent) error {
+	_, err := db.Exec(`
+		INSERT INTO event (year, num_days, day1_ts) VALUES (?, ?, ?)
+		ON CONFLICT (year) DO UPDATE SET
+		num_days = excluded.num_days,
+		day1_ts = excluded.day1_ts;
+		`,
+		event.Year,
+		event.NumDays,
+		event.Day1Timestamp,
+	)
+
+	return err
+}
+
+// SetScoringMode picks how modifiers are applied for the year, the event doesn't have to be fetched yet
+// but a private leaderboard has to track the year. ErrUnknownYear is returned otherwise, the event would
+// add a year without any day to the site
+func (d *DatabaseInst) SetScoringMode(year string, mode types.AOCScoringMode) error {
+	d.dbLock.Lock()
+	defer d.dbLock.Unlock()
+
+	var known bool
+	err := d.db.QueryRow(
+		"SELECT EXISTS (SELECT 1 FROM leaderboard WHERE year = ?) OR EXISTS (SELECT 1 FROM event WHERE year = ?);",
+		year,
+		year,
+	).Scan(&known)
+	if err != nil {
+		return err
+	}
+	if !known {
+		return ErrUnknownYear
+	}
+
+	_, err = d.db.Exec(`
+		INSERT INTO event (year, num_days, day1_ts, scoring_mode) VALUES (?, 0, 0, ?)
+		ON CONFLICT (year) DO UPDATE SET
+		scoring_mode = excluded.scoring_mode;
+		`,
+		year,
+		mode,
+	)
+
+	return err
+}
+
+func (d *DatabaseInst) GetEventYears() ([]string, error) {
+	d.dbLock.Lock()
+	defer d.dbLock.Unlock()
+
+	rows, err := d.db.Query("SELECT year FROM event ORDER BY year DESC;")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	years := []string{}
+	for rows.Next() {
+		var year string
+		err = rows.Scan(&year)
+		if err != nil {
+			return nil, err
+		}
+		years = append(years, year)
+	}
+
+	return years, rows.Err()
+}
@@ -0,0 +1,62 @@
+package database
+
+import (
+	"errors"
+	"testing"
+
+	"uocsclub.net/aoclb/internal/types"
+)
+
+func TestSetScoringMode(t *testing.T) {
+	db := testDatabase(t)
+
+	// 2025 is tracked but not fetched yet, 2024 was fetched
+	err := db.StorePrivateLeaderboard(&types.AOCPrivateLeaderboard{Id: "123456", Year: "2025", Name: "club", SessionEnv: "SESSION_ID"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = db.StoreEvent(&types.AOCEvent{Year: "2024", NumDays: 25, Day1Timestamp: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		year string
+		want error
+	}{
+		{"2025", nil},
+		{"2024", nil},
+		{"2019", ErrUnknownYear},
+	}
+
+	for _, test := range tests {
+		t.Run(test.year, func(t *testing.T) {
+			err := db.SetScoringMode(test.year, types.ScoringModePerStar)
+			if !errors.Is(err, test.want) {
+				t.Fatalf("got %v, want %v", err, test.want)
+			}
+
+			event, err := db.GetEvent(test.year)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if test.want != nil {
+				if event != nil {
+					t.Errorf("got event %+v for an unknown year", event)
+				}
+				return
+			}
+			if event == nil || event.ScoringMode != types.ScoringModePerStar {
+				t.Errorf("got event %+v, want the per star mode", event)
+			}
+		})
+	}
+
+	years, err := db.GetEventYears()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(years) != 2 {
+		t.Errorf("got years %v, want 2025 and 2024", years)
+	}
+}
@@ -0,0 +1,133 @@
+package database
+
+import (
+	"database/sql"
+	"log"
+
+	"uocsclub.net/aoclb/internal/types"
+)
+
+// GetFetchStatus returns the fetch status of the private leaderboard, a blank one if it was never fetched
+func (d *DatabaseInst) GetFetchStatus(leaderboardId string, year string) (*types.AOCFetchStatus, error) {
+	d.dbLock.Lock()
+	defer d.dbLock.Unlock()
+
+	statuses, err := getFetchStatusesByFilter(d.db, " leaderboard_id = ? AND year = ? ", leaderboardId, year)
+	if err != nil {
+		return nil, err
+	}
+	if len(statuses) == 0 {
+		return &types.AOCFetchStatus{
+			LeaderboardId: leaderboardId,
+			Year:          year,
+		}, nil
+	}
+
+	return statuses[0], nil
+}
+
+func (d *DatabaseInst) GetFetchStatusesByYear(year string) ([]*types.AOCFetchStatus, error) {
+	d.dbLock.Lock()
+	defer d.dbLock.Unlock()
+
+	return getFetchStatusesByFilter(d.db, " year = ? ", year)
+}
+
+func (d *DatabaseInst) StoreFetchStatus(status *types.AOCFetchStatus) error {
+	d.dbLock.Lock()
+	defer d.dbLock.Unlock()
+
+	_, err := d.db.Exec(`
+		INSERT INTO fetch_status (
+		leaderboard_id,
+		year,
+		last_attempt_ts,
+		last_success_ts,
+		status_code,
+		error,
+		error_kind,
+		consecutive_failures,
+		etag,
+		last_modified,
+		retry_after_ts)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (leaderboard_id, year) DO UPDATE SET
+		last_attempt_ts = excluded.last_attempt_ts,
+		last_success_ts = excluded.last_success_ts,
+		status_code = excluded.status_code,
+		error = excluded.error,
+		error_kind = excluded.error_kind,
+		consecutive_failures = excluded.consecutive_failures,
+		etag = excluded.etag,
+		last_modified = excluded.last_modified,
+		retry_after_ts = excluded.retry_after_ts;
+		`,
+		status.LeaderboardId,
+		status.Year,
+		status.LastAttempt,
+		status.LastSuccess,
+		status.StatusCode,
+		status.Error,
+		status.ErrorKind,
+		status.ConsecutiveFailures,
+		status.ETag,
+		status.LastModified,
+		status.RetryAfter,
+	)
+
+	return err
+}
+
+func getFetchStatusesByFilter(db *sql.DB, filter string, args ...any) ([]*types.AOCFetchStatus, error) {
+	query := `SELECT
+			leaderboard_id,
+			year,
+			last_attempt_ts,
+			last_success_ts,
+			status_code,
+			error,
+			error_kind,
+			consecutive_failures,
+			etag,
+			last_modified,
+			retry_after_ts
+		FROM fetch_status`
+
+	if len(filter) != 0 {
+		query += " WHERE " + filter
+	}
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	output := []*types.AOCFetchStatus{}
+
+	for rows.Next() {
+		rowData := &types.AOCFetchStatus{}
+
+		err := rows.Scan(
+			&rowData.LeaderboardId,
+			&rowData.Year,
+			&rowData.LastAttempt,
+			&rowData.LastSuccess,
+			&rowData.StatusCode,
+			&rowData.Error,
+			&rowData.ErrorKind,
+			&rowData.ConsecutiveFailures,
+			&rowData.ETag,
+			&rowData.LastModified,
+			&rowData.RetryAfter,
+		)
+		if err != nil {
+			log.Println(err)
+			continue
+		}
+
+		output = append(output, rowData)
+	}
+
+	return output, nil
+}
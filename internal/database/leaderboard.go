@@ -2,78 +2,133 @@ package database
 
 import (
 	"database/sql"
-	"errors"
-	"fmt"
 	"log"
-	"strconv"
-	"strings"
 
 	"uocsclub.net/aoclb/internal/types"
 )
 
-func (d *DatabaseInst) GetLeaderboard(year string) (types.AOCData, error) {
+// GetLeaderboard returns the entries of a private leaderboard, or of every private leaderboard
+// of the year merged together when leaderboardId is empty. Merged members are rescored against each other
+func (d *DatabaseInst) GetLeaderboard(year string, leaderboardId string) (types.AOCData, error) {
 	d.dbLock.Lock()
 	defer d.dbLock.Unlock()
 
 	data := types.AOCData{}
 
-	rows, err := d.db.Query("SELECT year, user_id, aoc_user.name, score, day_completions FROM leaderboard_entry LEFT JOIN aoc_user ON aoc_id = user_id WHERE year = ?", year)
+	query := "SELECT year, user_id, aoc_user.name, MAX(score) FROM leaderboard_entry LEFT JOIN aoc_user ON aoc_id = user_id WHERE year = ?"
+	args := []any{year}
+	if len(leaderboardId) != 0 {
+		query += " AND leaderboard_id = ?"
+		args = append(args, leaderboardId)
+	}
+	query += " GROUP BY user_id"
+
+	rows, err := d.db.Query(query, args...)
 	if err != nil {
 		return nil, err
 	}
+	defer rows.Close()
 
 	for rows.Next() {
 		entry := &types.AOCUserLB{
 			Completions: map[int]*types.AOCCompletion{},
 		}
-		var completions string
 
-		err = rows.Scan(&entry.Year, &entry.User.UserId, &entry.User.Name, &entry.Score, &completions)
+		err = rows.Scan(&entry.Year, &entry.User.UserId, &entry.User.Name, &entry.Score)
 
 		if err != nil {
 			return nil, err
 		}
 
-		for completion := range strings.SplitSeq(completions, ",") {
-			if len(completion) == 0 {
-				continue
-			}
-			s := strings.Split(completion, "d")
+		data[entry.User.UserId] = entry
+	}
 
-			if len(s) != 2 {
-				log.Printf("Got invalid completion format: %s\n", completion)
-				continue
-			}
+	err = loadStarCompletions(d.db, year, data)
+	if err != nil {
+		return nil, err
+	}
 
-			i1, err := strconv.Atoi(s[0])
-			if err != nil {
-				continue
-			}
+	if len(leaderboardId) == 0 {
+		err = rescoreMergedLeaderboard(d.db, year, data)
+		if err != nil {
+			return nil, err
+		}
+	}
 
-			if entry.Completions[i1] == nil {
-				entry.Completions[i1] = &types.AOCCompletion{}
-			}
+	return data, nil
+}
 
-			switch s[1] {
-			case "1":
-				entry.Completions[i1].Star1 = true
-			case "2":
-				entry.Completions[i1].Star2 = true
-			default:
-				log.Printf("Got invalid completion format: %s\n", completion)
-				continue
-			}
+// rescoreMergedLeaderboard computes the scores of the merged view, AOC only scores members against their own board
+func rescoreMergedLeaderboard(db *sql.DB, year string, data types.AOCData) error {
+	row := db.QueryRow("SELECT COUNT(DISTINCT leaderboard_id) FROM leaderboard_entry WHERE year = ?", year)
+	var boardCount int
+	err := row.Scan(&boardCount)
+	if err != nil {
+		return err
+	}
+	if boardCount <= 1 {
+		return nil
+	}
+
+	event, err := getEvent(db, year)
+	if err != nil || event == nil {
+		return err
+	}
+
+	types.RescoreAOCData(data, event.NumDays, nil)
+
+	return nil
+}
+
+// loadStarCompletions fills the Completions of every entry in data from the star_completion table
+func loadStarCompletions(db *sql.DB, year string, data types.AOCData) error {
+	rows, err := db.Query("SELECT user_id, day, star, star_ts, star_index, recorded_ts FROM star_completion WHERE year = ?", year)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var userId, day, star, starTs, starIndex, recordedTs int
 
+		err = rows.Scan(&userId, &day, &star, &starTs, &starIndex, &recordedTs)
+		if err != nil {
+			return err
 		}
-		entry.Modifiers, _ = getUserSubmissionsByFilter(d.db, " user_id = ? AND year = ?", entry.User.UserId, year)
 
-		data[entry.User.UserId] = entry
+		entry := data[userId]
+		if entry == nil {
+			continue
+		}
+
+		if entry.Completions[day] == nil {
+			entry.Completions[day] = &types.AOCCompletion{}
+		}
+		completion := entry.Completions[day]
+
+		switch star {
+		case 1:
+			completion.Star1 = true
+			completion.Star1TS = starTs
+			completion.Star1Index = starIndex
+			completion.Star1RecordedTS = recordedTs
+		case 2:
+			completion.Star2 = true
+			completion.Star2TS = starTs
+			completion.Star2Index = starIndex
+			completion.Star2RecordedTS = recordedTs
+		default:
+			log.Printf("Got invalid star completion: user %d day %d star %d\n", userId, day, star)
+		}
 	}
 
-	return data, nil
+	return rows.Err()
 }
 
-func (d *DatabaseInst) StoreLeaderboard(data types.AOCData) (types.AOCData, error) {
+// StoreLeaderboard replaces the entries of the private leaderboard, members that left the board are removed from it.
+// recordedAt is the time of the fetch, stars seen for the first time are recorded at it. The stars members
+// already had when they joined aren't new, they are recorded at 0
+func (d *DatabaseInst) StoreLeaderboard(leaderboard *types.AOCPrivateLeaderboard, data types.AOCData, recordedAt int) (*types.AOCLeaderboardChanges, error) {
 	d.dbLock.Lock()
 	defer d.dbLock.Unlock()
 
@@ -82,98 +137,258 @@ func (d *DatabaseInst) StoreLeaderboard(data types.AOCData) (types.AOCData, erro
 		return nil, err
 	}
 
-	err = ensureAOCUsers(db, data)
+	changes, err := storeLeaderboard(db, leaderboard, data, recordedAt)
 	if err != nil {
 		db.Rollback()
 		return nil, err
 	}
 
-	for _, entry := range data {
-		completions := make([]string, 0, len(entry.Completions)*2)
+	err = db.Commit()
+	if err != nil {
+		return nil, err
+	}
 
-		for day, completion := range entry.Completions {
-			if completion.Star1 {
-				completions = append(completions, fmt.Sprintf("%02dd1", day))
-			}
+	return changes, nil
+}
 
-			if completion.Star2 {
-				completions = append(completions, fmt.Sprintf("%02dd2", day))
-			}
-		}
+func storeLeaderboard(db *sql.Tx, leaderboard *types.AOCPrivateLeaderboard, data types.AOCData, recordedAt int) (*types.AOCLeaderboardChanges, error) {
+	changes := &types.AOCLeaderboardChanges{
+		LeaderboardId: leaderboard.Id,
+		Year:          leaderboard.Year,
+	}
 
-		row := db.QueryRow("SELECT user_id FROM leaderboard_entry WHERE year = ? AND user_id = ?", entry.Year, entry.User.UserId)
+	err := ensureAOCUsers(db, data)
+	if err != nil {
+		return nil, err
+	}
+
+	changes.Left, err = removeLeaderboardMembers(db, leaderboard, data)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, entry := range data {
+		starsRecordedAt := recordedAt
+		row := db.QueryRow("SELECT user_id FROM leaderboard_entry WHERE leaderboard_id = ? AND year = ? AND user_id = ?", leaderboard.Id, leaderboard.Year, entry.User.UserId)
 		var id int
 		if scanErr := row.Scan(&id); scanErr != nil {
-			_, err = db.Exec("INSERT INTO leaderboard_entry (year, user_id, score, day_completions) VALUES (?, ?, ?, ?);", entry.Year, entry.User.UserId, entry.Score, strings.Join(completions, ","))
-			if err != nil {
-				db.Rollback()
-				return nil, err
-			}
-			continue
+			_, err = db.Exec("INSERT INTO leaderboard_entry (leaderboard_id, year, user_id, score) VALUES (?, ?, ?, ?);", leaderboard.Id, leaderboard.Year, entry.User.UserId, entry.Score)
+			changes.Joined = append(changes.Joined, entry.User.UserId)
+			starsRecordedAt = 0
+		} else {
+			_, err = db.Exec("UPDATE leaderboard_entry SET score = ? WHERE leaderboard_id = ? AND year = ? AND user_id = ?;", entry.Score, leaderboard.Id, leaderboard.Year, entry.User.UserId)
 		}
-		_, err = db.Exec("UPDATE leaderboard_entry SET score = ?, day_completions = ? WHERE year = ? AND user_id = ?;", entry.Score, strings.Join(completions, ","), entry.Year, entry.User.UserId)
 		if err != nil {
-			db.Rollback()
 			return nil, err
 		}
-	}
 
-	db.Commit()
+		newStars, err := storeStarCompletions(db, entry, starsRecordedAt)
+		if err != nil {
+			return nil, err
+		}
+		changes.NewStars = append(changes.NewStars, newStars...)
+	}
 
-	return data, nil
+	return changes, nil
 }
 
-func (d *DatabaseInst) GetUserByGithubId(id int) (*types.AOCUser, error) {
-	d.dbLock.Lock()
-	defer d.dbLock.Unlock()
+// removeLeaderboardMembers deletes the entries of members who are no longer in the private leaderboard
+// and returns their aoc ids
+func removeLeaderboardMembers(db *sql.Tx, leaderboard *types.AOCPrivateLeaderboard, data types.AOCData) ([]int, error) {
+	rows, err := db.Query("SELECT user_id FROM leaderboard_entry WHERE leaderboard_id = ? AND year = ?", leaderboard.Id, leaderboard.Year)
+	if err != nil {
+		return nil, err
+	}
+
+	leftMembers := []int{}
+	for rows.Next() {
+		var id int
+		err = rows.Scan(&id)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		if data[id] == nil {
+			leftMembers = append(leftMembers, id)
+		}
+	}
+	rows.Close()
+
+	for _, id := range leftMembers {
+		_, err = db.Exec("DELETE FROM leaderboard_entry WHERE leaderboard_id = ? AND year = ? AND user_id = ?;", leaderboard.Id, leaderboard.Year, id)
+		if err != nil {
+			return nil, err
+		}
+	}
 
-	return getUserByGithubId(d.db, id)
+	return leftMembers, nil
 }
 
-func getUserByGithubId(db *sql.DB, id int) (*types.AOCUser, error) {
-	row := db.QueryRow("SELECT aoc_id, name, github_id, avatar_url FROM aoc_user WHERE github_id = ?;", id)
+// storeStarCompletions stores the stars of the entry and returns the ones it didn't have yet, nothing
+// is returned when recordedAt is 0
+func storeStarCompletions(db *sql.Tx, entry *types.AOCUserLB, recordedAt int) ([]*types.AOCNewStar, error) {
+	newStars := []*types.AOCNewStar{}
+
+	for day, completion := range entry.Completions {
+		stars := []struct {
+			done  bool
+			ts    int
+			index int
+		}{
+			{completion.Star1, completion.Star1TS, completion.Star1Index},
+			{completion.Star2, completion.Star2TS, completion.Star2Index},
+		}
 
-	user := &types.AOCUser{}
-	err := row.Scan(&user.UserId, &user.Name, &user.GithubId, &user.GithubAvatar)
-	if err == sql.ErrNoRows {
-		return nil, nil
+		for i, star := range stars {
+			if !star.done {
+				continue
+			}
+
+			result, err := db.Exec(`
+				INSERT INTO star_completion (year, user_id, day, star, star_ts, star_index, recorded_ts)
+				VALUES (?, ?, ?, ?, ?, ?, ?)
+				ON CONFLICT (year, user_id, day, star) DO NOTHING;
+				`,
+				entry.Year, entry.User.UserId, day, i+1, star.ts, star.index, recordedAt,
+			)
+			if err != nil {
+				return nil, err
+			}
+
+			inserted, err := result.RowsAffected()
+			if err != nil {
+				return nil, err
+			}
+			if inserted != 0 {
+				if recordedAt != 0 {
+					newStars = append(newStars, &types.AOCNewStar{
+						UserId: entry.User.UserId,
+						Day:    day,
+						Star:   i + 1,
+						StarTS: star.ts,
+					})
+				}
+				continue
+			}
+
+			// the star keeps the time it was first recorded at
+			_, err = db.Exec("UPDATE star_completion SET star_ts = ?, star_index = ? WHERE year = ? AND user_id = ? AND day = ? AND star = ?;",
+				star.ts, star.index, entry.Year, entry.User.UserId, day, i+1)
+			if err != nil {
+				return nil, err
+			}
+		}
 	}
 
+	return newStars, nil
+}
+
+func (d *DatabaseInst) GetUserByIdentity(identity types.AOCIdentity) (*types.AOCUser, error) {
+	d.dbLock.Lock()
+	defer d.dbLock.Unlock()
+
+	return getUserByIdentity(d.db, identity)
+}
+
+func getUserByIdentity(db *sql.DB, identity types.AOCIdentity) (*types.AOCUser, error) {
+	users, err := getUsersByFilter(db, "i.provider = ? AND i.subject = ?", identity.Provider, identity.Subject)
 	if err != nil {
-		log.Println(err)
 		return nil, err
 	}
+	if len(users) == 0 {
+		return nil, nil
+	}
 
-	return user, nil
+	return users[0], nil
 }
 
-func (d *DatabaseInst) LinkGithubUser(githubId int, githubAvatar string, aocId int64) (*types.AOCUser, error) {
+func (d *DatabaseInst) GetUserByAocId(aocId int) (*types.AOCUser, error) {
 	d.dbLock.Lock()
 	defer d.dbLock.Unlock()
 
-	user, err := getUserByGithubId(d.db, githubId)
+	users, err := getUsersByFilter(d.db, "u.aoc_id = ?", aocId)
 	if err != nil {
 		return nil, err
 	}
-	if user != nil {
-		return nil, errors.New("User already paired")
+	if len(users) == 0 {
+		return nil, nil
 	}
 
-	db, err := d.db.Begin()
-	if err != nil {
-		return nil, err
+	return users[0], nil
+}
+
+// GetLinkedUsers returns every user linked to an identity
+func (d *DatabaseInst) GetLinkedUsers() ([]*types.AOCUser, error) {
+	d.dbLock.Lock()
+	defer d.dbLock.Unlock()
+
+	return getUsersByFilter(d.db, "i.provider IS NOT NULL")
+}
+
+func getUsersByFilter(db *sql.DB, filter string, args ...any) ([]*types.AOCUser, error) {
+	query := `SELECT
+			u.aoc_id,
+			COALESCE(u.name, ''),
+			u.role,
+			COALESCE(i.provider, ''),
+			COALESCE(i.subject, ''),
+			COALESCE(i.avatar_url, '')
+		FROM aoc_user AS u
+		LEFT JOIN user_identity i ON i.aoc_id = u.aoc_id`
+	if len(filter) != 0 {
+		query += " WHERE " + filter
 	}
+	query += " ORDER BY u.name COLLATE NOCASE;"
 
-	_, err = db.Exec("UPDATE aoc_user SET github_id = ?, avatar_url = ? WHERE aoc_id = ?", githubId, githubAvatar, aocId)
+	rows, err := db.Query(query, args...)
 	if err != nil {
-		log.Println(err)
-		db.Rollback()
 		return nil, err
 	}
+	defer rows.Close()
 
-	db.Commit()
+	output := []*types.AOCUser{}
+	for rows.Next() {
+		user := &types.AOCUser{}
+		err = rows.Scan(&user.UserId, &user.Name, &user.Role, &user.Identity.Provider, &user.Identity.Subject, &user.Identity.AvatarUrl)
+		if err != nil {
+			log.Println(err)
+			return nil, err
+		}
+		output = append(output, user)
+	}
+
+	return output, rows.Err()
+}
+
+func (d *DatabaseInst) SetUserRole(aocId int, role types.AOCUserRole) error {
+	d.dbLock.Lock()
+	defer d.dbLock.Unlock()
+
+	_, err := d.db.Exec("UPDATE aoc_user SET role = ? WHERE aoc_id = ?;", role, aocId)
+	return err
+}
 
-	return getUserByGithubId(d.db, githubId)
+// BootstrapAdmins makes the users linked to the identities admins, identities linked later
+// are promoted when they log in
+func (d *DatabaseInst) BootstrapAdmins(identities []types.AOCIdentity) error {
+	d.dbLock.Lock()
+	defer d.dbLock.Unlock()
+
+	for _, identity := range identities {
+		_, err := d.db.Exec(`
+			UPDATE aoc_user SET role = ?
+			WHERE aoc_id IN (SELECT aoc_id FROM user_identity WHERE provider = ? AND subject = ?);
+			`,
+			types.RoleAdmin,
+			identity.Provider,
+			identity.Subject,
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
 }
 
 func ensureAOCUsers(db *sql.Tx, data types.AOCData) error {
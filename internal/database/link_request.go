@@ -0,0 +1,294 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	"uocsclub.net/aoclb/internal/types"
+)
+
+var (
+	ErrUserNotFound      = errors.New("AoC user isn't on any leaderboard")
+	ErrUserAlreadyLinked = errors.New("AoC user already linked to another account")
+)
+
+// CreateLinkRequest starts linking the identity to the aoc account, replacing any previous
+// request of the identity. An already linked identity is moved to the new aoc account once
+// the request is confirmed
+func (d *DatabaseInst) CreateLinkRequest(request *types.AOCLinkRequest) error {
+	d.dbLock.Lock()
+	defer d.dbLock.Unlock()
+
+	users, err := getUsersByFilter(d.db, "u.aoc_id = ?", request.AocId)
+	if err != nil {
+		return err
+	}
+	if len(users) == 0 {
+		return ErrUserNotFound
+	}
+	if users[0].Identity.Linked() {
+		return ErrUserAlreadyLinked
+	}
+
+	_, err = d.db.Exec(`
+		INSERT INTO link_request (provider, subject, aoc_id, token, avatar_url, created_ts) VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT (provider, subject) DO UPDATE SET
+		aoc_id = excluded.aoc_id,
+		token = excluded.token,
+		avatar_url = excluded.avatar_url,
+		created_ts = excluded.created_ts;
+		`,
+		request.Identity.Provider,
+		request.Identity.Subject,
+		request.AocId,
+		request.Token,
+		request.Identity.AvatarUrl,
+		request.CreatedAt,
+	)
+
+	return err
+}
+
+func (d *DatabaseInst) GetLinkRequest(identity types.AOCIdentity) (*types.AOCLinkRequest, error) {
+	d.dbLock.Lock()
+	defer d.dbLock.Unlock()
+
+	requests, err := getLinkRequestsByFilter(d.db, "provider = ? AND subject = ?", identity.Provider, identity.Subject)
+	if err != nil {
+		return nil, err
+	}
+	if len(requests) == 0 {
+		return nil, nil
+	}
+
+	return requests[0], nil
+}
+
+// ConfirmLinkRequests links the accounts whose aoc name contains their token, expired requests are dropped
+func (d *DatabaseInst) ConfirmLinkRequests(data types.AOCData) ([]*types.AOCUser, error) {
+	d.dbLock.Lock()
+	defer d.dbLock.Unlock()
+
+	requests, err := getLinkRequestsByFilter(d.db, "")
+	if err != nil {
+		return nil, err
+	}
+
+	db, err := d.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	linkedIds := []int{}
+	for _, request := range requests {
+		if request.Expired(now) {
+			err = deleteLinkRequest(db, request.Identity)
+			if err != nil {
+				db.Rollback()
+				return nil, err
+			}
+			continue
+		}
+
+		entry := data[request.AocId]
+		if entry == nil || !strings.Contains(entry.User.Name, request.Token) {
+			continue
+		}
+
+		// the aoc account might have been linked by an admin in the meantime
+		var linked int
+		err = db.QueryRow("SELECT COUNT(*) FROM user_identity WHERE aoc_id = ?;", request.AocId).Scan(&linked)
+		if err != nil {
+			db.Rollback()
+			return nil, err
+		}
+
+		if linked == 0 {
+			err = moveIdentity(db, request.Identity, request.AocId)
+			if err != nil {
+				db.Rollback()
+				return nil, err
+			}
+			linkedIds = append(linkedIds, request.AocId)
+		} else {
+			log.Printf("WARN: AoC user %d was linked before %s confirmed\n", request.AocId, request.Identity)
+		}
+
+		err = deleteLinkRequest(db, request.Identity)
+		if err != nil {
+			db.Rollback()
+			return nil, err
+		}
+	}
+
+	err = db.Commit()
+	if err != nil {
+		return nil, err
+	}
+
+	linked := []*types.AOCUser{}
+	for _, aocId := range linkedIds {
+		users, err := getUsersByFilter(d.db, "u.aoc_id = ?", aocId)
+		if err != nil {
+			return nil, err
+		}
+		linked = append(linked, users...)
+	}
+
+	return linked, nil
+}
+
+// ForceLinkUser is the admin override, it links the accounts without proof and unlinks
+// whatever either of them was linked to. It also transfers members to another aoc account
+func (d *DatabaseInst) ForceLinkUser(identity types.AOCIdentity, aocId int) (*types.AOCUser, error) {
+	d.dbLock.Lock()
+	defer d.dbLock.Unlock()
+
+	users, err := getUsersByFilter(d.db, "u.aoc_id = ?", aocId)
+	if err != nil {
+		return nil, err
+	}
+	if len(users) == 0 {
+		return nil, ErrUserNotFound
+	}
+
+	db, err := d.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+
+	// whoever was linked to the aoc account loses it
+	if current := users[0].Identity; current.Linked() && !current.Is(identity) {
+		err = unlinkUser(db, aocId)
+		if err != nil {
+			db.Rollback()
+			return nil, err
+		}
+	}
+
+	err = moveIdentity(db, identity, aocId)
+	if err != nil {
+		db.Rollback()
+		return nil, err
+	}
+
+	_, err = db.Exec("DELETE FROM link_request WHERE (provider = ? AND subject = ?) OR aoc_id = ?;", identity.Provider, identity.Subject, aocId)
+	if err != nil {
+		db.Rollback()
+		return nil, err
+	}
+
+	err = db.Commit()
+	if err != nil {
+		return nil, err
+	}
+
+	return getUserByIdentity(d.db, identity)
+}
+
+// UnlinkUser removes the identity linked to the aoc account, along with its role and api tokens
+func (d *DatabaseInst) UnlinkUser(aocId int) error {
+	d.dbLock.Lock()
+	defer d.dbLock.Unlock()
+
+	db, err := d.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	err = unlinkUser(db, aocId)
+	if err != nil {
+		db.Rollback()
+		return err
+	}
+
+	return db.Commit()
+}
+
+// unlinkUser also revokes the api tokens, they were made by whoever was linked
+func unlinkUser(db *sql.Tx, aocId int) error {
+	_, err := db.Exec("DELETE FROM user_identity WHERE aoc_id = ?;", aocId)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.Exec("DELETE FROM api_token WHERE aoc_id = ?;", aocId)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.Exec("UPDATE aoc_user SET role = ? WHERE aoc_id = ?;", types.RoleMember, aocId)
+	return err
+}
+
+// moveIdentity links the identity to the aoc account, the role and avatar follow the identity
+// if it was linked to another aoc account. An empty avatar keeps the current one
+func moveIdentity(db *sql.Tx, identity types.AOCIdentity, aocId int) error {
+	role := types.RoleMember
+	previousAocId := 0
+	avatarUrl := ""
+	err := db.QueryRow(`
+		SELECT u.aoc_id, u.role, i.avatar_url FROM user_identity AS i
+		JOIN aoc_user u ON u.aoc_id = i.aoc_id
+		WHERE i.provider = ? AND i.subject = ?;
+		`,
+		identity.Provider,
+		identity.Subject,
+	).Scan(&previousAocId, &role, &avatarUrl)
+	if err != nil && err != sql.ErrNoRows {
+		return err
+	}
+	if len(identity.AvatarUrl) != 0 {
+		avatarUrl = identity.AvatarUrl
+	}
+
+	if previousAocId != 0 {
+		err = unlinkUser(db, previousAocId)
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = db.Exec("INSERT INTO user_identity (provider, subject, aoc_id, avatar_url) VALUES (?, ?, ?, ?);",
+		identity.Provider, identity.Subject, aocId, avatarUrl)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.Exec("UPDATE aoc_user SET role = ? WHERE aoc_id = ?;", role, aocId)
+	return err
+}
+
+func deleteLinkRequest(db *sql.Tx, identity types.AOCIdentity) error {
+	_, err := db.Exec("DELETE FROM link_request WHERE provider = ? AND subject = ?;", identity.Provider, identity.Subject)
+	return err
+}
+
+func getLinkRequestsByFilter(db *sql.DB, filter string, args ...any) ([]*types.AOCLinkRequest, error) {
+	query := "SELECT provider, subject, avatar_url, aoc_id, token, created_ts FROM link_request"
+	if len(filter) != 0 {
+		query += " WHERE " + filter
+	}
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	output := []*types.AOCLinkRequest{}
+	for rows.Next() {
+		request := &types.AOCLinkRequest{}
+		err = rows.Scan(&request.Identity.Provider, &request.Identity.Subject, &request.Identity.AvatarUrl, &request.AocId, &request.Token, &request.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		output = append(output, request)
+	}
+
+	return output, rows.Err()
+}
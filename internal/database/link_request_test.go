@@ -0,0 +1,236 @@
+package database
+
+import (
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+
+	"uocsclub.net/aoclb/internal/types"
+)
+
+// testLeaderboard stores a private leaderboard with a member for every aoc id and name
+func testLeaderboard(t *testing.T, db *DatabaseInst, names map[int]string) types.AOCData {
+	t.Helper()
+
+	leaderboard := &types.AOCPrivateLeaderboard{Id: "123456", Year: "2025", Name: "club"}
+	err := db.StorePrivateLeaderboard(leaderboard)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data := types.AOCData{}
+	for id, name := range names {
+		data[id] = &types.AOCUserLB{Year: "2025", User: types.AOCUser{UserId: id, Name: name}, Completions: map[int]*types.AOCCompletion{}}
+	}
+	_, err = db.StoreLeaderboard(leaderboard, data, 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return data
+}
+
+// testIdentity is the github account with the id
+func testIdentity(githubId int) types.AOCIdentity {
+	return types.AOCIdentity{Provider: "github", Subject: strconv.Itoa(githubId)}
+}
+
+func TestCreateLinkRequest(t *testing.T) {
+	db := testDatabase(t)
+	testLeaderboard(t, db, map[int]string{1001: "alice", 1002: "bob"})
+
+	_, err := db.ForceLinkUser(testIdentity(1), 1001)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		githubId int
+		aocId    int
+		want     error
+	}{
+		{"unknown aoc account", 2, 9999, ErrUserNotFound},
+		{"aoc account already linked", 2, 1001, ErrUserAlreadyLinked},
+		{"linked github account moving to another aoc account", 1, 1002, nil},
+		{"new link", 2, 1002, nil},
+		{"replaces the previous request", 2, 1002, nil},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := db.CreateLinkRequest(&types.AOCLinkRequest{
+				Identity:  testIdentity(test.githubId),
+				AocId:     test.aocId,
+				Token:     "aoclb-00000000",
+				CreatedAt: int(time.Now().Unix()),
+			})
+			if !errors.Is(err, test.want) {
+				t.Errorf("got %v, want %v", err, test.want)
+			}
+		})
+	}
+}
+
+func TestConfirmLinkRequests(t *testing.T) {
+	db := testDatabase(t)
+	testLeaderboard(t, db, map[int]string{1001: "alice", 1002: "bob", 1003: "carol"})
+
+	now := int(time.Now().Unix())
+	expired := int(time.Now().Add(-types.LinkRequestExpiry - time.Minute).Unix())
+	requests := []*types.AOCLinkRequest{
+		{Identity: testIdentity(1), AocId: 1001, Token: "aoclb-11111111", CreatedAt: now},
+		{Identity: testIdentity(2), AocId: 1002, Token: "aoclb-22222222", CreatedAt: now},
+		{Identity: testIdentity(3), AocId: 1003, Token: "aoclb-33333333", CreatedAt: expired},
+	}
+	for _, request := range requests {
+		err := db.CreateLinkRequest(request)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// alice and carol put their token in their name, bob didn't yet
+	data := testLeaderboard(t, db, map[int]string{1001: "alice aoclb-11111111", 1002: "bob", 1003: "carol aoclb-33333333"})
+
+	linked, err := db.ConfirmLinkRequests(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(linked) != 1 || linked[0].UserId != 1001 || !linked[0].Identity.Is(testIdentity(1)) {
+		t.Fatalf("got %v linked, want alice", linked)
+	}
+
+	tests := []struct {
+		name        string
+		githubId    int
+		wantLinked  bool
+		wantRequest bool
+	}{
+		{"confirmed", 1, true, false},
+		{"pending", 2, false, true},
+		{"expired", 3, false, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			user, err := db.GetUserByIdentity(testIdentity(test.githubId))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if (user != nil) != test.wantLinked {
+				t.Errorf("got linked user %v, want linked %t", user, test.wantLinked)
+			}
+
+			request, err := db.GetLinkRequest(testIdentity(test.githubId))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if (request != nil) != test.wantRequest {
+				t.Errorf("got request %v, want pending %t", request, test.wantRequest)
+			}
+		})
+	}
+}
+
+func TestUnlinkUser(t *testing.T) {
+	db := testDatabase(t)
+	testLeaderboard(t, db, map[int]string{1001: "alice"})
+
+	_, err := db.ForceLinkUser(testIdentity(1), 1001)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = db.SetUserRole(1001, types.RoleReviewer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = db.UnlinkUser(1001)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	user, err := db.GetUserByIdentity(testIdentity(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user != nil {
+		t.Errorf("identity still linked to %d", user.UserId)
+	}
+
+	user, err = db.GetUserByAocId(1001)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.Identity.Linked() || user.Role != types.RoleMember {
+		t.Errorf("got identity %s and role %s, want an unlinked member", user.Identity, user.Role)
+	}
+}
+
+func TestTransferUser(t *testing.T) {
+	tests := []struct {
+		name     string
+		transfer func(db *DatabaseInst) error
+	}{
+		{
+			name: "confirmed link request",
+			transfer: func(db *DatabaseInst) error {
+				err := db.CreateLinkRequest(&types.AOCLinkRequest{Identity: testIdentity(1), AocId: 1002, Token: "aoclb-22222222", CreatedAt: int(time.Now().Unix())})
+				if err != nil {
+					return err
+				}
+
+				data := testLeaderboard(t, db, map[int]string{1001: "alice", 1002: "alice aoclb-22222222"})
+				_, err = db.ConfirmLinkRequests(data)
+				return err
+			},
+		},
+		{
+			name: "admin override",
+			transfer: func(db *DatabaseInst) error {
+				_, err := db.ForceLinkUser(testIdentity(1), 1002)
+				return err
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			db := testDatabase(t)
+			testLeaderboard(t, db, map[int]string{1001: "alice", 1002: "alice"})
+
+			_, err := db.ForceLinkUser(testIdentity(1), 1001)
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = db.SetUserRole(1001, types.RoleReviewer)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = test.transfer(db)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// the identity and its role moved to the new aoc account
+			user, err := db.GetUserByIdentity(testIdentity(1))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if user == nil || user.UserId != 1002 || user.Role != types.RoleReviewer {
+				t.Errorf("got %v, want the reviewer linked to 1002", user)
+			}
+
+			previous, err := db.GetUserByAocId(1001)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if previous.Identity.Linked() || previous.Role != types.RoleMember {
+				t.Errorf("got identity %s and role %s on the previous account, want an unlinked member", previous.Identity, previous.Role)
+			}
+		})
+	}
+}
@@ -15,6 +15,13 @@ type DatabaseInst struct {
 	dbLock sync.Mutex
 }
 
+// dbtx is implemented by both *sql.DB and *sql.Tx, for the helpers used in and out of transactions
+type dbtx interface {
+	Exec(query string, args ...any) (sql.Result, error)
+	Query(query string, args ...any) (*sql.Rows, error)
+	QueryRow(query string, args ...any) *sql.Row
+}
+
 func InitDatabase(filePath string, migrationDir string) (*DatabaseInst, error) {
 	db, err := sql.Open("sqlite3", filePath)
 
@@ -0,0 +1,339 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"uocsclub.net/aoclb/internal/types"
+)
+
+var (
+	ErrModifierSetLocked = errors.New("Modifier set is locked")
+	ErrModifierExists    = errors.New("Language already has a modifier")
+	ErrModifierNotFound  = errors.New("Language doesn't have a modifier")
+)
+
+func (d *DatabaseInst) GetModifierSet(year string) (*types.AOCModifierSet, error) {
+	d.dbLock.Lock()
+	defer d.dbLock.Unlock()
+
+	return getModifierSet(d.db, year)
+}
+
+func getModifierSet(db *sql.DB, year string) (*types.AOCModifierSet, error) {
+	row := db.QueryRow("SELECT year, locked FROM modifier_set WHERE year = ?;", year)
+
+	set := &types.AOCModifierSet{}
+	err := row.Scan(&set.Year, &set.Locked)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return set, nil
+}
+
+// EnsureModifierSet creates the modifier set of a new year as a copy of the closest
+// earlier year, or of the newest one when the year is older than every set. The first
+// set starts from the default modifiers
+func (d *DatabaseInst) EnsureModifierSet(year string) error {
+	d.dbLock.Lock()
+	defer d.dbLock.Unlock()
+
+	set, err := getModifierSet(d.db, year)
+	if err != nil || set != nil {
+		return err
+	}
+
+	db, err := d.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	var source sql.NullString
+	err = db.QueryRow(`
+		SELECT COALESCE(
+			(SELECT MAX(year) FROM modifier_set WHERE year < ?),
+			(SELECT MAX(year) FROM modifier_set)
+		);
+		`,
+		year,
+	).Scan(&source)
+	if err != nil {
+		db.Rollback()
+		return err
+	}
+
+	_, err = db.Exec("INSERT INTO modifier_set (year) VALUES (?);", year)
+	if err != nil {
+		db.Rollback()
+		return err
+	}
+
+	if source.Valid {
+		_, err = db.Exec(`
+			INSERT INTO modifiers (year, language_name, modifier_dec_percent, retired)
+			SELECT ?, language_name, modifier_dec_percent, retired FROM modifiers WHERE year = ?;
+			`,
+			year,
+			source.String,
+		)
+		if err != nil {
+			db.Rollback()
+			return err
+		}
+	} else {
+		_, err = db.Exec(`
+			INSERT INTO modifiers (year, language_name, modifier_dec_percent)
+			SELECT ?, language_name, modifier_dec_percent FROM default_modifiers;
+			`,
+			year,
+		)
+		if err != nil {
+			db.Rollback()
+			return err
+		}
+	}
+
+	return db.Commit()
+}
+
+// LockModifierSet freezes the modifiers of a year, locking is permanent
+func (d *DatabaseInst) LockModifierSet(year string) error {
+	d.dbLock.Lock()
+	defer d.dbLock.Unlock()
+
+	_, err := d.db.Exec("UPDATE modifier_set SET locked = 1 WHERE year = ?;", year)
+	return err
+}
+
+// AddModifier adds a language to the modifier set of a year, locked years are refused
+func (d *DatabaseInst) AddModifier(year string, modifier *types.AOCSubmissionModifier, userId int) error {
+	d.dbLock.Lock()
+	defer d.dbLock.Unlock()
+
+	db, err := d.beginModifierChange(year)
+	if err != nil {
+		return err
+	}
+
+	existing, err := getModifier(db, year, modifier.LanguageName)
+	if err != nil {
+		db.Rollback()
+		return err
+	}
+	if existing != nil {
+		db.Rollback()
+		return ErrModifierExists
+	}
+
+	_, err = db.Exec(`
+		INSERT INTO modifiers (year, language_name, modifier_dec_percent, retired) VALUES (?, ?, ?, ?);
+		`,
+		year,
+		modifier.LanguageName,
+		modifier.ModifierDecPercent,
+		modifier.Retired,
+	)
+	if err != nil {
+		db.Rollback()
+		return err
+	}
+
+	err = storeModifierAudit(db, year, userId, types.ModifierActionAdd, modifier.LanguageName, "", types.FormatDecPercent(modifier.ModifierDecPercent))
+	if err != nil {
+		db.Rollback()
+		return err
+	}
+
+	return db.Commit()
+}
+
+// UpdateModifier renames, re-weights, retires or restores the language, every change is audited.
+// Renaming also moves the submissions of that year to the new name
+func (d *DatabaseInst) UpdateModifier(year string, languageName string, modifier *types.AOCSubmissionModifier, userId int) error {
+	d.dbLock.Lock()
+	defer d.dbLock.Unlock()
+
+	db, err := d.beginModifierChange(year)
+	if err != nil {
+		return err
+	}
+
+	existing, err := getModifier(db, year, languageName)
+	if err != nil {
+		db.Rollback()
+		return err
+	}
+	if existing == nil {
+		db.Rollback()
+		return ErrModifierNotFound
+	}
+
+	if modifier.LanguageName != existing.LanguageName {
+		duplicate, err := getModifier(db, year, modifier.LanguageName)
+		if err != nil {
+			db.Rollback()
+			return err
+		}
+		if duplicate != nil {
+			db.Rollback()
+			return ErrModifierExists
+		}
+
+		_, err = db.Exec("UPDATE modifiers SET language_name = ? WHERE year = ? AND language_name = ?;", modifier.LanguageName, year, existing.LanguageName)
+		if err != nil {
+			db.Rollback()
+			return err
+		}
+
+		_, err = db.Exec("UPDATE modifier_submission SET language_name = ? WHERE year = ? AND language_name = ?;", modifier.LanguageName, year, existing.LanguageName)
+		if err != nil {
+			db.Rollback()
+			return err
+		}
+
+		err = storeModifierAudit(db, year, userId, types.ModifierActionRename, modifier.LanguageName, existing.LanguageName, modifier.LanguageName)
+		if err != nil {
+			db.Rollback()
+			return err
+		}
+	}
+
+	if modifier.ModifierDecPercent != existing.ModifierDecPercent {
+		_, err = db.Exec("UPDATE modifiers SET modifier_dec_percent = ? WHERE year = ? AND language_name = ?;", modifier.ModifierDecPercent, year, modifier.LanguageName)
+		if err != nil {
+			db.Rollback()
+			return err
+		}
+
+		err = storeModifierAudit(db, year, userId, types.ModifierActionReweight, modifier.LanguageName,
+			types.FormatDecPercent(existing.ModifierDecPercent), types.FormatDecPercent(modifier.ModifierDecPercent))
+		if err != nil {
+			db.Rollback()
+			return err
+		}
+	}
+
+	if modifier.Retired != existing.Retired {
+		_, err = db.Exec("UPDATE modifiers SET retired = ? WHERE year = ? AND language_name = ?;", modifier.Retired, year, modifier.LanguageName)
+		if err != nil {
+			db.Rollback()
+			return err
+		}
+
+		action := types.ModifierActionRestore
+		if modifier.Retired {
+			action = types.ModifierActionRetire
+		}
+		err = storeModifierAudit(db, year, userId, action, modifier.LanguageName, "", "")
+		if err != nil {
+			db.Rollback()
+			return err
+		}
+	}
+
+	return db.Commit()
+}
+
+// beginModifierChange starts the transaction of a change to the modifier set of the year,
+// creating the set if needed. Locked sets are refused
+func (d *DatabaseInst) beginModifierChange(year string) (*sql.Tx, error) {
+	set, err := getModifierSet(d.db, year)
+	if err != nil {
+		return nil, err
+	}
+	if set != nil && set.Locked {
+		return nil, ErrModifierSetLocked
+	}
+
+	db, err := d.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = db.Exec("INSERT INTO modifier_set (year) VALUES (?) ON CONFLICT (year) DO NOTHING;", year)
+	if err != nil {
+		db.Rollback()
+		return nil, err
+	}
+
+	return db, nil
+}
+
+func getModifier(db *sql.Tx, year string, languageName string) (*types.AOCSubmissionModifier, error) {
+	row := db.QueryRow("SELECT language_name, modifier_dec_percent, retired FROM modifiers WHERE year = ? AND language_name = ?;", year, languageName)
+
+	modifier := &types.AOCSubmissionModifier{}
+	err := row.Scan(&modifier.LanguageName, &modifier.ModifierDecPercent, &modifier.Retired)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return modifier, nil
+}
+
+func storeModifierAudit(db *sql.Tx, year string, userId int, action types.AOCModifierAction, languageName string, oldValue string, newValue string) error {
+	_, err := db.Exec(`
+		INSERT INTO modifier_audit (year, changed_ts, user_id, action, language_name, old_value, new_value)
+		VALUES (?, ?, ?, ?, ?, ?, ?);
+		`,
+		year,
+		time.Now().Unix(),
+		userId,
+		action,
+		languageName,
+		oldValue,
+		newValue,
+	)
+
+	return err
+}
+
+// GetModifierAudit returns the changes made to the modifier set of the year, newest first
+func (d *DatabaseInst) GetModifierAudit(year string) ([]*types.AOCModifierAudit, error) {
+	d.dbLock.Lock()
+	defer d.dbLock.Unlock()
+
+	rows, err := d.db.Query(`
+		SELECT
+			id,
+			year,
+			changed_ts,
+			user_id,
+			COALESCE((SELECT name FROM aoc_user WHERE aoc_id = a.user_id), ''),
+			action,
+			language_name,
+			old_value,
+			new_value
+		FROM modifier_audit AS a
+		WHERE year = ?
+		ORDER BY changed_ts DESC, id DESC;
+		`,
+		year,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	output := []*types.AOCModifierAudit{}
+	for rows.Next() {
+		audit := &types.AOCModifierAudit{}
+		err = rows.Scan(&audit.Id, &audit.Year, &audit.Timestamp, &audit.UserId, &audit.UserName, &audit.Action, &audit.LanguageName, &audit.OldValue, &audit.NewValue)
+		if err != nil {
+			return nil, err
+		}
+		output = append(output, audit)
+	}
+
+	return output, rows.Err()
+}
@@ -0,0 +1,61 @@
+package database
+
+import (
+	"path/filepath"
+	"testing"
+
+	"uocsclub.net/aoclb/internal/types"
+)
+
+func testDatabase(t *testing.T) *DatabaseInst {
+	t.Helper()
+
+	db, err := InitDatabase(filepath.Join(t.TempDir(), "aoclb.db"), "../../migrations")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.db.Close() })
+
+	return db
+}
+
+func TestEnsureModifierSetCopiesRetired(t *testing.T) {
+	db := testDatabase(t)
+
+	err := db.AddModifier("2030", &types.AOCSubmissionModifier{LanguageName: "kodr", ModifierDecPercent: 25, Retired: true}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = db.EnsureModifierSet("2031")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var retired bool
+	err = db.db.QueryRow("SELECT retired FROM modifiers WHERE year = '2031' AND language_name = 'kodr';").Scan(&retired)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !retired {
+		t.Error("the retired language of 2030 isn't retired in 2031")
+	}
+}
+
+func TestEnsureModifierSetStartsFromDefaults(t *testing.T) {
+	db := testDatabase(t)
+
+	err := db.EnsureModifierSet("2025")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var percent int
+	err = db.db.QueryRow("SELECT modifier_dec_percent FROM modifiers WHERE year = '2025' AND language_name = 'VHDL';").Scan(&percent)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if percent != 40 {
+		t.Errorf("got %d for VHDL, want the default of 40", percent)
+	}
+}
@@ -2,19 +2,38 @@ package database
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
 	"log"
 	"strconv"
 	"strings"
+	"time"
 
 	"uocsclub.net/aoclb/internal/types"
 )
 
+var ErrSubmissionReviewed = errors.New("Submission was already reviewed")
+
 func (d *DatabaseInst) GetUserSubmissions(year string, aocUserId int) ([]*types.AOCUserSubmission, error) {
 	d.dbLock.Lock()
 	defer d.dbLock.Unlock()
 
-	return getUserSubmissionsByFilter(d.db, "user_id = ? AND year = ?", aocUserId, year)
+	return getUserSubmissionsByFilter(d.db, "user_id = ? AND s.year = ?", aocUserId, year)
+}
+
+func (d *DatabaseInst) GetSubmissionsByYear(year string) ([]*types.AOCUserSubmission, error) {
+	d.dbLock.Lock()
+	defer d.dbLock.Unlock()
+
+	return getUserSubmissionsByFilter(d.db, "s.year = ?", year)
+}
+
+// GetSubmissionsByStatus returns the submissions of the year in that review state, oldest first
+func (d *DatabaseInst) GetSubmissionsByStatus(year string, status types.AOCSubmissionStatus) ([]*types.AOCUserSubmission, error) {
+	d.dbLock.Lock()
+	defer d.dbLock.Unlock()
+
+	return getUserSubmissionsByFilter(d.db, "s.year = ? AND status = ? ORDER BY id", year, status)
 }
 
 func (d *DatabaseInst) AddUserSubmission(year string, submission *types.AOCUserSubmission) (*types.AOCUserSubmission, error) {
@@ -52,6 +71,8 @@ func (d *DatabaseInst) AddUserSubmission(year string, submission *types.AOCUserS
 	db.Commit()
 
 	submission.Id = id
+	submission.Year = year
+	submission.Status = types.SubmissionPending
 	return submission, nil
 }
 
@@ -68,7 +89,11 @@ func (d *DatabaseInst) UpdateUserSubmission(submission *types.AOCUserSubmission)
 		UPDATE modifier_submission SET
 		day = ?,
 		submission_url = ?,
-		language_name = ?
+		language_name = ?,
+		status = 'pending',
+		reject_reason = '',
+		reviewer_id = NULL,
+		reviewed_ts = 0
 		WHERE id = ?;
 		`,
 		fmt.Sprintf("%02dd%d", submission.Date, submission.Star),
@@ -85,6 +110,11 @@ func (d *DatabaseInst) UpdateUserSubmission(submission *types.AOCUserSubmission)
 
 	db.Commit()
 
+	// a changed submission has to be reviewed again
+	submission.Status = types.SubmissionPending
+	submission.RejectReason = ""
+	submission.ReviewerId = 0
+	submission.ReviewedAt = 0
 	return submission, nil
 }
 
@@ -113,6 +143,45 @@ func (d *DatabaseInst) DeleteUserSubmission(submissionId int) error {
 	return nil
 }
 
+// ReviewUserSubmission approves or rejects a pending submission, the reason is only kept for rejections
+func (d *DatabaseInst) ReviewUserSubmission(submissionId int, status types.AOCSubmissionStatus, reason string, reviewerId int) error {
+	d.dbLock.Lock()
+	defer d.dbLock.Unlock()
+
+	if status != types.SubmissionRejected {
+		reason = ""
+	}
+
+	res, err := d.db.Exec(`
+		UPDATE modifier_submission SET
+		status = ?,
+		reject_reason = ?,
+		reviewer_id = ?,
+		reviewed_ts = ?
+		WHERE id = ? AND status = ?;
+		`,
+		status,
+		reason,
+		reviewerId,
+		time.Now().Unix(),
+		submissionId,
+		types.SubmissionPending,
+	)
+	if err != nil {
+		return err
+	}
+
+	count, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return ErrSubmissionReviewed
+	}
+
+	return nil
+}
+
 func (d *DatabaseInst) GetUserSubmissionById(submissionId int) (*types.AOCUserSubmission, error) {
 	d.dbLock.Lock()
 	defer d.dbLock.Unlock()
@@ -128,18 +197,18 @@ func (d *DatabaseInst) GetUserSubmissionById(submissionId int) (*types.AOCUserSu
 	return submissions[0], nil
 }
 
-func (d *DatabaseInst) GetModifiers() ([]*types.AOCSubmissionModifier, error) {
+func (d *DatabaseInst) GetModifiers(year string) ([]*types.AOCSubmissionModifier, error) {
 	d.dbLock.Lock()
 	defer d.dbLock.Unlock()
 
-	return getModifiersByFilter(d.db, "")
+	return getModifiersByFilter(d.db, " year = ? ", year)
 }
 
-func (d *DatabaseInst) GetModifiersByLanguageName(languageName string) (*types.AOCSubmissionModifier, error) {
+func (d *DatabaseInst) GetModifiersByLanguageName(year string, languageName string) (*types.AOCSubmissionModifier, error) {
 	d.dbLock.Lock()
 	defer d.dbLock.Unlock()
 
-	modifiers, err := getModifiersByFilter(d.db, " language_name = ? ", languageName)
+	modifiers, err := getModifiersByFilter(d.db, " year = ? AND language_name = ? ", year, languageName)
 	if err != nil {
 		return nil, err
 	}
@@ -158,9 +227,14 @@ func getUserSubmissionsByFilter(db *sql.DB, filter string, args ...any) ([]*type
 			m.language_name,
 			modifier_dec_percent,
 			user_id,
-			id
+			s.year,
+			id,
+			status,
+			reject_reason,
+			COALESCE(reviewer_id, 0),
+			reviewed_ts
 		FROM modifier_submission AS s 
-		LEFT JOIN modifiers m ON s.language_name = m.language_name`
+		LEFT JOIN modifiers m ON s.language_name = m.language_name AND s.year = m.year`
 	if len(filter) != 0 {
 		query += " WHERE " + filter
 	}
@@ -176,7 +250,8 @@ func getUserSubmissionsByFilter(db *sql.DB, filter string, args ...any) ([]*type
 		rowData := &types.AOCUserSubmission{}
 		var dayString string
 
-		err = rows.Scan(&dayString, &rowData.SubmissionUrl, &rowData.LanguageName, &rowData.ModifierDecPercent, &rowData.AocUserId, &rowData.Id)
+		err = rows.Scan(&dayString, &rowData.SubmissionUrl, &rowData.LanguageName, &rowData.ModifierDecPercent, &rowData.AocUserId, &rowData.Year, &rowData.Id,
+			&rowData.Status, &rowData.RejectReason, &rowData.ReviewerId, &rowData.ReviewedAt)
 		if err != nil {
 			log.Println(err)
 			continue
@@ -218,7 +293,8 @@ func getModifiersByFilter(db *sql.DB, filter string, args ...any) ([]*types.AOCS
 
 	query := `SELECT 
 			language_name,
-			modifier_dec_percent
+			modifier_dec_percent,
+			retired
 		FROM modifiers`
 
 	if len(filter) != 0 {
@@ -234,7 +310,7 @@ func getModifiersByFilter(db *sql.DB, filter string, args ...any) ([]*types.AOCS
 	for rows.Next() {
 		rowData := &types.AOCSubmissionModifier{}
 
-		err := rows.Scan(&rowData.LanguageName, &rowData.ModifierDecPercent)
+		err := rows.Scan(&rowData.LanguageName, &rowData.ModifierDecPercent, &rowData.Retired)
 		if err != nil {
 			log.Println(err)
 			continue
@@ -0,0 +1,132 @@
+package database
+
+import (
+	"log"
+
+	"uocsclub.net/aoclb/internal/types"
+)
+
+func (d *DatabaseInst) GetPrivateLeaderboards() ([]*types.AOCPrivateLeaderboard, error) {
+	d.dbLock.Lock()
+	defer d.dbLock.Unlock()
+
+	return getPrivateLeaderboardsByFilter(d.db, "")
+}
+
+func (d *DatabaseInst) GetPrivateLeaderboardsByYear(year string) ([]*types.AOCPrivateLeaderboard, error) {
+	d.dbLock.Lock()
+	defer d.dbLock.Unlock()
+
+	return getPrivateLeaderboardsByFilter(d.db, " year = ? ", year)
+}
+
+func (d *DatabaseInst) GetPrivateLeaderboard(id string, year string) (*types.AOCPrivateLeaderboard, error) {
+	d.dbLock.Lock()
+	defer d.dbLock.Unlock()
+
+	leaderboards, err := getPrivateLeaderboardsByFilter(d.db, " id = ? AND year = ? ", id, year)
+	if err != nil {
+		return nil, err
+	}
+	if len(leaderboards) == 0 {
+		return nil, nil
+	}
+
+	return leaderboards[0], nil
+}
+
+// StorePrivateLeaderboard adds the leaderboard or updates its name and session
+func (d *DatabaseInst) StorePrivateLeaderboard(leaderboard *types.AOCPrivateLeaderboard) error {
+	d.dbLock.Lock()
+	defer d.dbLock.Unlock()
+
+	return storePrivateLeaderboard(d.db, leaderboard)
+}
+
+func storePrivateLeaderboard(db dbtx, leaderboard *types.AOCPrivateLeaderboard) error {
+	_, err := db.Exec(`
+		INSERT INTO leaderboard (id, year, name, session_env) VALUES (?, ?, ?, ?)
+		ON CONFLICT (id, year) DO UPDATE SET
+		name = excluded.name,
+		session_env = excluded.session_env;
+		`,
+		leaderboard.Id,
+		leaderboard.Year,
+		leaderboard.Name,
+		leaderboard.SessionEnv,
+	)
+
+	return err
+}
+
+func getPrivateLeaderboardsByFilter(db dbtx, filter string, args ...any) ([]*types.AOCPrivateLeaderboard, error) {
+	query := `SELECT
+			id,
+			year,
+			name,
+			session_env
+		FROM leaderboard`
+
+	if len(filter) != 0 {
+		query += " WHERE " + filter
+	}
+	query += " ORDER BY year DESC, name"
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	output := []*types.AOCPrivateLeaderboard{}
+
+	for rows.Next() {
+		rowData := &types.AOCPrivateLeaderboard{}
+
+		err := rows.Scan(&rowData.Id, &rowData.Year, &rowData.Name, &rowData.SessionEnv)
+		if err != nil {
+			log.Println(err)
+			continue
+		}
+
+		output = append(output, rowData)
+	}
+
+	return output, nil
+}
+
+// AdoptLegacyEntries hands the entries stored before there were several private leaderboards over to the
+// board they were fetched from, migration 000005 keeps them under a board without id. The entries the
+// board already has for a year are kept
+func (d *DatabaseInst) AdoptLegacyEntries(leaderboardId string) error {
+	d.dbLock.Lock()
+	defer d.dbLock.Unlock()
+
+	db, err := d.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	_, err = db.Exec("INSERT OR IGNORE INTO leaderboard (id, year, name, session_env) SELECT ?, year, name, session_env FROM leaderboard WHERE id = '';", leaderboardId)
+	if err != nil {
+		db.Rollback()
+		return err
+	}
+
+	_, err = db.Exec("UPDATE OR IGNORE leaderboard_entry SET leaderboard_id = ? WHERE leaderboard_id = '';", leaderboardId)
+	if err != nil {
+		db.Rollback()
+		return err
+	}
+
+	// the entries left are the ones the board already had
+	for _, table := range []string{"leaderboard_entry WHERE leaderboard_id = ''", "leaderboard WHERE id = ''"} {
+		_, err = db.Exec("DELETE FROM " + table + ";")
+		if err != nil {
+			db.Rollback()
+			return err
+		}
+	}
+
+	return db.Commit()
+}
@@ -0,0 +1,110 @@
+package database
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+
+	"github.com/golang-migrate/migrate/v4"
+	"github.com/golang-migrate/migrate/v4/database/sqlite3"
+	"uocsclub.net/aoclb/internal/types"
+)
+
+// testMigratedDatabase runs the setup statements on a database migrated up to the version, then
+// migrates it the rest of the way like InitDatabase
+func testMigratedDatabase(t *testing.T, version uint, setup string) *DatabaseInst {
+	t.Helper()
+
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "aoclb.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	driver, err := sqlite3.WithInstance(db, &sqlite3.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	migrator, err := migrate.NewWithDatabaseInstance("file://../../migrations", "aoclb", driver)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = migrator.Migrate(version)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = db.Exec(setup)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = migrator.Up()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &DatabaseInst{db: db}
+}
+
+func TestAdoptLegacyEntries(t *testing.T) {
+	// alice and bob were on the single board of 2023 and 2024, before boards existed
+	db := testMigratedDatabase(t, 4, `
+		INSERT INTO aoc_user (aoc_id, name) VALUES (1001, 'alice'), (1002, 'bob');
+		INSERT INTO leaderboard_entry (year, user_id, score) VALUES
+			('2023', 1001, 40), ('2023', 1002, 20),
+			('2024', 1001, 10);
+	`)
+
+	// 2024 was fetched again before the entries were handed over
+	leaderboard := &types.AOCPrivateLeaderboard{Id: "123456", Year: "2024", Name: "club", SessionEnv: "SESSION_ID"}
+	err := db.StorePrivateLeaderboard(leaderboard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = db.StoreLeaderboard(leaderboard, types.AOCData{
+		1001: {Year: "2024", User: types.AOCUser{UserId: 1001, Name: "alice"}, Score: 12, Completions: map[int]*types.AOCCompletion{}},
+	}, 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = db.AdoptLegacyEntries("123456")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		year string
+		want map[int]int // score by aoc id
+	}{
+		{"2023", map[int]int{1001: 40, 1002: 20}},
+		{"2024", map[int]int{1001: 12}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.year, func(t *testing.T) {
+			data, err := db.GetLeaderboard(test.year, "123456")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(data) != len(test.want) {
+				t.Fatalf("got %d entries, want %d", len(data), len(test.want))
+			}
+			for id, score := range test.want {
+				if data[id] == nil || data[id].Score != score {
+					t.Errorf("got %+v for %d, want a score of %d", data[id], id, score)
+				}
+			}
+		})
+	}
+
+	boards, err := db.GetPrivateLeaderboards()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, board := range boards {
+		if len(board.Id) == 0 {
+			t.Errorf("the board without id of %s is still there", board.Year)
+		}
+	}
+}
@@ -0,0 +1,259 @@
+package database
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"io"
+	"log"
+
+	"uocsclub.net/aoclb/internal/types"
+)
+
+// StoreSnapshot archives the raw leaderboard payload, payloads are stored once per distinct content
+func (d *DatabaseInst) StoreSnapshot(leaderboard *types.AOCPrivateLeaderboard, fetchedAt int, payload []byte) (*types.AOCLeaderboardSnapshot, error) {
+	hashBytes := sha256.Sum256(payload)
+	hash := hex.EncodeToString(hashBytes[:])
+
+	compressed := &bytes.Buffer{}
+	writer := gzip.NewWriter(compressed)
+	_, err := writer.Write(payload)
+	if err != nil {
+		return nil, err
+	}
+	err = writer.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	d.dbLock.Lock()
+	defer d.dbLock.Unlock()
+
+	db, err := d.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = db.Exec("INSERT INTO snapshot_payload (hash, payload) VALUES (?, ?) ON CONFLICT (hash) DO NOTHING;", hash, compressed.Bytes())
+	if err != nil {
+		db.Rollback()
+		return nil, err
+	}
+
+	row := db.QueryRow(
+		"INSERT INTO leaderboard_snapshot (leaderboard_id, year, fetched_ts, hash) VALUES (?, ?, ?, ?) RETURNING id;",
+		leaderboard.Id,
+		leaderboard.Year,
+		fetchedAt,
+		hash,
+	)
+
+	snapshot := &types.AOCLeaderboardSnapshot{
+		LeaderboardId: leaderboard.Id,
+		Year:          leaderboard.Year,
+		FetchedAt:     fetchedAt,
+		Hash:          hash,
+	}
+	if scanErr := row.Scan(&snapshot.Id); scanErr != nil {
+		db.Rollback()
+		return nil, scanErr
+	}
+
+	db.Commit()
+
+	return snapshot, nil
+}
+
+// GetSnapshots returns every archived snapshot in the order they were fetched
+func (d *DatabaseInst) GetSnapshots() ([]*types.AOCLeaderboardSnapshot, error) {
+	d.dbLock.Lock()
+	defer d.dbLock.Unlock()
+
+	return getSnapshotsByFilter(d.db, "")
+}
+
+func (d *DatabaseInst) GetSnapshotsByYear(year string) ([]*types.AOCLeaderboardSnapshot, error) {
+	d.dbLock.Lock()
+	defer d.dbLock.Unlock()
+
+	return getSnapshotsByFilter(d.db, " year = ? ", year)
+}
+
+// GetSnapshotPayload returns the uncompressed payload of the snapshot
+func (d *DatabaseInst) GetSnapshotPayload(snapshot *types.AOCLeaderboardSnapshot) ([]byte, error) {
+	d.dbLock.Lock()
+	defer d.dbLock.Unlock()
+
+	return getSnapshotPayload(d.db, snapshot)
+}
+
+func getSnapshotPayload(db dbtx, snapshot *types.AOCLeaderboardSnapshot) ([]byte, error) {
+	row := db.QueryRow("SELECT payload FROM snapshot_payload WHERE hash = ?;", snapshot.Hash)
+
+	var compressed []byte
+	err := row.Scan(&compressed)
+	if err != nil {
+		return nil, err
+	}
+
+	reader, err := gzip.NewReader(bytes.NewReader(compressed))
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	return io.ReadAll(reader)
+}
+
+// ReplaySnapshots rebuilds the leaderboards from the archived snapshots, in the order they were fetched.
+// parse decodes a payload, the snapshots it fails on are skipped. The clear and the rebuild are a single
+// transaction, the database is left as it was if a snapshot can't be stored. Returns how many snapshots
+// were replayed out of how many
+func (d *DatabaseInst) ReplaySnapshots(parse func(payload []byte) (*types.AOCEvent, types.AOCData, error)) (int, int, error) {
+	d.dbLock.Lock()
+	defer d.dbLock.Unlock()
+
+	db, err := d.db.Begin()
+	if err != nil {
+		return 0, 0, err
+	}
+
+	replayed, total, err := replaySnapshots(db, parse)
+	if err != nil {
+		db.Rollback()
+		return 0, 0, err
+	}
+
+	err = db.Commit()
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return replayed, total, nil
+}
+
+func replaySnapshots(db *sql.Tx, parse func(payload []byte) (*types.AOCEvent, types.AOCData, error)) (int, int, error) {
+	snapshots, err := getSnapshotsByFilter(db, "")
+	if err != nil {
+		return 0, 0, err
+	}
+
+	err = clearDerivedLeaderboardData(db)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	replayed := 0
+	for _, snapshot := range snapshots {
+		payload, err := getSnapshotPayload(db, snapshot)
+		if err != nil {
+			return 0, 0, err
+		}
+
+		event, data, err := parse(payload)
+		if err != nil {
+			log.Printf("WARN: Skipping snapshot %d: %s\n", snapshot.Id, err)
+			continue
+		}
+
+		leaderboards, err := getPrivateLeaderboardsByFilter(db, " id = ? AND year = ? ", snapshot.LeaderboardId, snapshot.Year)
+		if err != nil {
+			return 0, 0, err
+		}
+		leaderboard := &types.AOCPrivateLeaderboard{
+			Id:         snapshot.LeaderboardId,
+			Year:       snapshot.Year,
+			Name:       snapshot.LeaderboardId,
+			SessionEnv: "SESSION_ID",
+		}
+		if len(leaderboards) != 0 {
+			leaderboard = leaderboards[0]
+		} else {
+			// the leaderboard was removed from the config since, keep its data reachable
+			err = storePrivateLeaderboard(db, leaderboard)
+			if err != nil {
+				return 0, 0, err
+			}
+		}
+
+		err = storeEvent(db, event)
+		if err != nil {
+			return 0, 0, err
+		}
+
+		_, err = storeLeaderboard(db, leaderboard, data, snapshot.FetchedAt)
+		if err != nil {
+			return 0, 0, err
+		}
+		replayed += 1
+	}
+
+	return replayed, len(snapshots), nil
+}
+
+// clearDerivedLeaderboardData removes what gets rebuilt from the archived snapshots. Only the boards and years
+// that have snapshots are cleared, what was stored without any archive can't be rebuilt and is kept. Users are
+// kept since they hold the identity links, the events keep their scoring mode, only what AOC sent is reset
+func clearDerivedLeaderboardData(db *sql.Tx) error {
+	queries := []string{
+		// stars belong to the year, every board the member is on lists all of them
+		`DELETE FROM star_completion WHERE EXISTS (
+			SELECT 1 FROM leaderboard_entry AS e
+			INNER JOIN leaderboard_snapshot AS s ON s.leaderboard_id = e.leaderboard_id AND s.year = e.year
+			WHERE e.year = star_completion.year AND e.user_id = star_completion.user_id
+		);`,
+		`DELETE FROM leaderboard_entry WHERE EXISTS (
+			SELECT 1 FROM leaderboard_snapshot AS s
+			WHERE s.leaderboard_id = leaderboard_entry.leaderboard_id AND s.year = leaderboard_entry.year
+		);`,
+		"UPDATE event SET num_days = 0, day1_ts = 0 WHERE year IN (SELECT year FROM leaderboard_snapshot);",
+	}
+
+	for _, query := range queries {
+		_, err := db.Exec(query)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func getSnapshotsByFilter(db dbtx, filter string, args ...any) ([]*types.AOCLeaderboardSnapshot, error) {
+	query := `SELECT
+			id,
+			leaderboard_id,
+			year,
+			fetched_ts,
+			hash
+		FROM leaderboard_snapshot`
+
+	if len(filter) != 0 {
+		query += " WHERE " + filter
+	}
+	query += " ORDER BY fetched_ts, id"
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	output := []*types.AOCLeaderboardSnapshot{}
+
+	for rows.Next() {
+		rowData := &types.AOCLeaderboardSnapshot{}
+
+		err := rows.Scan(&rowData.Id, &rowData.LeaderboardId, &rowData.Year, &rowData.FetchedAt, &rowData.Hash)
+		if err != nil {
+			log.Println(err)
+			continue
+		}
+
+		output = append(output, rowData)
+	}
+
+	return output, nil
+}
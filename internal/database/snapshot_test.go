@@ -0,0 +1,86 @@
+package database
+
+import (
+	"testing"
+
+	"uocsclub.net/aoclb/internal/types"
+)
+
+func TestReplaySnapshotsKeepsUnarchivedYears(t *testing.T) {
+	db := testDatabase(t)
+
+	// 2023 was stored before snapshots were archived, 2024 has a snapshot
+	entry := func(year string, score int, day int) types.AOCData {
+		return types.AOCData{
+			1001: {Year: year, User: types.AOCUser{UserId: 1001, Name: "alice"}, Score: score, Completions: map[int]*types.AOCCompletion{
+				day: {Star1: true, Star1TS: 1000},
+			}},
+		}
+	}
+	for _, year := range []string{"2023", "2024"} {
+		leaderboard := &types.AOCPrivateLeaderboard{Id: "123456", Year: year, Name: "club", SessionEnv: "SESSION_ID"}
+		err := db.StorePrivateLeaderboard(leaderboard)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = db.StoreEvent(&types.AOCEvent{Year: year, NumDays: 25, Day1Timestamp: 1})
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = db.StoreLeaderboard(leaderboard, entry(year, 10, 1), 1000)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if year == "2024" {
+			_, err = db.StoreSnapshot(leaderboard, 1000, []byte("2024"))
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	// the snapshot has alice on day 2 instead
+	replayed, total, err := db.ReplaySnapshots(func(payload []byte) (*types.AOCEvent, types.AOCData, error) {
+		return &types.AOCEvent{Year: "2024", NumDays: 25, Day1Timestamp: 2}, entry("2024", 20, 2), nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if replayed != 1 || total != 1 {
+		t.Fatalf("replayed %d/%d snapshots, want 1/1", replayed, total)
+	}
+
+	tests := []struct {
+		year      string
+		wantScore int
+		wantDay   int
+		wantDay1  int
+	}{
+		{"2023", 10, 1, 1},
+		{"2024", 20, 2, 2},
+	}
+
+	for _, test := range tests {
+		t.Run(test.year, func(t *testing.T) {
+			data, err := db.GetLeaderboard(test.year, "123456")
+			if err != nil {
+				t.Fatal(err)
+			}
+			alice := data[1001]
+			if alice == nil || alice.Score != test.wantScore {
+				t.Fatalf("got %+v, want alice with a score of %d", alice, test.wantScore)
+			}
+			if len(alice.Completions) != 1 || alice.Completions[test.wantDay] == nil {
+				t.Errorf("got completions %v, want day %d only", alice.Completions, test.wantDay)
+			}
+
+			event, err := db.GetEvent(test.year)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if event.Day1Timestamp != test.wantDay1 {
+				t.Errorf("got day 1 at %d, want %d", event.Day1Timestamp, test.wantDay1)
+			}
+		})
+	}
+}
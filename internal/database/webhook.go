@@ -0,0 +1,269 @@
+package database
+
+import (
+	"database/sql"
+	"strings"
+
+	"uocsclub.net/aoclb/internal/types"
+)
+
+func (d *DatabaseInst) CreateWebhook(webhook *types.AOCWebhook) error {
+	d.dbLock.Lock()
+	defer d.dbLock.Unlock()
+
+	result, err := d.db.Exec(
+		"INSERT INTO webhook (url, secret, events, active, created_ts) VALUES (?, ?, ?, ?, ?);",
+		webhook.Url,
+		webhook.Secret,
+		strings.Join(webhook.Events, ","),
+		webhook.Active,
+		webhook.CreatedAt,
+	)
+	if err != nil {
+		return err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+	webhook.Id = int(id)
+
+	return nil
+}
+
+// UpdateWebhook saves the url, events and active flag of the webhook, the secret never changes
+func (d *DatabaseInst) UpdateWebhook(webhook *types.AOCWebhook) error {
+	d.dbLock.Lock()
+	defer d.dbLock.Unlock()
+
+	_, err := d.db.Exec(
+		"UPDATE webhook SET url = ?, events = ?, active = ? WHERE id = ?;",
+		webhook.Url,
+		strings.Join(webhook.Events, ","),
+		webhook.Active,
+		webhook.Id,
+	)
+	return err
+}
+
+// DeleteWebhook removes the webhook along with its deliveries
+func (d *DatabaseInst) DeleteWebhook(id int) error {
+	d.dbLock.Lock()
+	defer d.dbLock.Unlock()
+
+	db, err := d.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	_, err = db.Exec("DELETE FROM webhook_delivery WHERE webhook_id = ?;", id)
+	if err != nil {
+		db.Rollback()
+		return err
+	}
+
+	_, err = db.Exec("DELETE FROM webhook WHERE id = ?;", id)
+	if err != nil {
+		db.Rollback()
+		return err
+	}
+
+	return db.Commit()
+}
+
+func (d *DatabaseInst) GetWebhooks() ([]*types.AOCWebhook, error) {
+	d.dbLock.Lock()
+	defer d.dbLock.Unlock()
+
+	return getWebhooksByFilter(d.db, "")
+}
+
+func (d *DatabaseInst) GetWebhook(id int) (*types.AOCWebhook, error) {
+	d.dbLock.Lock()
+	defer d.dbLock.Unlock()
+
+	webhooks, err := getWebhooksByFilter(d.db, "id = ?", id)
+	if err != nil {
+		return nil, err
+	}
+	if len(webhooks) == 0 {
+		return nil, nil
+	}
+
+	return webhooks[0], nil
+}
+
+func getWebhooksByFilter(db *sql.DB, filter string, args ...any) ([]*types.AOCWebhook, error) {
+	query := "SELECT id, url, secret, events, active, created_ts FROM webhook"
+	if len(filter) != 0 {
+		query += " WHERE " + filter
+	}
+	query += " ORDER BY id;"
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	output := []*types.AOCWebhook{}
+	for rows.Next() {
+		webhook := &types.AOCWebhook{}
+		var events string
+		err = rows.Scan(&webhook.Id, &webhook.Url, &webhook.Secret, &events, &webhook.Active, &webhook.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		webhook.Events = strings.FieldsFunc(events, func(r rune) bool { return r == ',' })
+		output = append(output, webhook)
+	}
+
+	return output, rows.Err()
+}
+
+// QueueWebhookDelivery adds a pending delivery, it is sent on the next attempt after NextAttemptAt
+func (d *DatabaseInst) QueueWebhookDelivery(delivery *types.AOCWebhookDelivery) error {
+	d.dbLock.Lock()
+	defer d.dbLock.Unlock()
+
+	result, err := d.db.Exec(
+		"INSERT INTO webhook_delivery (webhook_id, event, payload, status, next_attempt_ts, created_ts) VALUES (?, ?, ?, ?, ?, ?);",
+		delivery.WebhookId,
+		delivery.Event,
+		delivery.Payload,
+		types.DeliveryPending,
+		delivery.NextAttemptAt,
+		delivery.CreatedAt,
+	)
+	if err != nil {
+		return err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+	delivery.Id = int(id)
+	delivery.Status = types.DeliveryPending
+
+	return nil
+}
+
+// GetDueWebhookDeliveries returns the pending deliveries whose next attempt is before now, oldest first.
+// The deliveries of inactive webhooks wait for them to be activated again
+func (d *DatabaseInst) GetDueWebhookDeliveries(now int, limit int) ([]*types.AOCWebhookDelivery, error) {
+	d.dbLock.Lock()
+	defer d.dbLock.Unlock()
+
+	return getWebhookDeliveriesByFilter(
+		d.db,
+		"status = ? AND next_attempt_ts <= ? AND webhook_id IN (SELECT id FROM webhook WHERE active = 1) ORDER BY id LIMIT ?",
+		types.DeliveryPending, now, limit,
+	)
+}
+
+// GetWebhookDeliveries is the delivery log of the webhook, newest first
+func (d *DatabaseInst) GetWebhookDeliveries(webhookId int, limit int) ([]*types.AOCWebhookDelivery, error) {
+	d.dbLock.Lock()
+	defer d.dbLock.Unlock()
+
+	return getWebhookDeliveriesByFilter(d.db, "webhook_id = ? ORDER BY id DESC LIMIT ?", webhookId, limit)
+}
+
+// StoreWebhookAttempt saves the outcome of an attempt at sending the delivery
+func (d *DatabaseInst) StoreWebhookAttempt(delivery *types.AOCWebhookDelivery) error {
+	d.dbLock.Lock()
+	defer d.dbLock.Unlock()
+
+	_, err := d.db.Exec(`
+		UPDATE webhook_delivery SET
+		status = ?,
+		attempts = ?,
+		next_attempt_ts = ?,
+		response_status = ?,
+		error = ?,
+		delivered_ts = ?
+		WHERE id = ?;
+		`,
+		delivery.Status,
+		delivery.Attempts,
+		delivery.NextAttemptAt,
+		delivery.ResponseStatus,
+		delivery.Error,
+		delivery.DeliveredAt,
+		delivery.Id,
+	)
+	return err
+}
+
+// RetryWebhookDelivery sends a delivery of the webhook again on the next attempt, with all its attempts back
+func (d *DatabaseInst) RetryWebhookDelivery(webhookId int, id int, now int) error {
+	d.dbLock.Lock()
+	defer d.dbLock.Unlock()
+
+	_, err := d.db.Exec(
+		"UPDATE webhook_delivery SET status = ?, attempts = 0, next_attempt_ts = ? WHERE id = ? AND webhook_id = ?;",
+		types.DeliveryPending, now, id, webhookId,
+	)
+	return err
+}
+
+// PruneWebhookDeliveries forgets the deliveries done before the timestamp, pending ones are kept
+func (d *DatabaseInst) PruneWebhookDeliveries(before int) error {
+	d.dbLock.Lock()
+	defer d.dbLock.Unlock()
+
+	_, err := d.db.Exec("DELETE FROM webhook_delivery WHERE status != ? AND created_ts < ?;", types.DeliveryPending, before)
+	return err
+}
+
+// getWebhookDeliveriesByFilter takes the ordering in the filter, the log and the queue are ordered differently
+func getWebhookDeliveriesByFilter(db *sql.DB, filter string, args ...any) ([]*types.AOCWebhookDelivery, error) {
+	query := `SELECT
+			id,
+			webhook_id,
+			event,
+			payload,
+			status,
+			attempts,
+			next_attempt_ts,
+			response_status,
+			error,
+			created_ts,
+			delivered_ts
+		FROM webhook_delivery`
+	if len(filter) != 0 {
+		query += " WHERE " + filter
+	}
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	output := []*types.AOCWebhookDelivery{}
+	for rows.Next() {
+		delivery := &types.AOCWebhookDelivery{}
+		err = rows.Scan(
+			&delivery.Id,
+			&delivery.WebhookId,
+			&delivery.Event,
+			&delivery.Payload,
+			&delivery.Status,
+			&delivery.Attempts,
+			&delivery.NextAttemptAt,
+			&delivery.ResponseStatus,
+			&delivery.Error,
+			&delivery.CreatedAt,
+			&delivery.DeliveredAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		output = append(output, delivery)
+	}
+
+	return output, rows.Err()
+}
@@ -0,0 +1,89 @@
+package events
+
+import "uocsclub.net/aoclb/internal/types"
+
+// The data of the events leaving the app, the JSON is what the webhooks receive
+
+type User struct {
+	AocId int    `json:"aoc_id"`
+	Name  string `json:"name"`
+}
+
+// StarEarnedData is the data of StarEarned, a star seen for the first time by a fetch
+type StarEarnedData struct {
+	User        User   `json:"user"`
+	Leaderboard string `json:"leaderboard"` // display name of the private leaderboard
+	Day         int    `json:"day"`
+	Star        int    `json:"star"`
+	CompletedAt int    `json:"completed_at"` // unix timestamp
+}
+
+// RankChangedData is the data of RankChanged and RankFirst, only members moving up are published,
+// the members they overtook moved down. Members new to the leaderboard only get a RankFirst, with an old rank of 0
+type RankChangedData struct {
+	User        User   `json:"user"`
+	Leaderboard string `json:"leaderboard"`
+	OldRank     int    `json:"old_rank"`
+	NewRank     int    `json:"new_rank"`
+	Score       int    `json:"score"`
+	Overtaken   []User `json:"overtaken"`
+}
+
+// SubmissionData is the data of SubmissionCreated and SubmissionReviewed
+type SubmissionData struct {
+	Id           int                       `json:"id"`
+	User         User                      `json:"user"`
+	Year         string                    `json:"year"`
+	Day          int                       `json:"day"`
+	Star         int                       `json:"star"`
+	Language     string                    `json:"language"`
+	Modifier     float64                   `json:"modifier"` // percentage
+	Url          string                    `json:"url"`
+	Status       types.AOCSubmissionStatus `json:"status"`
+	RejectReason string                    `json:"reject_reason,omitempty"`
+	Reviewer     *User                     `json:"reviewer,omitempty"` // nil until reviewed
+}
+
+func NewUser(user *types.AOCUser) User {
+	return User{AocId: user.UserId, Name: user.Name}
+}
+
+// NewSubmissionData describes the submission of the user, the reviewer is left to the caller
+func NewSubmissionData(user *types.AOCUser, submission *types.AOCUserSubmission) *SubmissionData {
+	return &SubmissionData{
+		Id:           submission.Id,
+		User:         User{AocId: submission.AocUserId, Name: user.Name},
+		Year:         submission.Year,
+		Day:          submission.Date,
+		Star:         submission.Star,
+		Language:     submission.LanguageName,
+		Modifier:     float64(submission.ModifierDecPercent) / 10,
+		Url:          submission.SubmissionUrl,
+		Status:       submission.Status,
+		RejectReason: submission.RejectReason,
+	}
+}
+
+// Payload is the JSON body of the webhook deliveries, the data depends on the type
+type Payload struct {
+	Type          EventType `json:"type"`
+	Year          string    `json:"year,omitempty"`
+	LeaderboardId string    `json:"leaderboard_id,omitempty"`
+	CreatedAt     int       `json:"created_at"` // unix timestamp
+	Data          any       `json:"data"`
+}
+
+func NewPayload(event Event, createdAt int) *Payload {
+	return &Payload{
+		Type:          event.Type,
+		Year:          event.Year,
+		LeaderboardId: event.LeaderboardId,
+		CreatedAt:     createdAt,
+		Data:          event.Data,
+	}
+}
+
+// PingData is the data of Ping
+type PingData struct {
+	WebhookId int `json:"webhook_id"`
+}
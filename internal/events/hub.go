@@ -0,0 +1,116 @@
+package events
+
+import (
+	"log"
+	"slices"
+	"sync"
+)
+
+type EventType string
+
+const (
+	// LeaderboardUpdated is published when storing a fetch changed a private leaderboard,
+	// the data is the *types.AOCLeaderboardChanges
+	LeaderboardUpdated EventType = "leaderboard.updated"
+	// the data of these is described in data.go
+	StarEarned         EventType = "star.earned"
+	RankChanged        EventType = "rank.changed"
+	RankFirst          EventType = "rank.first"
+	SubmissionCreated  EventType = "submission.created"
+	SubmissionReviewed EventType = "submission.reviewed"
+	// Ping is never published, it is what the admins send to test a webhook
+	Ping EventType = "ping"
+)
+
+type Event struct {
+	Type          EventType
+	Year          string
+	LeaderboardId string // empty for events that aren't about a single private leaderboard
+	Data          any
+}
+
+// Hub fans the events out to every subscriber and handler, it is safe to use from any goroutine
+type Hub struct {
+	lock        sync.Mutex
+	subscribers map[chan Event][]EventType
+	handlers    []handler
+}
+
+type handler struct {
+	handle     func(Event)
+	eventTypes []EventType
+}
+
+func NewHub() *Hub {
+	return &Hub{
+		subscribers: map[chan Event][]EventType{},
+	}
+}
+
+// Subscribe returns a channel receiving the events of the types published from now on, every event
+// without any type. Up to buffer events are kept for a slow subscriber. The returned func unsubscribes
+// and closes the channel
+func (h *Hub) Subscribe(buffer int, eventTypes ...EventType) (<-chan Event, func()) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	ch := make(chan Event, buffer)
+	h.subscribers[ch] = eventTypes
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			h.lock.Lock()
+			defer h.lock.Unlock()
+
+			delete(h.subscribers, ch)
+			close(ch)
+		})
+	}
+}
+
+// Handle calls handle for the events of the types published from now on, every event without any type.
+// Unlike a subscriber it never misses an event, it is called by Publish before it returns so it holds
+// up the publisher. It is for what can't be lost, like the webhook deliveries
+func (h *Hub) Handle(handle func(Event), eventTypes ...EventType) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	h.handlers = append(h.handlers, handler{handle: handle, eventTypes: eventTypes})
+}
+
+// Publish calls the handlers and doesn't block otherwise, subscribers whose buffer is full miss the
+// event, the drop is logged
+func (h *Hub) Publish(event Event) {
+	h.lock.Lock()
+
+	handlers := []handler{}
+	for _, handler := range h.handlers {
+		if matches(handler.eventTypes, event.Type) {
+			handlers = append(handlers, handler)
+		}
+	}
+
+	for ch, eventTypes := range h.subscribers {
+		if !matches(eventTypes, event.Type) {
+			continue
+		}
+
+		select {
+		case ch <- event:
+		default:
+			log.Printf("WARN: Dropped a %s event, a subscriber is %d events behind\n", event.Type, cap(ch))
+		}
+	}
+
+	// unlocked since the handlers can publish events of their own
+	h.lock.Unlock()
+
+	for _, handler := range handlers {
+		handler.handle(event)
+	}
+}
+
+func matches(eventTypes []EventType, eventType EventType) bool {
+	return len(eventTypes) == 0 || slices.Contains(eventTypes, eventType)
+}
@@ -1,8 +1,6 @@
 package fetcher
 
 import (
-	"log"
-
 	"uocsclub.net/aoclb/internal/types"
 )
 
@@ -12,6 +10,7 @@ type AOCResponseLeaderboard struct {
 	StartTimestamp int                              `json:"day1_ts"`
 	Year           string                           `json:"event"`
 	Members        map[string]*AOCLeaderboardMember `json:"members,omitempty"`
+	Raw            []byte                           `json:"-"` // payload as it was received from AOC
 }
 
 type AOCLeaderboardMember struct {
@@ -31,7 +30,7 @@ type AOCLeaderboardDayCompletion struct {
 
 type AOCLeaderboardStarCompletion struct {
 	Index  int `json:"star_index"`
-	StarTS int `json:"get_star_Ts"`
+	StarTS int `json:"get_star_ts"`
 }
 
 func (l *AOCResponseLeaderboard) ToAOCData() types.AOCData {
@@ -53,10 +52,18 @@ func (l *AOCResponseLeaderboard) ToAOCData() types.AOCData {
 		}
 
 		for id, day := range member.DayCompletions {
-			entry.Completions[id] = &types.AOCCompletion{
-				Star1: day.Star1 != nil,
-				Star2: day.Star2 != nil,
+			completion := &types.AOCCompletion{}
+			if day.Star1 != nil {
+				completion.Star1 = true
+				completion.Star1TS = day.Star1.StarTS
+				completion.Star1Index = day.Star1.Index
+			}
+			if day.Star2 != nil {
+				completion.Star2 = true
+				completion.Star2TS = day.Star2.StarTS
+				completion.Star2Index = day.Star2.Index
 			}
+			entry.Completions[id] = completion
 		}
 
 		data[member.Id] = entry
@@ -65,16 +72,14 @@ func (l *AOCResponseLeaderboard) ToAOCData() types.AOCData {
 	return data
 }
 
-// haha hack, eventually this should be info that comes from AOC (we literally get it lol)
-// however it requires much more work to link it all together than just hardcode
-func EstimateAOCDayCount(year string) int {
-	switch year {
-	case "2026":
-		log.Fatalln("Hey big boy, if you're still using this in a year, nice, but also fix this")
-		return 69 // shutup go compiler, this will never happen, idc that it doesn't return
-	case "2025":
-		return 12
-	default:
-		return 25
+func (l *AOCResponseLeaderboard) ToAOCEvent() *types.AOCEvent {
+	if l == nil {
+		return nil
+	}
+
+	return &types.AOCEvent{
+		Year:          l.Year,
+		NumDays:       l.NumDays,
+		Day1Timestamp: l.StartTimestamp,
 	}
 }
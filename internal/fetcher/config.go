@@ -1,7 +1,21 @@
 package fetcher
 
+import "time"
+
+const (
+	DefaultAOCBaseUrl = "https://adventofcode.com"
+	// AOC asks for its private leaderboard API not to be fetched more than once every 15 minutes
+	DefaultMinInterval = 15 * time.Minute
+	DefaultMaxBackoff  = 4 * time.Hour
+	DefaultUserAgent   = "github.com/uocsclub/aoc-lb"
+)
+
 type AOCFetcherConfig struct {
+	BaseUrl       string // defaults to DefaultAOCBaseUrl, point it at cmd/aocstub for offline dev
 	SessionCookie string
 	LeaderboardId string
 	Year          string
+	UserAgent     string        // defaults to DefaultUserAgent, AOC asks for it to identify who is fetching
+	MinInterval   time.Duration // defaults to DefaultMinInterval
+	MaxBackoff    time.Duration // defaults to DefaultMaxBackoff
 }
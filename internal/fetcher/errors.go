@@ -0,0 +1,36 @@
+package fetcher
+
+import (
+	"errors"
+
+	"uocsclub.net/aoclb/internal/types"
+)
+
+var (
+	// ErrNotModified is returned when AOC reports that the leaderboard didn't change since the last fetch
+	ErrNotModified = errors.New("AOC leaderboard not modified")
+	// ErrSessionExpired is returned when AOC sends us to the login page instead of the leaderboard
+	ErrSessionExpired = errors.New("AOC session expired or invalid")
+	ErrNotFound       = errors.New("AOC leaderboard not found")
+	ErrRateLimited    = errors.New("AOC rate limited the request")
+	ErrUnavailable    = errors.New("AOC is unavailable")
+	ErrMalformed      = errors.New("AOC returned a malformed leaderboard")
+)
+
+// ErrorKind classifies a fetch error so it can be stored and shown without keeping the error around
+func ErrorKind(err error) types.AOCFetchErrorKind {
+	switch {
+	case err == nil, errors.Is(err, ErrNotModified):
+		return types.FetchErrorNone
+	case errors.Is(err, ErrSessionExpired):
+		return types.FetchErrorSessionExpired
+	case errors.Is(err, ErrNotFound):
+		return types.FetchErrorNotFound
+	case errors.Is(err, ErrRateLimited):
+		return types.FetchErrorRateLimited
+	case errors.Is(err, ErrMalformed):
+		return types.FetchErrorMalformed
+	default:
+		return types.FetchErrorUnavailable
+	}
+}
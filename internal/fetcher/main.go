@@ -4,19 +4,94 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"log"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
 	"uocsclub.net/aoclb/internal/types"
 )
 
-func FetchAOCLeaderboard(config *AOCFetcherConfig) (types.AOCData, error) {
+// NextFetch returns when the leaderboard can be fetched again. Fetches are spaced by the
+// minimum interval, doubled for every consecutive failure up to the maximum backoff, and never
+// before the Retry-After of a rate limited attempt
+func NextFetch(config *AOCFetcherConfig, status *types.AOCFetchStatus) time.Time {
+	if status == nil || status.LastAttempt == 0 {
+		return time.Time{}
+	}
+
+	minInterval := config.MinInterval
+	if minInterval <= 0 {
+		minInterval = DefaultMinInterval
+	}
+	maxBackoff := config.MaxBackoff
+	if maxBackoff <= 0 {
+		maxBackoff = DefaultMaxBackoff
+	}
+	maxBackoff = max(maxBackoff, minInterval)
+
+	interval := minInterval
+	for range status.ConsecutiveFailures {
+		interval *= 2
+		if interval >= maxBackoff {
+			interval = maxBackoff
+			break
+		}
+	}
+
+	next := time.Unix(int64(status.LastAttempt), 0).Add(interval)
+	if retryAfter := time.Unix(int64(status.RetryAfter), 0); status.RetryAfter != 0 && retryAfter.After(next) {
+		return retryAfter
+	}
+
+	return next
+}
+
+// FetchAOCLeaderboard fetches the private leaderboard and records the attempt in status
+func FetchAOCLeaderboard(config *AOCFetcherConfig, status *types.AOCFetchStatus) (*AOCResponseLeaderboard, error) {
+	status.LastAttempt = int(time.Now().Unix())
+	status.RetryAfter = 0
+
+	leaderboard, err := fetchAOCLeaderboard(config, status)
+	if err != nil && !errors.Is(err, ErrNotModified) {
+		status.ConsecutiveFailures += 1
+		status.Error = err.Error()
+		status.ErrorKind = ErrorKind(err)
+		return nil, err
+	}
+
+	status.LastSuccess = status.LastAttempt
+	status.ConsecutiveFailures = 0
+	status.Error = ""
+	status.ErrorKind = types.FetchErrorNone
 
-	client := &http.Client{}
+	return leaderboard, err
+}
+
+func fetchAOCLeaderboard(config *AOCFetcherConfig, status *types.AOCFetchStatus) (*AOCResponseLeaderboard, error) {
+	client := &http.Client{
+		Timeout: 30 * time.Second,
+		// AOC redirects to the leaderboard page when the session isn't valid, we want to see that redirect
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	baseUrl := config.BaseUrl
+	if len(baseUrl) == 0 {
+		baseUrl = DefaultAOCBaseUrl
+	}
+
+	userAgent := config.UserAgent
+	if len(userAgent) == 0 {
+		userAgent = DefaultUserAgent
+	}
 
 	req, err := http.NewRequest(
 		"GET",
-		fmt.Sprintf("https://adventofcode.com/%s/leaderboard/private/view/%s.json", config.Year, config.LeaderboardId),
+		fmt.Sprintf("%s/%s/leaderboard/private/view/%s.json", strings.TrimSuffix(baseUrl, "/"), config.Year, config.LeaderboardId),
 		nil,
 	)
 
@@ -25,6 +100,14 @@ func FetchAOCLeaderboard(config *AOCFetcherConfig) (types.AOCData, error) {
 		return nil, errors.New("Failed to fetch AOC")
 	}
 
+	req.Header.Set("User-Agent", userAgent)
+	if len(status.ETag) != 0 {
+		req.Header.Set("If-None-Match", status.ETag)
+	}
+	if len(status.LastModified) != 0 {
+		req.Header.Set("If-Modified-Since", status.LastModified)
+	}
+
 	req.AddCookie(&http.Cookie{
 		Name:  "session",
 		Value: config.SessionCookie,
@@ -37,19 +120,97 @@ func FetchAOCLeaderboard(config *AOCFetcherConfig) (types.AOCData, error) {
 
 	resp, err := client.Do(req)
 	if err != nil {
-		log.Printf("ERROR: %s\n", err)
-		return nil, errors.New("Failed to fetch AOC")
+		status.StatusCode = 0
+		return nil, fmt.Errorf("%w: %s", ErrUnavailable, err)
 	}
 	defer resp.Body.Close()
 
-	decoder := json.NewDecoder(resp.Body)
+	status.StatusCode = resp.StatusCode
 
-	requestData := AOCResponseLeaderboard{}
-	err = decoder.Decode(&requestData)
+	err = checkAOCResponse(resp)
+	if errors.Is(err, ErrRateLimited) {
+		if delay, ok := parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()); ok {
+			status.RetryAfter = int(time.Now().Add(delay).Unix())
+		}
+	}
 	if err != nil {
-		log.Printf("ERROR: %s\n", err)
-		return nil, errors.New("Failed to fetch AOC")
+		return nil, err
+	}
+
+	raw, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrUnavailable, err)
+	}
+
+	requestData, err := ParseAOCLeaderboard(raw)
+	if err != nil {
+		return nil, err
+	}
+	if requestData.Year != config.Year {
+		return nil, fmt.Errorf("%w: got event %q instead of %q", ErrMalformed, requestData.Year, config.Year)
+	}
+
+	status.ETag = resp.Header.Get("ETag")
+	status.LastModified = resp.Header.Get("Last-Modified")
+
+	return requestData, nil
+}
+
+// ParseAOCLeaderboard decodes a private leaderboard payload, the payload is kept in Raw so it can be archived
+func ParseAOCLeaderboard(raw []byte) (*AOCResponseLeaderboard, error) {
+	requestData := &AOCResponseLeaderboard{}
+	err := json.Unmarshal(raw, requestData)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrMalformed, err)
+	}
+
+	requestData.Raw = raw
+
+	return requestData, nil
+}
+
+// parseRetryAfter reads a Retry-After header, either a number of seconds or an http date
+func parseRetryAfter(header string, now time.Time) (time.Duration, bool) {
+	header = strings.TrimSpace(header)
+	if len(header) == 0 {
+		return 0, false
+	}
+
+	if seconds, err := strconv.Atoi(header); err == nil {
+		return max(time.Duration(seconds)*time.Second, 0), true
+	}
+
+	date, err := http.ParseTime(header)
+	if err != nil {
+		return 0, false
+	}
+
+	return max(date.Sub(now), 0), true
+}
+
+// checkAOCResponse classifies responses that don't contain the leaderboard
+func checkAOCResponse(resp *http.Response) error {
+	switch {
+	case resp.StatusCode == http.StatusNotModified:
+		return ErrNotModified
+	case resp.StatusCode >= 300 && resp.StatusCode < 400:
+		return fmt.Errorf("%w: redirected to %s", ErrSessionExpired, resp.Header.Get("Location"))
+	case resp.StatusCode == http.StatusBadRequest,
+		resp.StatusCode == http.StatusUnauthorized,
+		resp.StatusCode == http.StatusForbidden:
+		return fmt.Errorf("%w: status %d", ErrSessionExpired, resp.StatusCode)
+	case resp.StatusCode == http.StatusNotFound:
+		return ErrNotFound
+	case resp.StatusCode == http.StatusTooManyRequests:
+		return ErrRateLimited
+	case resp.StatusCode != http.StatusOK:
+		return fmt.Errorf("%w: status %d", ErrUnavailable, resp.StatusCode)
+	}
+
+	// the login page is served with a 200
+	if strings.HasPrefix(resp.Header.Get("Content-Type"), "text/html") {
+		return fmt.Errorf("%w: got an html page", ErrSessionExpired)
 	}
 
-	return requestData.ToAOCData(), nil
+	return nil
 }
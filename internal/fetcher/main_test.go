@@ -0,0 +1,153 @@
+package fetcher
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"uocsclub.net/aoclb/internal/types"
+)
+
+func TestCheckAOCResponse(t *testing.T) {
+	tests := []struct {
+		name        string
+		status      int
+		contentType string
+		want        error
+		kind        types.AOCFetchErrorKind
+	}{
+		{"ok", http.StatusOK, "application/json", nil, types.FetchErrorNone},
+		{"not modified", http.StatusNotModified, "", ErrNotModified, types.FetchErrorNone},
+		{"found", http.StatusFound, "", ErrSessionExpired, types.FetchErrorSessionExpired},
+		{"see other", http.StatusSeeOther, "", ErrSessionExpired, types.FetchErrorSessionExpired},
+		{"temporary redirect", http.StatusTemporaryRedirect, "", ErrSessionExpired, types.FetchErrorSessionExpired},
+		{"bad request", http.StatusBadRequest, "", ErrSessionExpired, types.FetchErrorSessionExpired},
+		{"unauthorized", http.StatusUnauthorized, "", ErrSessionExpired, types.FetchErrorSessionExpired},
+		{"forbidden", http.StatusForbidden, "", ErrSessionExpired, types.FetchErrorSessionExpired},
+		{"not found", http.StatusNotFound, "", ErrNotFound, types.FetchErrorNotFound},
+		{"too many requests", http.StatusTooManyRequests, "", ErrRateLimited, types.FetchErrorRateLimited},
+		{"internal server error", http.StatusInternalServerError, "", ErrUnavailable, types.FetchErrorUnavailable},
+		{"bad gateway", http.StatusBadGateway, "", ErrUnavailable, types.FetchErrorUnavailable},
+		{"service unavailable", http.StatusServiceUnavailable, "", ErrUnavailable, types.FetchErrorUnavailable},
+		{"login page", http.StatusOK, "text/html; charset=utf-8", ErrSessionExpired, types.FetchErrorSessionExpired},
+	}
+
+	for _, test := range tests {
+		resp := &http.Response{StatusCode: test.status, Header: http.Header{}}
+		resp.Header.Set("Content-Type", test.contentType)
+
+		err := checkAOCResponse(resp)
+		if !errors.Is(err, test.want) || (test.want == nil && err != nil) {
+			t.Errorf("%s: got %v, want %v", test.name, err, test.want)
+		}
+		if kind := ErrorKind(err); kind != test.kind {
+			t.Errorf("%s: got error kind %q, want %q", test.name, kind, test.kind)
+		}
+	}
+}
+
+func TestFetchSessionExpired(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/2025/leaderboard/private", http.StatusFound)
+	}))
+	defer server.Close()
+
+	config := &AOCFetcherConfig{BaseUrl: server.URL, LeaderboardId: "123456", Year: "2025"}
+	status := &types.AOCFetchStatus{}
+
+	_, err := FetchAOCLeaderboard(config, status)
+	if !errors.Is(err, ErrSessionExpired) {
+		t.Fatalf("got %v, want ErrSessionExpired", err)
+	}
+	if status.ErrorKind != types.FetchErrorSessionExpired || status.StatusCode != http.StatusFound {
+		t.Errorf("got error kind %q with status %d", status.ErrorKind, status.StatusCode)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2025, time.December, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		header string
+		want   time.Duration
+		ok     bool
+	}{
+		{"120", 2 * time.Minute, true},
+		{" 30 ", 30 * time.Second, true},
+		{"-5", 0, true},
+		{"Mon, 01 Dec 2025 13:00:00 GMT", time.Hour, true},
+		{"Mon, 01 Dec 2025 11:00:00 GMT", 0, true}, // already passed
+		{"", 0, false},
+		{"soon", 0, false},
+	}
+
+	for _, test := range tests {
+		got, ok := parseRetryAfter(test.header, now)
+		if got != test.want || ok != test.ok {
+			t.Errorf("%q: got %s %t, want %s %t", test.header, got, ok, test.want, test.ok)
+		}
+	}
+}
+
+func TestNextFetch(t *testing.T) {
+	config := &AOCFetcherConfig{MinInterval: 15 * time.Minute, MaxBackoff: time.Hour}
+	lastAttempt := time.Date(2025, time.December, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name       string
+		failures   int
+		retryAfter time.Duration // after the last attempt, 0 without one
+		want       time.Duration
+	}{
+		{"success", 0, 0, 15 * time.Minute},
+		{"backoff", 2, 0, time.Hour},
+		{"retry after past the backoff", 1, 3 * time.Hour, 3 * time.Hour},
+		{"retry after before the backoff", 2, 5 * time.Minute, time.Hour},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			status := &types.AOCFetchStatus{
+				LastAttempt:         int(lastAttempt.Unix()),
+				ConsecutiveFailures: test.failures,
+			}
+			if test.retryAfter != 0 {
+				status.RetryAfter = int(lastAttempt.Add(test.retryAfter).Unix())
+			}
+
+			got := NextFetch(config, status).Sub(lastAttempt)
+			if got != test.want {
+				t.Errorf("got %s, want %s", got, test.want)
+			}
+		})
+	}
+}
+
+func TestFetchRateLimited(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "7200")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer server.Close()
+
+	config := &AOCFetcherConfig{BaseUrl: server.URL, LeaderboardId: "123456", Year: "2025"}
+	status := &types.AOCFetchStatus{}
+
+	_, err := FetchAOCLeaderboard(config, status)
+	if !errors.Is(err, ErrRateLimited) {
+		t.Fatalf("got %v, want ErrRateLimited", err)
+	}
+	if status.ErrorKind != types.FetchErrorRateLimited {
+		t.Errorf("got error kind %q", status.ErrorKind)
+	}
+
+	wait := time.Unix(int64(status.RetryAfter), 0).Sub(time.Unix(int64(status.LastAttempt), 0))
+	if wait < 2*time.Hour-time.Second || wait > 2*time.Hour+time.Second {
+		t.Errorf("got retry after %s after the attempt, want 2h", wait)
+	}
+	if next := NextFetch(config, status); next.Before(time.Unix(int64(status.RetryAfter), 0)) {
+		t.Errorf("next fetch at %s is before the retry after", next)
+	}
+}
@@ -0,0 +1,275 @@
+package idpstub
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"log"
+	"math/big"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// Config describes the OpenID Connect provider served by the stub
+type Config struct {
+	Issuer       string        `json:"issuer"` // url the stub is reachable at
+	ClientId     string        `json:"client_id"`
+	ClientSecret string        `json:"client_secret"`
+	Users        []*UserConfig `json:"users"`
+}
+
+type UserConfig struct {
+	Subject string `json:"sub"`
+	Name    string `json:"name"`
+	Picture string `json:"picture"`
+}
+
+// Generate creates a config with numbered users, stub-1 to stub-count
+func Generate(issuer string, clientId string, clientSecret string, count int) *Config {
+	config := &Config{
+		Issuer:       issuer,
+		ClientId:     clientId,
+		ClientSecret: clientSecret,
+		Users:        make([]*UserConfig, 0, count),
+	}
+
+	for i := 1; i <= count; i++ {
+		config.Users = append(config.Users, &UserConfig{
+			Subject: fmt.Sprintf("stub-%d", i),
+			Name:    fmt.Sprintf("Stub Login %d", i),
+		})
+	}
+
+	return config
+}
+
+// pendingCode is an authorization code waiting to be exchanged
+type pendingCode struct {
+	user        *UserConfig
+	redirectUri string
+	nonce       string
+	challenge   string
+}
+
+type stub struct {
+	config *Config
+	key    *rsa.PrivateKey
+
+	lock  sync.Mutex
+	codes map[string]*pendingCode
+}
+
+const keyId = "stub"
+
+var authorizePage = template.Must(template.New("authorize").Parse(`<!DOCTYPE html>
+<html><body>
+<h1>Log in to the stub provider</h1>
+<ul>
+{{range .}}<li><a href="{{.Url}}">{{.Name}} ({{.Subject}})</a></li>
+{{end}}</ul>
+</body></html>`))
+
+// NewHandler serves discovery, authorize, token and jwks endpoints. The authorize endpoint lists the
+// users and logs in as the one picked without asking for a password, pass user=<sub> to skip the list
+func NewHandler(config *Config) http.Handler {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	s := &stub{
+		config: config,
+		key:    key,
+		codes:  map[string]*pendingCode{},
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /.well-known/openid-configuration", s.handleDiscovery)
+	mux.HandleFunc("GET /authorize", s.handleAuthorize)
+	mux.HandleFunc("POST /token", s.handleToken)
+	mux.HandleFunc("GET /jwks", s.handleJwks)
+
+	return mux
+}
+
+func (s *stub) handleDiscovery(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]any{
+		"issuer":                                s.config.Issuer,
+		"authorization_endpoint":                s.config.Issuer + "/authorize",
+		"token_endpoint":                        s.config.Issuer + "/token",
+		"jwks_uri":                              s.config.Issuer + "/jwks",
+		"response_types_supported":              []string{"code"},
+		"subject_types_supported":               []string{"public"},
+		"id_token_signing_alg_values_supported": []string{"RS256"},
+		"code_challenge_methods_supported":      []string{"S256"},
+	})
+}
+
+func (s *stub) handleAuthorize(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	if query.Get("client_id") != s.config.ClientId || query.Get("response_type") != "code" {
+		http.Error(w, "invalid client or response type", http.StatusBadRequest)
+		return
+	}
+	if query.Get("code_challenge_method") != "S256" || len(query.Get("code_challenge")) == 0 {
+		http.Error(w, "PKCE is required", http.StatusBadRequest)
+		return
+	}
+	redirectUri, err := url.Parse(query.Get("redirect_uri"))
+	if err != nil || len(redirectUri.Host) == 0 {
+		http.Error(w, "invalid redirect_uri", http.StatusBadRequest)
+		return
+	}
+
+	var user *UserConfig
+	for _, u := range s.config.Users {
+		if u.Subject == query.Get("user") {
+			user = u
+		}
+	}
+
+	if user == nil {
+		type choice struct {
+			Subject string
+			Name    string
+			Url     string
+		}
+		choices := []choice{}
+		for _, u := range s.config.Users {
+			query.Set("user", u.Subject)
+			choices = append(choices, choice{u.Subject, u.Name, "/authorize?" + query.Encode()})
+		}
+
+		w.Header().Set("Content-Type", "text/html")
+		authorizePage.Execute(w, choices)
+		return
+	}
+
+	code := randomString()
+	s.lock.Lock()
+	s.codes[code] = &pendingCode{
+		user:        user,
+		redirectUri: query.Get("redirect_uri"),
+		nonce:       query.Get("nonce"),
+		challenge:   query.Get("code_challenge"),
+	}
+	s.lock.Unlock()
+
+	callback := redirectUri.Query()
+	callback.Set("code", code)
+	if state := query.Get("state"); len(state) != 0 {
+		callback.Set("state", state)
+	}
+	redirectUri.RawQuery = callback.Encode()
+
+	http.Redirect(w, r, redirectUri.String(), http.StatusFound)
+}
+
+func (s *stub) handleToken(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_request"})
+		return
+	}
+
+	clientId, clientSecret, ok := r.BasicAuth()
+	if !ok {
+		clientId, clientSecret = r.PostForm.Get("client_id"), r.PostForm.Get("client_secret")
+	}
+	if clientId != s.config.ClientId || clientSecret != s.config.ClientSecret {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid_client"})
+		return
+	}
+
+	s.lock.Lock()
+	code := s.codes[r.PostForm.Get("code")]
+	delete(s.codes, r.PostForm.Get("code")) // codes are single use
+	s.lock.Unlock()
+
+	verifier := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+	if code == nil ||
+		r.PostForm.Get("grant_type") != "authorization_code" ||
+		r.PostForm.Get("redirect_uri") != code.redirectUri ||
+		base64.RawURLEncoding.EncodeToString(verifier[:]) != code.challenge {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+		return
+	}
+
+	now := time.Now().Unix()
+	idToken, err := s.sign(map[string]any{
+		"iss":     s.config.Issuer,
+		"sub":     code.user.Subject,
+		"aud":     s.config.ClientId,
+		"exp":     now + 300,
+		"iat":     now,
+		"nonce":   code.nonce,
+		"name":    code.user.Name,
+		"picture": code.user.Picture,
+	})
+	if err != nil {
+		log.Println(err)
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "server_error"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"access_token": randomString(),
+		"token_type":   "Bearer",
+		"expires_in":   300,
+		"id_token":     idToken,
+	})
+}
+
+func (s *stub) handleJwks(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]any{
+		"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": keyId,
+			"use": "sig",
+			"alg": "RS256",
+			"n":   base64.RawURLEncoding.EncodeToString(s.key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(s.key.E)).Bytes()),
+		}},
+	})
+}
+
+// sign encodes the claims as an RS256 JWT
+func (s *stub) sign(claims map[string]any) (string, error) {
+	header, err := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT", "kid": keyId})
+	if err != nil {
+		return "", err
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	hash := sha256.Sum256([]byte(signed))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, s.key, crypto.SHA256, hash[:])
+	if err != nil {
+		return "", err
+	}
+
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+func writeJSON(w http.ResponseWriter, status int, value any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(value)
+}
+
+func randomString() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
@@ -0,0 +1,255 @@
+package notify
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"uocsclub.net/aoclb/internal/events"
+)
+
+// DiscordEvents are the events the Discord notifier can post
+var DiscordEvents = []events.EventType{
+	events.StarEarned,
+	events.RankChanged,
+	events.RankFirst,
+	events.SubmissionCreated,
+}
+
+type DiscordConfig struct {
+	WebhookUrls []string
+	Events      []events.EventType // subset of DiscordEvents
+	BaseURL     string             // public url of the app the embeds link to, no links if empty
+}
+
+// Discord posts the events to Discord webhooks as embeds
+type Discord struct {
+	config DiscordConfig
+	hub    *events.Hub
+	client *http.Client
+
+	lock    sync.Mutex
+	pending []*discordEmbed // waiting to be posted
+}
+
+// a message holds up to 10 embeds
+const discordMaxEmbeds = 10
+
+// discordMaxRetries is how many times a rate limited message is sent again
+const discordMaxRetries = 3
+
+// discordMaxPending is how many embeds wait while Discord rate limits us, the oldest are dropped past it
+const discordMaxPending = 500
+
+type discordMessage struct {
+	Embeds []*discordEmbed `json:"embeds"`
+}
+
+type discordEmbed struct {
+	Title       string          `json:"title"`
+	Description string          `json:"description,omitempty"`
+	Url         string          `json:"url,omitempty"`
+	Color       int             `json:"color"`
+	Timestamp   string          `json:"timestamp,omitempty"` // RFC 3339
+	Fields      []*discordField `json:"fields,omitempty"`
+}
+
+type discordField struct {
+	Name   string `json:"name"`
+	Value  string `json:"value"`
+	Inline bool   `json:"inline"`
+}
+
+func NewDiscord(config DiscordConfig, hub *events.Hub) *Discord {
+	return &Discord{
+		config: config,
+		hub:    hub,
+		client: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Run posts the events, it never returns. The posts are made from another goroutine, waiting out
+// the rate limits of Discord there doesn't make the hub drop the events meanwhile
+func (d *Discord) Run() {
+	updates, unsubscribe := d.hub.Subscribe(256, d.config.Events...)
+	defer unsubscribe()
+
+	wake := make(chan struct{}, 1)
+	go d.postPending(wake)
+
+	for event := range updates {
+		embed := d.embed(event)
+		if embed == nil {
+			continue
+		}
+
+		d.lock.Lock()
+		d.pending = append(d.pending, embed)
+		if dropped := len(d.pending) - discordMaxPending; dropped > 0 {
+			log.Printf("WARN: Dropping %d Discord embeds, too many are waiting to be posted\n", dropped)
+			d.pending = d.pending[dropped:]
+		}
+		d.lock.Unlock()
+
+		select {
+		case wake <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// postPending posts the pending embeds every time it is woken up, the events of a fetch are
+// published together, they go in the same message
+func (d *Discord) postPending(wake <-chan struct{}) {
+	for range wake {
+		for {
+			d.lock.Lock()
+			embeds := d.pending[:min(len(d.pending), discordMaxEmbeds)]
+			d.pending = d.pending[len(embeds):]
+			d.lock.Unlock()
+
+			if len(embeds) == 0 {
+				break
+			}
+
+			for _, url := range d.config.WebhookUrls {
+				err := d.post(url, &discordMessage{Embeds: embeds})
+				if err != nil {
+					log.Printf("ERROR: Failed to post to Discord: %s\n", err)
+				}
+			}
+		}
+	}
+}
+
+func (d *Discord) post(url string, message *discordMessage) error {
+	body, err := json.Marshal(message)
+	if err != nil {
+		return err
+	}
+
+	for retry := 0; ; retry++ {
+		resp, err := d.client.Post(url, "application/json", bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+
+		if resp.StatusCode/100 == 2 {
+			return nil
+		}
+		if resp.StatusCode != http.StatusTooManyRequests || retry == discordMaxRetries {
+			return fmt.Errorf("webhook responded %s", resp.Status)
+		}
+
+		// Discord says how long to wait in seconds, fractions included
+		wait, err := strconv.ParseFloat(resp.Header.Get("Retry-After"), 64)
+		if err != nil || wait <= 0 {
+			wait = 1
+		}
+		time.Sleep(min(time.Duration(wait*float64(time.Second)), time.Minute))
+	}
+}
+
+// embed formats the event, nil if it shouldn't be posted
+func (d *Discord) embed(event events.Event) *discordEmbed {
+	switch data := event.Data.(type) {
+	case *events.StarEarnedData:
+		color := 0x9999cc // same colors as the leaderboard
+		if data.Star == 2 {
+			color = 0xffff66
+		}
+		return &discordEmbed{
+			Title:       fmt.Sprintf("%s got the %s star of day %d", userName(data.User), ordinal(data.Star), data.Day),
+			Description: data.Leaderboard,
+			Url:         d.url(event.Year),
+			Color:       color,
+			Timestamp:   time.Unix(int64(data.CompletedAt), 0).UTC().Format(time.RFC3339),
+		}
+
+	case *events.RankChangedData:
+		// reaching first place has its own embed when it is posted
+		if event.Type == events.RankChanged && data.NewRank == 1 && slices.Contains(d.config.Events, events.RankFirst) {
+			return nil
+		}
+
+		title := fmt.Sprintf("%s moved up to #%d", userName(data.User), data.NewRank)
+		color := 0x009900
+		if event.Type == events.RankFirst {
+			title = fmt.Sprintf("%s took first place", userName(data.User))
+			color = 0xffd700
+		}
+
+		description := fmt.Sprintf("%s, was #%d", data.Leaderboard, data.OldRank)
+		if data.OldRank == 0 {
+			description = fmt.Sprintf("%s, new to the leaderboard", data.Leaderboard)
+		}
+
+		return &discordEmbed{
+			Title:       title,
+			Description: description,
+			Url:         d.url(event.Year),
+			Color:       color,
+			Fields: []*discordField{
+				{Name: "Score", Value: strconv.Itoa(data.Score), Inline: true},
+				{Name: "Overtook", Value: userNames(data.Overtaken), Inline: true},
+			},
+		}
+
+	case *events.SubmissionData:
+		return &discordEmbed{
+			Title:       fmt.Sprintf("%s solved day %d star %d in %s", userName(data.User), data.Day, data.Star, data.Language),
+			Description: fmt.Sprintf("%g%% modifier, waiting for review", data.Modifier),
+			Url:         data.Url,
+			Color:       0xcccccc,
+		}
+	}
+
+	return nil
+}
+
+func (d *Discord) url(year string) string {
+	if len(d.config.BaseURL) == 0 {
+		return ""
+	}
+	return strings.TrimSuffix(d.config.BaseURL, "/") + "/" + year
+}
+
+func userName(user events.User) string {
+	if len(user.Name) == 0 {
+		return fmt.Sprintf("(anonymous user #%d)", user.AocId)
+	}
+	return user.Name
+}
+
+// userNames lists the first few users, embed fields are limited to 1024 characters
+func userNames(users []events.User) string {
+	const maxNames = 10
+
+	names := []string{}
+	for _, user := range users[:min(len(users), maxNames)] {
+		names = append(names, userName(user))
+	}
+	if len(users) > maxNames {
+		names = append(names, fmt.Sprintf("and %d more", len(users)-maxNames))
+	}
+	if len(names) == 0 {
+		return "-"
+	}
+
+	return strings.Join(names, ", ")
+}
+
+func ordinal(star int) string {
+	if star == 1 {
+		return "first"
+	}
+	return "second"
+}
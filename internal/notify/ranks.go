@@ -0,0 +1,214 @@
+package notify
+
+import (
+	"log"
+	"sync"
+
+	"uocsclub.net/aoclb/internal/database"
+	"uocsclub.net/aoclb/internal/events"
+	"uocsclub.net/aoclb/internal/scoring"
+	"uocsclub.net/aoclb/internal/types"
+)
+
+// RankTracker turns the leaderboard updates into the events worth notifying, new stars and members
+// moving up. It remembers the ranks of the previous fetch of every private leaderboard, the ranks
+// are taken again without notifying when a submission changes the adjusted scores in between
+type RankTracker struct {
+	db           *database.DatabaseInst
+	hub          *events.Hub
+	leaderboards []*types.AOCPrivateLeaderboard
+
+	lock  sync.Mutex
+	ranks map[rankKey]map[int]int // aoc id to rank
+}
+
+type rankKey struct {
+	year          string
+	leaderboardId string
+}
+
+func NewRankTracker(db *database.DatabaseInst, hub *events.Hub, leaderboards []*types.AOCPrivateLeaderboard) *RankTracker {
+	return &RankTracker{
+		db:           db,
+		hub:          hub,
+		leaderboards: leaderboards,
+		ranks:        map[rankKey]map[int]int{},
+	}
+}
+
+// Seed remembers the current ranks, otherwise the first fetch after a restart has nothing to compare to
+func (t *RankTracker) Seed() error {
+	for _, leaderboard := range t.leaderboards {
+		err := t.seedLeaderboard(leaderboard)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// seedYear remembers the current ranks of the leaderboards of the year
+func (t *RankTracker) seedYear(year string) error {
+	for _, leaderboard := range t.leaderboards {
+		if leaderboard.Year != year {
+			continue
+		}
+
+		err := t.seedLeaderboard(leaderboard)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (t *RankTracker) seedLeaderboard(leaderboard *types.AOCPrivateLeaderboard) error {
+	rows, err := t.score(leaderboard.Year, leaderboard.Id)
+	if err != nil {
+		return err
+	}
+	t.swapRanks(leaderboard.Year, leaderboard.Id, rows)
+
+	return nil
+}
+
+// Start publishes the events derived from the leaderboard updates from now on, they are published
+// by the fetch job as it publishes the update so none of them are missed
+func (t *RankTracker) Start() {
+	t.hub.Handle(func(event events.Event) {
+		changes, ok := event.Data.(*types.AOCLeaderboardChanges)
+		if !ok {
+			return
+		}
+
+		err := t.update(changes)
+		if err != nil {
+			log.Println(err)
+		}
+	}, events.LeaderboardUpdated)
+
+	// a submission moves members without a fetch, only the moves of the next fetch are its own
+	t.hub.Handle(func(event events.Event) {
+		err := t.seedYear(event.Year)
+		if err != nil {
+			log.Println(err)
+		}
+	}, events.SubmissionCreated, events.SubmissionReviewed)
+}
+
+func (t *RankTracker) update(changes *types.AOCLeaderboardChanges) error {
+	rows, err := t.score(changes.Year, changes.LeaderboardId)
+	if err != nil {
+		return err
+	}
+
+	users := map[int]events.User{}
+	for _, row := range rows {
+		users[row.Entry.User.UserId] = events.NewUser(&row.Entry.User)
+	}
+
+	name := t.leaderboardName(changes.Year, changes.LeaderboardId)
+
+	for _, star := range changes.NewStars {
+		t.hub.Publish(events.Event{
+			Type:          events.StarEarned,
+			Year:          changes.Year,
+			LeaderboardId: changes.LeaderboardId,
+			Data: &events.StarEarnedData{
+				User:        users[star.UserId],
+				Leaderboard: name,
+				Day:         star.Day,
+				Star:        star.Star,
+				CompletedAt: star.StarTS,
+			},
+		})
+	}
+
+	oldRanks := t.swapRanks(changes.Year, changes.LeaderboardId, rows)
+	if oldRanks == nil {
+		return nil
+	}
+
+	for _, row := range rows {
+		// members new to the leaderboard have no old rank, they are only worth notifying in first place
+		oldRank, ok := oldRanks[row.Entry.User.UserId]
+		if (ok && row.Rank >= oldRank) || (!ok && row.Rank != 1) {
+			continue
+		}
+
+		// whoever was ahead before and is behind now
+		overtaken := []events.User{}
+		for _, other := range rows {
+			otherOldRank, otherOk := oldRanks[other.Entry.User.UserId]
+			if otherOk && (!ok || otherOldRank < oldRank) && other.Rank > row.Rank {
+				overtaken = append(overtaken, users[other.Entry.User.UserId])
+			}
+		}
+
+		data := &events.RankChangedData{
+			User:        users[row.Entry.User.UserId],
+			Leaderboard: name,
+			OldRank:     oldRank,
+			NewRank:     row.Rank,
+			Score:       row.AdjustedScore,
+			Overtaken:   overtaken,
+		}
+
+		if ok {
+			t.hub.Publish(events.Event{
+				Type:          events.RankChanged,
+				Year:          changes.Year,
+				LeaderboardId: changes.LeaderboardId,
+				Data:          data,
+			})
+		}
+		if row.Rank == 1 {
+			t.hub.Publish(events.Event{
+				Type:          events.RankFirst,
+				Year:          changes.Year,
+				LeaderboardId: changes.LeaderboardId,
+				Data:          data,
+			})
+		}
+	}
+
+	return nil
+}
+
+func (t *RankTracker) score(year string, leaderboardId string) ([]*scoring.Row, error) {
+	input, err := scoring.LoadInput(t.db, year, leaderboardId)
+	if err != nil {
+		return nil, err
+	}
+
+	return scoring.ForEvent(input.Event).Score(input), nil
+}
+
+// swapRanks remembers the ranks of the rows and returns the previous ones, nil if there were none
+func (t *RankTracker) swapRanks(year string, leaderboardId string, rows []*scoring.Row) map[int]int {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	ranks := map[int]int{}
+	for _, row := range rows {
+		ranks[row.Entry.User.UserId] = row.Rank
+	}
+
+	key := rankKey{year: year, leaderboardId: leaderboardId}
+	oldRanks := t.ranks[key]
+	t.ranks[key] = ranks
+
+	return oldRanks
+}
+
+func (t *RankTracker) leaderboardName(year string, leaderboardId string) string {
+	for _, leaderboard := range t.leaderboards {
+		if leaderboard.Year == year && leaderboard.Id == leaderboardId {
+			return leaderboard.Name
+		}
+	}
+
+	return leaderboardId
+}
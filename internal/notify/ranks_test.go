@@ -0,0 +1,177 @@
+package notify
+
+import (
+	"path/filepath"
+	"testing"
+
+	"uocsclub.net/aoclb/internal/database"
+	"uocsclub.net/aoclb/internal/events"
+	"uocsclub.net/aoclb/internal/types"
+)
+
+func testDatabase(t *testing.T) *database.DatabaseInst {
+	t.Helper()
+
+	db, err := database.InitDatabase(filepath.Join(t.TempDir(), "aoclb.db"), "../../migrations")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return db
+}
+
+func testEntry(id int, name string, score int) *types.AOCUserLB {
+	return &types.AOCUserLB{
+		Year:        "2025",
+		User:        types.AOCUser{UserId: id, Name: name},
+		Score:       score,
+		Completions: map[int]*types.AOCCompletion{},
+	}
+}
+
+// receive returns the events already published on the channel
+func receive(ch <-chan events.Event) []events.Event {
+	received := []events.Event{}
+	for {
+		select {
+		case event := <-ch:
+			received = append(received, event)
+		default:
+			return received
+		}
+	}
+}
+
+func TestRankTrackerUpdate(t *testing.T) {
+	tests := []struct {
+		name   string
+		update types.AOCData
+		want   []events.EventType
+		old    int // old rank of the events
+		taken  int // members overtaken
+	}{
+		{
+			name:   "new member in first place",
+			update: types.AOCData{1: testEntry(1, "alice", 10), 2: testEntry(2, "bob", 5), 3: testEntry(3, "carol", 20)},
+			want:   []events.EventType{events.RankFirst},
+			old:    0,
+			taken:  2,
+		},
+		{
+			name:   "new member behind",
+			update: types.AOCData{1: testEntry(1, "alice", 10), 2: testEntry(2, "bob", 5), 3: testEntry(3, "carol", 7)},
+			want:   []events.EventType{},
+		},
+		{
+			name:   "member moving up to first place",
+			update: types.AOCData{1: testEntry(1, "alice", 10), 2: testEntry(2, "bob", 15)},
+			want:   []events.EventType{events.RankChanged, events.RankFirst},
+			old:    2,
+			taken:  1,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			db := testDatabase(t)
+			hub := events.NewHub()
+			leaderboard := &types.AOCPrivateLeaderboard{Id: "123456", Year: "2025", Name: "club"}
+
+			err := db.StorePrivateLeaderboard(leaderboard)
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, err = db.StoreLeaderboard(leaderboard, types.AOCData{1: testEntry(1, "alice", 10), 2: testEntry(2, "bob", 5)}, 1000)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			tracker := NewRankTracker(db, hub, []*types.AOCPrivateLeaderboard{leaderboard})
+			err = tracker.Seed()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			ranks, unsubscribe := hub.Subscribe(16, events.RankChanged, events.RankFirst)
+			defer unsubscribe()
+
+			changes, err := db.StoreLeaderboard(leaderboard, test.update, 2000)
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = tracker.update(changes)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			received := receive(ranks)
+			if len(received) != len(test.want) {
+				t.Fatalf("got %d events, want %v", len(received), test.want)
+			}
+			for i, event := range received {
+				data := event.Data.(*events.RankChangedData)
+				if event.Type != test.want[i] || data.NewRank != 1 || data.OldRank != test.old || len(data.Overtaken) != test.taken {
+					t.Errorf("got %s from #%d to #%d overtaking %d, want %s from #%d to #1 overtaking %d",
+						event.Type, data.OldRank, data.NewRank, len(data.Overtaken), test.want[i], test.old, test.taken)
+				}
+			}
+		})
+	}
+}
+
+func TestRankTrackerSubmissionMoves(t *testing.T) {
+	db := testDatabase(t)
+	hub := events.NewHub()
+	leaderboard := &types.AOCPrivateLeaderboard{Id: "123456", Year: "2025", Name: "club"}
+
+	err := db.StorePrivateLeaderboard(leaderboard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = db.StoreLeaderboard(leaderboard, types.AOCData{1: testEntry(1, "alice", 100), 2: testEntry(2, "bob", 98)}, 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = db.EnsureModifierSet("2025")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tracker := NewRankTracker(db, hub, []*types.AOCPrivateLeaderboard{leaderboard})
+	err = tracker.Seed()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tracker.Start()
+
+	ranks, unsubscribe := hub.Subscribe(16, events.RankChanged, events.RankFirst)
+	defer unsubscribe()
+
+	// bob's VHDL bonus puts him ahead of alice before anything is fetched
+	submission, err := db.AddUserSubmission("2025", &types.AOCUserSubmission{
+		AOCSubmissionModifier: types.AOCSubmissionModifier{LanguageName: "VHDL"},
+		AocUserId:             2,
+		SubmissionUrl:         "https://example.com",
+		Date:                  1,
+		Star:                  1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	hub.Publish(events.Event{
+		Type: events.SubmissionCreated,
+		Year: "2025",
+		Data: events.NewSubmissionData(&types.AOCUser{UserId: 2, Name: "bob"}, submission),
+	})
+
+	// the next fetch doesn't change the order, it isn't the one moving bob up
+	changes, err := db.StoreLeaderboard(leaderboard, types.AOCData{1: testEntry(1, "alice", 100), 2: testEntry(2, "bob", 99)}, 2000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hub.Publish(events.Event{Type: events.LeaderboardUpdated, Year: "2025", LeaderboardId: leaderboard.Id, Data: changes})
+
+	if received := receive(ranks); len(received) != 0 {
+		t.Errorf("got %d rank events, want none", len(received))
+	}
+}
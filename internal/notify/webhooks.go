@@ -0,0 +1,232 @@
+package notify
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"uocsclub.net/aoclb/internal/database"
+	"uocsclub.net/aoclb/internal/events"
+	"uocsclub.net/aoclb/internal/types"
+)
+
+// WebhookEvents are the events the webhooks can subscribe to
+var WebhookEvents = []events.EventType{
+	events.StarEarned,
+	events.RankChanged,
+	events.RankFirst,
+	events.SubmissionCreated,
+	events.SubmissionReviewed,
+}
+
+// Webhooks queues the events for the webhooks subscribed to them and sends the deliveries, failed
+// attempts are retried with an exponential backoff
+type Webhooks struct {
+	db     *database.DatabaseInst
+	hub    *events.Hub
+	client *http.Client
+	wake   chan struct{}
+}
+
+const (
+	// webhookMaxAttempts is how many times a delivery is sent before it is marked failed,
+	// the attempts are spread over about an hour
+	webhookMaxAttempts = 8
+	webhookRetryDelay  = 30 * time.Second // doubled after every failed attempt
+
+	// webhookPollInterval is how often the queue is checked for retries and deliveries queued
+	// by the server, like pings
+	webhookPollInterval = 5 * time.Second
+	webhookBatchSize    = 50
+
+	// the delivery log keeps what was done in the last month
+	webhookLogRetention = 30 * 24 * time.Hour
+)
+
+func NewWebhooks(db *database.DatabaseInst, hub *events.Hub) *Webhooks {
+	return &Webhooks{
+		db:     db,
+		hub:    hub,
+		client: &http.Client{Timeout: 10 * time.Second},
+		wake:   make(chan struct{}, 1),
+	}
+}
+
+// Start queues the deliveries of the events published from now on and sends them in the background.
+// The deliveries are queued by the publishers, the fetch job and the submission handlers, before they
+// move on so none are lost
+func (w *Webhooks) Start() {
+	w.hub.Handle(func(event events.Event) {
+		err := w.queue(event)
+		if err != nil {
+			log.Printf("ERROR: Failed to queue the webhook deliveries of a %s event: %s\n", event.Type, err)
+			return
+		}
+
+		select {
+		case w.wake <- struct{}{}:
+		default:
+		}
+	}, WebhookEvents...)
+
+	go w.send()
+}
+
+// QueueWebhookEvent stores a delivery of the event for the webhook, it is sent on the next poll
+func QueueWebhookEvent(db *database.DatabaseInst, webhookId int, event events.Event) error {
+	now := int(time.Now().Unix())
+
+	payload, err := json.Marshal(events.NewPayload(event, now))
+	if err != nil {
+		return err
+	}
+
+	return db.QueueWebhookDelivery(&types.AOCWebhookDelivery{
+		WebhookId:     webhookId,
+		Event:         string(event.Type),
+		Payload:       string(payload),
+		NextAttemptAt: now,
+		CreatedAt:     now,
+	})
+}
+
+func (w *Webhooks) queue(event events.Event) error {
+	webhooks, err := w.db.GetWebhooks()
+	if err != nil {
+		return err
+	}
+
+	for _, webhook := range webhooks {
+		if !webhook.Subscribed(string(event.Type)) {
+			continue
+		}
+
+		err = QueueWebhookEvent(w.db, webhook.Id, event)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (w *Webhooks) send() {
+	ticker := time.NewTicker(webhookPollInterval)
+	defer ticker.Stop()
+
+	lastPrune := time.Time{}
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-w.wake:
+		}
+
+		err := w.sendDue()
+		if err != nil {
+			log.Println(err)
+		}
+
+		if time.Since(lastPrune) > time.Hour {
+			lastPrune = time.Now()
+			err = w.db.PruneWebhookDeliveries(int(lastPrune.Add(-webhookLogRetention).Unix()))
+			if err != nil {
+				log.Println(err)
+			}
+		}
+	}
+}
+
+// sendDue attempts every delivery that is due
+func (w *Webhooks) sendDue() error {
+	for {
+		deliveries, err := w.db.GetDueWebhookDeliveries(int(time.Now().Unix()), webhookBatchSize)
+		if err != nil {
+			return err
+		}
+
+		for _, delivery := range deliveries {
+			webhook, err := w.db.GetWebhook(delivery.WebhookId)
+			if err != nil {
+				return err
+			}
+			if webhook == nil {
+				continue
+			}
+
+			w.attempt(webhook, delivery)
+
+			err = w.db.StoreWebhookAttempt(delivery)
+			if err != nil {
+				return err
+			}
+		}
+
+		if len(deliveries) < webhookBatchSize {
+			return nil
+		}
+	}
+}
+
+// attempt sends the delivery once and records how it went in it
+func (w *Webhooks) attempt(webhook *types.AOCWebhook, delivery *types.AOCWebhookDelivery) {
+	now := time.Now()
+	timestamp := strconv.FormatInt(now.Unix(), 10)
+
+	delivery.Attempts++
+	delivery.ResponseStatus = 0
+	delivery.Error = ""
+
+	req, err := http.NewRequest(http.MethodPost, webhook.Url, bytes.NewReader([]byte(delivery.Payload)))
+	if err == nil {
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("User-Agent", "aoclb-webhooks")
+		req.Header.Set("X-Aoclb-Event", delivery.Event)
+		req.Header.Set("X-Aoclb-Delivery", strconv.Itoa(delivery.Id))
+		req.Header.Set("X-Aoclb-Timestamp", timestamp)
+		req.Header.Set("X-Aoclb-Signature", "sha256="+signWebhook(webhook.Secret, timestamp, delivery.Payload))
+
+		var resp *http.Response
+		resp, err = w.client.Do(req)
+		if err == nil {
+			// drained so the connection is reused
+			io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+			resp.Body.Close()
+
+			delivery.ResponseStatus = resp.StatusCode
+			if resp.StatusCode/100 == 2 {
+				delivery.Status = types.DeliveryDelivered
+				delivery.DeliveredAt = int(now.Unix())
+				return
+			}
+			delivery.Error = "webhook responded " + resp.Status
+		}
+	}
+	if err != nil {
+		delivery.Error = err.Error()
+	}
+
+	if delivery.Attempts >= webhookMaxAttempts {
+		delivery.Status = types.DeliveryFailed
+		log.Printf("ERROR: Webhook delivery %d to %s failed after %d attempts: %s\n", delivery.Id, webhook.Url, delivery.Attempts, delivery.Error)
+		return
+	}
+
+	delay := webhookRetryDelay << (delivery.Attempts - 1)
+	delivery.NextAttemptAt = int(now.Add(delay).Unix())
+}
+
+// signWebhook is the hex HMAC-SHA256 of "<timestamp>.<body>", the timestamp is signed so a
+// captured delivery can't be replayed later
+func signWebhook(secret string, timestamp string, body string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "." + body))
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,123 @@
+package notify
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"uocsclub.net/aoclb/internal/events"
+	"uocsclub.net/aoclb/internal/types"
+)
+
+func TestWebhooksQueueOnPublish(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	db := testDatabase(t)
+	hub := events.NewHub()
+
+	subscribed := &types.AOCWebhook{Url: server.URL, Secret: "secret", Events: []string{string(events.StarEarned)}, Active: true}
+	other := &types.AOCWebhook{Url: server.URL, Secret: "secret", Events: []string{string(events.RankFirst)}, Active: true}
+	for _, webhook := range []*types.AOCWebhook{subscribed, other} {
+		err := db.CreateWebhook(webhook)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	NewWebhooks(db, hub).Start()
+
+	// more events than any subscriber buffer, none may be dropped
+	const published = 300
+	for range published {
+		hub.Publish(events.Event{Type: events.StarEarned, Year: "2025", Data: &events.StarEarnedData{Day: 1, Star: 1}})
+	}
+
+	deliveries, err := db.GetWebhookDeliveries(subscribed.Id, 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(deliveries) != published {
+		t.Errorf("got %d deliveries queued, want %d", len(deliveries), published)
+	}
+
+	deliveries, err = db.GetWebhookDeliveries(other.Id, 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(deliveries) != 0 {
+		t.Errorf("got %d deliveries for a webhook not subscribed to the event", len(deliveries))
+	}
+}
+
+func TestSignWebhook(t *testing.T) {
+	// the python snippet of the README gives the same signature
+	want := "ae57d761b962b70cbc99f33b87a7df4bcd00e96c91f1f91f8f7570fcd3e9e0fc"
+	if got := signWebhook("s3cret", "1764568800", `{"type":"ping"}`); got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+
+	// the signature changes with any of its inputs
+	for _, got := range []string{
+		signWebhook("other", "1764568800", `{"type":"ping"}`),
+		signWebhook("s3cret", "1764568801", `{"type":"ping"}`),
+		signWebhook("s3cret", "1764568800", `{"type":"pong"}`),
+	} {
+		if got == want {
+			t.Errorf("got the same signature for different inputs")
+		}
+	}
+}
+
+func TestWebhookAttempt(t *testing.T) {
+	status := http.StatusInternalServerError
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+
+		want := "sha256=" + signWebhook("secret", r.Header.Get("X-Aoclb-Timestamp"), string(body))
+		if r.Header.Get("X-Aoclb-Signature") != want {
+			t.Errorf("got signature %q, want %q", r.Header.Get("X-Aoclb-Signature"), want)
+		}
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	w := NewWebhooks(nil, nil)
+	webhook := &types.AOCWebhook{Id: 1, Url: server.URL, Secret: "secret"}
+	delivery := &types.AOCWebhookDelivery{Id: 1, WebhookId: 1, Event: string(events.Ping), Payload: `{"type":"ping"}`, Status: types.DeliveryPending}
+
+	// 30s after the first attempt, doubled every time after
+	for attempt := 1; attempt < webhookMaxAttempts; attempt++ {
+		before := time.Now()
+		w.attempt(webhook, delivery)
+
+		want := webhookRetryDelay << (attempt - 1)
+		delay := time.Unix(int64(delivery.NextAttemptAt), 0).Sub(before.Truncate(time.Second))
+		if delivery.Status != types.DeliveryPending || delivery.Attempts != attempt {
+			t.Fatalf("attempt %d: got %s after %d attempts", attempt, delivery.Status, delivery.Attempts)
+		}
+		if delay < want || delay > want+time.Second {
+			t.Errorf("attempt %d: got the next attempt %s later, want %s", attempt, delay, want)
+		}
+		if delivery.ResponseStatus != status || len(delivery.Error) == 0 {
+			t.Errorf("attempt %d: got response %d and error %q", attempt, delivery.ResponseStatus, delivery.Error)
+		}
+	}
+
+	w.attempt(webhook, delivery)
+	if delivery.Status != types.DeliveryFailed || delivery.Attempts != webhookMaxAttempts {
+		t.Errorf("got %s after %d attempts, want failed after %d", delivery.Status, delivery.Attempts, webhookMaxAttempts)
+	}
+
+	// sent again from the delivery log
+	status = http.StatusNoContent
+	delivery.Status = types.DeliveryPending
+	delivery.Attempts = 0
+
+	w.attempt(webhook, delivery)
+	if delivery.Status != types.DeliveryDelivered || delivery.DeliveredAt == 0 || len(delivery.Error) != 0 {
+		t.Errorf("got %s delivered at %d with error %q", delivery.Status, delivery.DeliveredAt, delivery.Error)
+	}
+}
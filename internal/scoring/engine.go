@@ -0,0 +1,179 @@
+package scoring
+
+import (
+	"cmp"
+	"slices"
+	"strings"
+
+	"uocsclub.net/aoclb/internal/types"
+)
+
+// Engine turns a leaderboard into ranked rows, every ruleset is its own engine
+type Engine interface {
+	Name() types.AOCScoringMode
+	Score(input *Input) []*Row
+}
+
+type Input struct {
+	Event       *types.AOCEvent
+	Leaderboard types.AOCData
+	Submissions []*types.AOCUserSubmission
+	Modifiers   []*types.AOCSubmissionModifier // submissions in a language missing from here don't count
+	// VerifiedOnly only counts approved submissions, otherwise pending ones count too.
+	// Rejected submissions never count
+	VerifiedOnly bool
+}
+
+// StarScore is the breakdown of a single star of a member
+type StarScore struct {
+	Day            int
+	Star           int
+	Points         int                      // points from the local leaderboard rules
+	Submission     *types.AOCUserSubmission // submission with the best modifier, nil without one
+	Modifier       int                      // %*10 like types.AOCSubmissionModifier, 0 without a submission
+	ModifierPoints float64                  // bonus points given by the modifiers
+}
+
+type Row struct {
+	Rank          int // 1 is first place
+	Entry         *types.AOCUserLB
+	AdjustedScore int
+	Stars         []*StarScore // ordered by day then star
+}
+
+// Default is the engine used when a year doesn't pick a scoring mode
+var Default Engine = TotalEngine{}
+
+var engines = []Engine{
+	TotalEngine{},
+	PerStarEngine{},
+}
+
+// ForMode returns the engine implementing the scoring mode, or the default one
+func ForMode(mode types.AOCScoringMode) Engine {
+	for _, engine := range engines {
+		if engine.Name() == mode {
+			return engine
+		}
+	}
+
+	return Default
+}
+
+// ForEvent returns the engine picked by the event
+func ForEvent(event *types.AOCEvent) Engine {
+	if event == nil {
+		return Default
+	}
+
+	return ForMode(event.ScoringMode)
+}
+
+// languageModifiers indexes the modifier of every language that counts
+func languageModifiers(input *Input) map[string]int {
+	modifiers := map[string]int{}
+	for _, modifier := range input.Modifiers {
+		modifiers[modifier.LanguageName] = modifier.ModifierDecPercent
+	}
+
+	return modifiers
+}
+
+// bestSubmissions returns the submission with the best modifier of every star, indexed by user, day, then star-1.
+// Every submission counts here, whether the star was completed or not
+func bestSubmissions(input *Input, modifiers map[string]int) map[int]map[int]*[2]*types.AOCUserSubmission {
+	bestSubmissions := map[int]map[int]*[2]*types.AOCUserSubmission{}
+	for _, submission := range input.Submissions {
+		percent, ok := modifiers[submission.LanguageName]
+		if !ok || submission.Star < 1 || submission.Star > 2 {
+			continue
+		}
+		if submission.Status == types.SubmissionRejected || (input.VerifiedOnly && submission.Status != types.SubmissionApproved) {
+			continue
+		}
+
+		if bestSubmissions[submission.AocUserId] == nil {
+			bestSubmissions[submission.AocUserId] = map[int]*[2]*types.AOCUserSubmission{}
+		}
+		days := bestSubmissions[submission.AocUserId]
+		if days[submission.Date] == nil {
+			days[submission.Date] = &[2]*types.AOCUserSubmission{}
+		}
+
+		best := days[submission.Date][submission.Star-1]
+		if best == nil || modifiers[best.LanguageName] < percent {
+			days[submission.Date][submission.Star-1] = submission
+		}
+	}
+
+	return bestSubmissions
+}
+
+// starScores returns the points and best submission of every star obtained by every member,
+// submissions for stars that weren't completed are left out
+func starScores(input *Input) map[int][]*StarScore {
+	numDays := 0
+	if input.Event != nil {
+		numDays = input.Event.NumDays
+	}
+
+	modifiers := languageModifiers(input)
+	bestSubmissions := bestSubmissions(input, modifiers)
+
+	starPoints := types.ComputeStarPoints(input.Leaderboard, numDays, nil)
+
+	scores := map[int][]*StarScore{}
+	for id, entry := range input.Leaderboard {
+		scores[id] = []*StarScore{}
+
+		for day := 1; day <= numDays; day++ {
+			completion := entry.Completions[day]
+			if completion == nil {
+				continue
+			}
+
+			for star := 1; star <= 2; star++ {
+				if (star == 1 && !completion.Star1) || (star == 2 && !completion.Star2) {
+					continue
+				}
+
+				score := &StarScore{
+					Day:  day,
+					Star: star,
+				}
+				if points := starPoints[id][day]; points != nil {
+					score.Points = points[star-1]
+				}
+				if submissions := bestSubmissions[id][day]; submissions != nil && submissions[star-1] != nil {
+					score.Submission = submissions[star-1]
+					score.Modifier = modifiers[score.Submission.LanguageName]
+				}
+
+				scores[id] = append(scores[id], score)
+			}
+		}
+	}
+
+	return scores
+}
+
+func modifierMultiplier(score *StarScore) float64 {
+	return float64(score.Modifier) / 1000
+}
+
+// rank orders the rows by adjusted score and numbers them
+func rank(rows []*Row) []*Row {
+	slices.SortFunc(rows, func(a, b *Row) int {
+		return cmp.Or(
+			b.AdjustedScore-a.AdjustedScore,
+			strings.Compare(a.Entry.User.Name, b.Entry.User.Name),
+			a.Entry.User.UserId-b.Entry.User.UserId,
+		)
+	})
+
+	for idx, row := range rows {
+		row.Rank = idx + 1
+	}
+
+	return rows
+}
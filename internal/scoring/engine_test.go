@@ -0,0 +1,203 @@
+package scoring
+
+import (
+	"testing"
+
+	"uocsclub.net/aoclb/internal/types"
+)
+
+// testInput is a 2 day leaderboard of 3 members, alice got every star first:
+//
+//	day 1 star 1: alice 3, bob 2, carol 1
+//	day 1 star 2: alice 3, bob 2
+//	day 2 star 1: alice 3
+func testInput(numDays int, verifiedOnly bool) *Input {
+	entry := func(id int, name string, score int, completions map[int]*types.AOCCompletion) *types.AOCUserLB {
+		return &types.AOCUserLB{
+			Year:        "2025",
+			User:        types.AOCUser{UserId: id, Name: name},
+			Score:       score,
+			Completions: completions,
+		}
+	}
+	submission := func(userId int, day int, star int, language string, status types.AOCSubmissionStatus) *types.AOCUserSubmission {
+		return &types.AOCUserSubmission{
+			AOCSubmissionModifier: types.AOCSubmissionModifier{LanguageName: language},
+			AocUserId:             userId,
+			Year:                  "2025",
+			Date:                  day,
+			Star:                  star,
+			Status:                status,
+		}
+	}
+
+	return &Input{
+		Event: &types.AOCEvent{Year: "2025", NumDays: numDays},
+		Leaderboard: types.AOCData{
+			1: entry(1, "alice", 9, map[int]*types.AOCCompletion{
+				1: {Star1: true, Star1TS: 100, Star1Index: 0, Star2: true, Star2TS: 200, Star2Index: 2},
+				2: {Star1: true, Star1TS: 300, Star1Index: 4},
+			}),
+			2: entry(2, "bob", 4, map[int]*types.AOCCompletion{
+				1: {Star1: true, Star1TS: 110, Star1Index: 1, Star2: true, Star2TS: 210, Star2Index: 3},
+			}),
+			3: entry(3, "carol", 1, map[int]*types.AOCCompletion{
+				1: {Star1: true, Star1TS: 500, Star1Index: 5},
+			}),
+		},
+		Submissions: []*types.AOCUserSubmission{
+			submission(1, 1, 1, "Go", types.SubmissionApproved),
+			submission(1, 1, 1, "Rust", types.SubmissionApproved), // better than Go
+			submission(1, 1, 2, "Go", types.SubmissionPending),
+			submission(1, 2, 2, "Rust", types.SubmissionApproved),  // star not completed
+			submission(2, 1, 1, "Rust", types.SubmissionRejected),  // rejected
+			submission(2, 1, 2, "Cobol", types.SubmissionApproved), // no modifier
+			submission(3, 1, 1, "Go", types.SubmissionPending),
+			submission(3, 3, 1, "Rust", types.SubmissionApproved), // after the last day
+		},
+		Modifiers: []*types.AOCSubmissionModifier{
+			{LanguageName: "Rust", ModifierDecPercent: 500},
+			{LanguageName: "Go", ModifierDecPercent: 200},
+		},
+		VerifiedOnly: verifiedOnly,
+	}
+}
+
+func TestStarScores(t *testing.T) {
+	type star struct {
+		day      int
+		star     int
+		points   int
+		language string // of the best submission, empty without one
+	}
+
+	tests := []struct {
+		name         string
+		numDays      int
+		verifiedOnly bool
+		want         map[int][]star
+	}{
+		{
+			name:    "pending submissions count",
+			numDays: 2,
+			want: map[int][]star{
+				1: {{1, 1, 3, "Rust"}, {1, 2, 3, "Go"}, {2, 1, 3, ""}},
+				2: {{1, 1, 2, ""}, {1, 2, 2, ""}},
+				3: {{1, 1, 1, "Go"}},
+			},
+		},
+		{
+			name:         "verified only",
+			numDays:      2,
+			verifiedOnly: true,
+			want: map[int][]star{
+				1: {{1, 1, 3, "Rust"}, {1, 2, 3, ""}, {2, 1, 3, ""}},
+				2: {{1, 1, 2, ""}, {1, 2, 2, ""}},
+				3: {{1, 1, 1, ""}},
+			},
+		},
+		{
+			name:    "first day only",
+			numDays: 1,
+			want: map[int][]star{
+				1: {{1, 1, 3, "Rust"}, {1, 2, 3, "Go"}},
+				2: {{1, 1, 2, ""}, {1, 2, 2, ""}},
+				3: {{1, 1, 1, "Go"}},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			input := testInput(test.numDays, test.verifiedOnly)
+			scores := starScores(input)
+
+			if len(scores) != len(test.want) {
+				t.Fatalf("got %d members, want %d", len(scores), len(test.want))
+			}
+			for id, want := range test.want {
+				got := scores[id]
+				if len(got) != len(want) {
+					t.Errorf("member %d: got %d stars, want %d", id, len(got), len(want))
+					continue
+				}
+
+				for i, score := range got {
+					language := ""
+					if score.Submission != nil {
+						language = score.Submission.LanguageName
+					}
+					gotStar := star{score.Day, score.Star, score.Points, language}
+					if gotStar != want[i] {
+						t.Errorf("member %d: got %v, want %v", id, gotStar, want[i])
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestEngines(t *testing.T) {
+	tests := []struct {
+		name         string
+		engine       Engine
+		numDays      int
+		verifiedOnly bool
+		want         map[int]int // adjusted score by aoc id
+	}{
+		// every submission counts, alice: 9 * (1 + 50% + 20% + 50%)
+		{"total", TotalEngine{}, 2, false, map[int]int{1: 19, 2: 4, 3: 1}},
+		{"total before the event is fetched", TotalEngine{}, 0, false, map[int]int{1: 19, 2: 4, 3: 1}},
+		// alice: 9 * (1 + 50% + 50%)
+		{"total verified", TotalEngine{}, 2, true, map[int]int{1: 18, 2: 4, 3: 1}},
+		// only completed stars count, alice: 3 * 150% + 3 * 120% + 3
+		{"per star", PerStarEngine{}, 2, false, map[int]int{1: 11, 2: 4, 3: 1}},
+		{"per star verified", PerStarEngine{}, 2, true, map[int]int{1: 10, 2: 4, 3: 1}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rows := test.engine.Score(testInput(test.numDays, test.verifiedOnly))
+
+			if len(rows) != len(test.want) {
+				t.Fatalf("got %d rows, want %d", len(rows), len(test.want))
+			}
+			for i, row := range rows {
+				id := row.Entry.User.UserId
+				if row.AdjustedScore != test.want[id] {
+					t.Errorf("member %d: got %d, want %d", id, row.AdjustedScore, test.want[id])
+				}
+				if row.Rank != i+1 {
+					t.Errorf("member %d: got rank %d at index %d", id, row.Rank, i)
+				}
+			}
+			if rows[0].Entry.User.UserId != 1 {
+				t.Errorf("got member %d first, want alice", rows[0].Entry.User.UserId)
+			}
+		})
+	}
+}
+
+func TestRank(t *testing.T) {
+	row := func(score int, name string, id int) *Row {
+		return &Row{
+			Entry:         &types.AOCUserLB{User: types.AOCUser{UserId: id, Name: name}},
+			AdjustedScore: score,
+		}
+	}
+
+	rows := rank([]*Row{
+		row(10, "bob", 5),
+		row(10, "alice", 9),
+		row(12, "zed", 1),
+		row(10, "alice", 3),
+		row(10, "", 7), // anonymous members sort first among equal scores
+	})
+
+	want := []int{1, 7, 3, 9, 5}
+	for i, row := range rows {
+		if row.Entry.User.UserId != want[i] || row.Rank != i+1 {
+			t.Errorf("rank %d: got member %d ranked %d, want member %d", i+1, row.Entry.User.UserId, row.Rank, want[i])
+		}
+	}
+}
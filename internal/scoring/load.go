@@ -0,0 +1,58 @@
+package scoring
+
+import (
+	"uocsclub.net/aoclb/internal/database"
+	"uocsclub.net/aoclb/internal/types"
+)
+
+// LoadInput reads what the engines need to score the private leaderboard of the year, every board
+// merged together if leaderboardId is empty
+func LoadInput(db *database.DatabaseInst, year string, leaderboardId string) (*Input, error) {
+	data, err := db.GetLeaderboard(year, leaderboardId)
+	if err != nil {
+		return nil, err
+	}
+
+	event, err := LoadEvent(db, year)
+	if err != nil {
+		return nil, err
+	}
+
+	submissions, err := db.GetSubmissionsByYear(year)
+	if err != nil {
+		return nil, err
+	}
+
+	modifiers, err := db.GetModifiers(year)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Input{
+		Event:       event,
+		Leaderboard: data,
+		Submissions: submissions,
+		Modifiers:   modifiers,
+	}, nil
+}
+
+// LoadEvent reads the event of the year, the events that aren't stored yet score in total mode and
+// the ones AOC wasn't fetched for yet get their days from the calendar
+func LoadEvent(db *database.DatabaseInst, year string) (*types.AOCEvent, error) {
+	event, err := db.GetEvent(year)
+	if err != nil {
+		return nil, err
+	}
+	if event == nil {
+		event = &types.AOCEvent{Year: year, ScoringMode: types.ScoringModeTotal}
+	}
+
+	// events set up for their scoring mode don't have days until AOC is fetched
+	if event.NumDays == 0 {
+		calendar := types.CalendarAOCEvent(year)
+		event.NumDays = calendar.NumDays
+		event.Day1Timestamp = calendar.Day1Timestamp
+	}
+
+	return event, nil
+}
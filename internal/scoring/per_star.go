@@ -0,0 +1,30 @@
+package scoring
+
+import "uocsclub.net/aoclb/internal/types"
+
+// PerStarEngine applies the best modifier of every star to the points earned for that star only
+type PerStarEngine struct{}
+
+func (PerStarEngine) Name() types.AOCScoringMode {
+	return types.ScoringModePerStar
+}
+
+func (PerStarEngine) Score(input *Input) []*Row {
+	rows := make([]*Row, 0, len(input.Leaderboard))
+
+	for id, stars := range starScores(input) {
+		adjustedScore := 0.0
+		for _, star := range stars {
+			star.ModifierPoints = float64(star.Points) * modifierMultiplier(star)
+			adjustedScore += float64(star.Points) + star.ModifierPoints
+		}
+
+		rows = append(rows, &Row{
+			Entry:         input.Leaderboard[id],
+			AdjustedScore: int(adjustedScore),
+			Stars:         stars,
+		})
+	}
+
+	return rank(rows)
+}
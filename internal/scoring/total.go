@@ -0,0 +1,44 @@
+package scoring
+
+import "uocsclub.net/aoclb/internal/types"
+
+// TotalEngine sums the best modifier of every star and applies the sum to the final score,
+// a 5% bonus is worth the same on every star no matter how many points it earned.
+// Like the original scoring, every submission counts even when its star isn't completed
+type TotalEngine struct{}
+
+func (TotalEngine) Name() types.AOCScoringMode {
+	return types.ScoringModeTotal
+}
+
+func (TotalEngine) Score(input *Input) []*Row {
+	rows := make([]*Row, 0, len(input.Leaderboard))
+	modifiers := languageModifiers(input)
+	bestSubmissions := bestSubmissions(input, modifiers)
+
+	for id, stars := range starScores(input) {
+		entry := input.Leaderboard[id]
+
+		scoreMultiplier := 0.0
+		for _, submissions := range bestSubmissions[id] {
+			for _, submission := range submissions {
+				if submission != nil {
+					scoreMultiplier += float64(modifiers[submission.LanguageName]) / 1000
+				}
+			}
+		}
+
+		// the bonus is spread over the stars proportionally to their points to show a breakdown
+		for _, star := range stars {
+			star.ModifierPoints = float64(star.Points) * scoreMultiplier
+		}
+
+		rows = append(rows, &Row{
+			Entry:         entry,
+			AdjustedScore: int(float64(entry.Score) * (1 + scoreMultiplier)),
+			Stars:         stars,
+		})
+	}
+
+	return rank(rows)
+}
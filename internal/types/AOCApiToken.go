@@ -0,0 +1,22 @@
+package types
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+// AOCApiToken lets a user manage their submissions from scripts, only the hash of the token is stored
+type AOCApiToken struct {
+	Id         int
+	AocId      int
+	Name       string
+	Prefix     string // start of the token so users can tell them apart
+	CreatedAt  int    // unix timestamp
+	LastUsedAt int    // unix timestamp, 0 if it was never used
+}
+
+// HashApiToken is how tokens are stored, they are random enough that a plain sha256 can't be reversed
+func HashApiToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -3,44 +3,63 @@ package types
 import (
 	"fmt"
 	"slices"
+	"strconv"
 	"strings"
 )
 
 type AOCData = map[int]*AOCUserLB
 
 type AOCUserLB struct {
-	Year           string
-	User           AOCUser
-	Score          int                    // local LB score
-	Completions    map[int]*AOCCompletion // indexed by day
-	Modifiers      []*AOCUserSubmission
-	_adjustedScore int // computed field
+	Year        string
+	User        AOCUser
+	Score       int                    // local LB score
+	Completions map[int]*AOCCompletion // indexed by day
 }
 
 type AOCUser struct {
-	UserId       int
-	Name         string
-	GithubId     int
-	GithubAvatar string
+	UserId   int
+	Name     string
+	Role     AOCUserRole
+	Identity AOCIdentity // account used to log in, zero if the user isn't linked
 }
 
 type AOCCompletion struct {
-	Star1 bool
-	Star2 bool
+	Star1           bool
+	Star2           bool
+	Star1TS         int // unix timestamp, 0 if the star wasn't obtained
+	Star2TS         int
+	Star1Index      int // AoC star_index, breaks ties between equal timestamps
+	Star2Index      int
+	Star1RecordedTS int // unix timestamp of the fetch that first saw the star, 0 if the member had it when they joined
+	Star2RecordedTS int
 }
 
 type AOCSubmissionModifier struct {
 	LanguageName       string
-	ModifierDecPercent int // %*10, so 2.5% stored as 25
+	ModifierDecPercent int  // %*10, so 2.5% stored as 25
+	Retired            bool // can't be picked for new submissions anymore
 }
 
+type AOCSubmissionStatus string
+
+const (
+	SubmissionPending  AOCSubmissionStatus = "pending"
+	SubmissionApproved AOCSubmissionStatus = "approved"
+	SubmissionRejected AOCSubmissionStatus = "rejected"
+)
+
 type AOCUserSubmission struct {
 	AOCSubmissionModifier
 	AocUserId     int
+	Year          string
 	Id            int
 	SubmissionUrl string
 	Date          int
 	Star          int
+	Status        AOCSubmissionStatus
+	RejectReason  string
+	ReviewerId    int // aoc id of the reviewer, 0 until reviewed
+	ReviewedAt    int // unix timestamp
 }
 
 func SortSubmissionModifiers(modifiers []*AOCSubmissionModifier) {
@@ -57,33 +76,63 @@ func FormatDecPercent(i int) string {
 	return fmt.Sprintf("%d.%d%%", i/10, i%10)
 }
 
-func (lb AOCUserLB) GetAdjustedScore() int {
-	if lb._adjustedScore != 0 || lb.Score == 0 {
-		return lb._adjustedScore
+// ParseDecPercent parses a percentage with at most one decimal like 2.5 or 2.5% into %*10
+func ParseDecPercent(s string) (int, error) {
+	s = strings.TrimSuffix(strings.TrimSpace(s), "%")
+
+	whole, decimal, _ := strings.Cut(s, ".")
+	if len(whole) == 0 {
+		whole = "0"
 	}
-	if len(lb.Modifiers) == 0 {
-		return lb.Score
+	if len(decimal) > 1 {
+		return 0, fmt.Errorf("Too many decimals in %q", s)
 	}
-	bestModifiers := map[int][]int{}
 
-	// loop over all modifiers and pick the best ones for the max score
-	for _, modifier := range lb.Modifiers {
-		if modifier.Star > 2 || modifier.Star < 1 {
-			continue
-		}
-		if bestModifiers[modifier.Date] == nil {
-			bestModifiers[modifier.Date] = []int{0, 0}
-		}
+	i, err := strconv.ParseUint(whole, 10, 16)
+	if err != nil {
+		return 0, err
+	}
 
-		bestModifiers[modifier.Date][modifier.Star-1] = max(bestModifiers[modifier.Date][modifier.Star-1], modifier.ModifierDecPercent)
+	d := uint64(0)
+	if len(decimal) != 0 {
+		d, err = strconv.ParseUint(decimal, 10, 8)
+		if err != nil {
+			return 0, err
+		}
 	}
 
-	scoreMultiplier := 1.0
-	for _, modifier := range bestModifiers {
-		scoreMultiplier += float64(modifier[0])/1000 + float64(modifier[1])/1000
+	return int(i*10 + d), nil
+}
+
+// MergeAOCData combines private leaderboards, members of multiple boards keep their best score.
+// The scores are only comparable once the merged data goes through RescoreAOCData
+func MergeAOCData(leaderboards ...AOCData) AOCData {
+	merged := AOCData{}
+
+	for _, data := range leaderboards {
+		for id, entry := range data {
+			if merged[id] == nil || merged[id].Score < entry.Score {
+				copied := *entry
+				merged[id] = &copied
+			}
+		}
 	}
 
-	lb._adjustedScore = int(float64(lb.Score) * scoreMultiplier)
+	return merged
+}
+
+// CompletedBy returns whether the star was obtained at or before the unix timestamp
+func (c *AOCCompletion) CompletedBy(star int, timestamp int) bool {
+	if c == nil {
+		return false
+	}
 
-	return lb._adjustedScore
+	switch star {
+	case 1:
+		return c.Star1 && c.Star1TS <= timestamp
+	case 2:
+		return c.Star2 && c.Star2TS <= timestamp
+	default:
+		return false
+	}
 }
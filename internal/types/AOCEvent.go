@@ -0,0 +1,78 @@
+package types
+
+import (
+	"strconv"
+	"time"
+)
+
+type AOCScoringMode string
+
+const (
+	// ScoringModeTotal sums the modifiers and applies them to the final score
+	ScoringModeTotal AOCScoringMode = "total"
+	// ScoringModePerStar applies every modifier to the points earned for its star only
+	ScoringModePerStar AOCScoringMode = "per_star"
+)
+
+func ParseScoringMode(mode string) (AOCScoringMode, bool) {
+	switch AOCScoringMode(mode) {
+	case ScoringModeTotal, ScoringModePerStar:
+		return AOCScoringMode(mode), true
+	default:
+		return ScoringModeTotal, false
+	}
+}
+
+type AOCEvent struct {
+	Year          string
+	NumDays       int
+	Day1Timestamp int // unix timestamp of when day 1 unlocks
+	ScoringMode   AOCScoringMode
+}
+
+const aocDayDuration = 24 * time.Hour
+
+// CalendarAOCEvent estimates the event of a year from the calendar, it stands in until the
+// first successful fetch stores the real one: day 1 unlocks December 1st at midnight EST,
+// with 25 days before 2025 and 12 since
+func CalendarAOCEvent(year string) *AOCEvent {
+	y, err := strconv.Atoi(year)
+	if err != nil {
+		return &AOCEvent{Year: year}
+	}
+
+	numDays := 25
+	if y >= 2025 {
+		numDays = 12
+	}
+
+	return &AOCEvent{
+		Year:          year,
+		NumDays:       numDays,
+		Day1Timestamp: int(time.Date(y, time.December, 1, 5, 0, 0, 0, time.UTC).Unix()),
+	}
+}
+
+// UnlockedDays is the number of days that have been released at the given time
+func (e *AOCEvent) UnlockedDays(now time.Time) int {
+	if e == nil || e.Day1Timestamp == 0 {
+		return 0
+	}
+
+	elapsed := now.Sub(time.Unix(int64(e.Day1Timestamp), 0))
+	if elapsed < 0 {
+		return 0
+	}
+
+	return min(int(elapsed/aocDayDuration)+1, e.NumDays)
+}
+
+// Ended reports whether the last day of the event is over at the given time
+func (e *AOCEvent) Ended(now time.Time) bool {
+	if e == nil || e.Day1Timestamp == 0 || e.NumDays == 0 {
+		return false
+	}
+
+	end := time.Unix(int64(e.Day1Timestamp), 0).Add(time.Duration(e.NumDays) * aocDayDuration)
+	return !now.Before(end)
+}
@@ -0,0 +1,58 @@
+package types
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCalendarAOCEvent(t *testing.T) {
+	tests := []struct {
+		year    string
+		numDays int
+		day1    time.Time
+	}{
+		{"2015", 25, time.Date(2015, time.December, 1, 5, 0, 0, 0, time.UTC)},
+		{"2024", 25, time.Date(2024, time.December, 1, 5, 0, 0, 0, time.UTC)},
+		{"2025", 12, time.Date(2025, time.December, 1, 5, 0, 0, 0, time.UTC)},
+		{"2026", 12, time.Date(2026, time.December, 1, 5, 0, 0, 0, time.UTC)},
+	}
+
+	for _, tt := range tests {
+		event := CalendarAOCEvent(tt.year)
+		if event.Year != tt.year || event.NumDays != tt.numDays || event.Day1Timestamp != int(tt.day1.Unix()) {
+			t.Errorf("CalendarAOCEvent(%s) = %+v, want %d days starting %v", tt.year, event, tt.numDays, tt.day1)
+		}
+	}
+
+	if event := CalendarAOCEvent("nope"); event.NumDays != 0 || event.Day1Timestamp != 0 {
+		t.Errorf("CalendarAOCEvent(nope) = %+v, want an empty event", event)
+	}
+}
+
+func TestUnlockedDays(t *testing.T) {
+	event := CalendarAOCEvent("2024")
+	day1 := time.Unix(int64(event.Day1Timestamp), 0)
+
+	tests := []struct {
+		name string
+		now  time.Time
+		want int
+	}{
+		{"before the event", day1.Add(-time.Second), 0},
+		{"day 1 unlock", day1, 1},
+		{"just before day 2", day1.Add(24*time.Hour - time.Second), 1},
+		{"day 2 unlock", day1.Add(24 * time.Hour), 2},
+		{"after the event", day1.AddDate(1, 0, 0), 25},
+	}
+
+	for _, tt := range tests {
+		if got := event.UnlockedDays(tt.now); got != tt.want {
+			t.Errorf("%s: UnlockedDays = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+
+	var missing *AOCEvent
+	if got := missing.UnlockedDays(day1); got != 0 {
+		t.Errorf("nil event: UnlockedDays = %d, want 0", got)
+	}
+}
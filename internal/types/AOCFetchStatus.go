@@ -0,0 +1,45 @@
+package types
+
+type AOCFetchErrorKind string
+
+const (
+	FetchErrorNone           AOCFetchErrorKind = ""
+	FetchErrorSessionExpired AOCFetchErrorKind = "session_expired"
+	FetchErrorNotFound       AOCFetchErrorKind = "not_found"
+	FetchErrorRateLimited    AOCFetchErrorKind = "rate_limited"
+	FetchErrorUnavailable    AOCFetchErrorKind = "unavailable"
+	FetchErrorMalformed      AOCFetchErrorKind = "malformed"
+)
+
+// Description is a short explanation of the error kind meant for the people running the app
+func (k AOCFetchErrorKind) Description() string {
+	switch k {
+	case FetchErrorNone:
+		return ""
+	case FetchErrorSessionExpired:
+		return "session expired"
+	case FetchErrorNotFound:
+		return "leaderboard not found"
+	case FetchErrorRateLimited:
+		return "rate limited by AoC"
+	case FetchErrorMalformed:
+		return "AoC returned unexpected data"
+	default:
+		return "AoC is unreachable"
+	}
+}
+
+// AOCFetchStatus tracks the fetches of a private leaderboard so we can respect AOC's rate limits
+type AOCFetchStatus struct {
+	LeaderboardId       string
+	Year                string
+	LastAttempt         int // unix timestamp
+	LastSuccess         int // unix timestamp, 0 if it was never fetched
+	StatusCode          int // http status of the last attempt, 0 if the request didn't go through
+	Error               string
+	ErrorKind           AOCFetchErrorKind
+	ConsecutiveFailures int
+	RetryAfter          int    // unix timestamp from the Retry-After of a rate limited attempt, 0 without one
+	ETag                string // sent back to AOC so unchanged leaderboards don't have to be downloaded again
+	LastModified        string
+}
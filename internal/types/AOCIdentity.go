@@ -0,0 +1,23 @@
+package types
+
+import "fmt"
+
+// AOCIdentity is an account of an identity provider (github, oidc) used to log in
+type AOCIdentity struct {
+	Provider  string // name of the provider, empty if the user isn't linked
+	Subject   string // id of the account at the provider
+	AvatarUrl string
+}
+
+func (i AOCIdentity) Linked() bool {
+	return len(i.Provider) != 0
+}
+
+// Is reports whether both identities are the same account, avatars aside
+func (i AOCIdentity) Is(other AOCIdentity) bool {
+	return i.Provider == other.Provider && i.Subject == other.Subject
+}
+
+func (i AOCIdentity) String() string {
+	return fmt.Sprintf("%s:%s", i.Provider, i.Subject)
+}
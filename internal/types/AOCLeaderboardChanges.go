@@ -0,0 +1,21 @@
+package types
+
+// AOCLeaderboardChanges is what storing a fetch of a private leaderboard changed
+type AOCLeaderboardChanges struct {
+	LeaderboardId string
+	Year          string
+	NewStars      []*AOCNewStar
+	Joined        []int // aoc ids of the members new to the leaderboard
+	Left          []int
+}
+
+type AOCNewStar struct {
+	UserId int
+	Day    int
+	Star   int
+	StarTS int // unix timestamp the star was obtained at
+}
+
+func (c *AOCLeaderboardChanges) Changed() bool {
+	return len(c.NewStars) != 0 || len(c.Joined) != 0 || len(c.Left) != 0
+}
@@ -0,0 +1,10 @@
+package types
+
+// AOCLeaderboardSnapshot is a raw private leaderboard payload as it was fetched from AOC
+type AOCLeaderboardSnapshot struct {
+	Id            int
+	LeaderboardId string
+	Year          string
+	FetchedAt     int    // unix timestamp
+	Hash          string // sha256 of the payload
+}
@@ -0,0 +1,19 @@
+package types
+
+import "time"
+
+// LinkRequestExpiry is how long a user has to put the token in their AoC name
+const LinkRequestExpiry = 24 * time.Hour
+
+// AOCLinkRequest is an identity asking to be linked to an aoc account, it is linked
+// once a fetch sees the token in the name of the aoc account
+type AOCLinkRequest struct {
+	Identity  AOCIdentity
+	AocId     int
+	Token     string
+	CreatedAt int // unix timestamp
+}
+
+func (r *AOCLinkRequest) Expired(now time.Time) bool {
+	return now.Sub(time.Unix(int64(r.CreatedAt), 0)) > LinkRequestExpiry
+}
@@ -0,0 +1,83 @@
+package types
+
+import (
+	"cmp"
+	"slices"
+)
+
+// AOCStarPoints are the points a member got for each star, indexed by day then star-1
+type AOCStarPoints = map[int]*[2]int
+
+// ComputeStarPoints reimplements the AOC private leaderboard scoring. For every star, the first
+// member to get it gets one point per member of the leaderboard, the second one point less, and so on.
+// Stars of excluded days don't award any points, like AOC does when a day had an outage
+func ComputeStarPoints(data AOCData, numDays int, excludedDays []int) map[int]AOCStarPoints {
+	memberCount := len(data)
+	points := map[int]AOCStarPoints{}
+	for id := range data {
+		points[id] = AOCStarPoints{}
+	}
+
+	type solve struct {
+		userId int
+		ts     int
+		index  int
+	}
+
+	for day := 1; day <= numDays; day++ {
+		if slices.Contains(excludedDays, day) {
+			continue
+		}
+
+		for star := 1; star <= 2; star++ {
+			solves := []solve{}
+			for id, entry := range data {
+				completion := entry.Completions[day]
+				if completion == nil {
+					continue
+				}
+				if star == 1 && completion.Star1 {
+					solves = append(solves, solve{id, completion.Star1TS, completion.Star1Index})
+				}
+				if star == 2 && completion.Star2 {
+					solves = append(solves, solve{id, completion.Star2TS, completion.Star2Index})
+				}
+			}
+
+			slices.SortFunc(solves, func(a, b solve) int {
+				return cmp.Or(cmp.Compare(a.ts, b.ts), cmp.Compare(a.index, b.index))
+			})
+
+			for rank, solve := range solves {
+				if points[solve.userId][day] == nil {
+					points[solve.userId][day] = &[2]int{}
+				}
+				points[solve.userId][day][star-1] = memberCount - rank
+			}
+		}
+	}
+
+	return points
+}
+
+// ComputeLocalScores returns the local score of every member, see ComputeStarPoints
+func ComputeLocalScores(data AOCData, numDays int, excludedDays []int) map[int]int {
+	scores := map[int]int{}
+
+	for id, starPoints := range ComputeStarPoints(data, numDays, excludedDays) {
+		scores[id] = 0
+		for _, dayPoints := range starPoints {
+			scores[id] += dayPoints[0] + dayPoints[1]
+		}
+	}
+
+	return scores
+}
+
+// RescoreAOCData replaces the scores from AOC with our own, used when merging members of
+// different private leaderboards since AOC only scores them against their own board
+func RescoreAOCData(data AOCData, numDays int, excludedDays []int) {
+	for id, score := range ComputeLocalScores(data, numDays, excludedDays) {
+		data[id].Score = score
+	}
+}
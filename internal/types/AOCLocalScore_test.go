@@ -0,0 +1,147 @@
+package types_test
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"uocsclub.net/aoclb/internal/fetcher"
+	"uocsclub.net/aoclb/internal/types"
+)
+
+// loadLeaderboard reads a leaderboard in the format of the AoC private leaderboard api
+func loadLeaderboard(t *testing.T, path string) *fetcher.AOCResponseLeaderboard {
+	t.Helper()
+
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	leaderboard := &fetcher.AOCResponseLeaderboard{}
+	err = json.Unmarshal(raw, leaderboard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if leaderboard.NumDays == 0 {
+		leaderboard.NumDays = 25
+	}
+
+	return leaderboard
+}
+
+// checkLocalScores compares the computed scores to the local_score of every member
+func checkLocalScores(t *testing.T, leaderboard *fetcher.AOCResponseLeaderboard) {
+	t.Helper()
+
+	scores := types.ComputeLocalScores(leaderboard.ToAOCData(), leaderboard.NumDays, nil)
+	if len(scores) != len(leaderboard.Members) {
+		t.Fatalf("got %d scores for %d members", len(scores), len(leaderboard.Members))
+	}
+	for _, member := range leaderboard.Members {
+		if scores[member.Id] != member.LocalScore {
+			t.Errorf("member %d: got %d, want %d", member.Id, scores[member.Id], member.LocalScore)
+		}
+	}
+}
+
+// testdata/aoc holds real anonymized responses, see its README. The constructed leaderboards are
+// only as right as our reading of AoC's rules, so this one must have something to check against
+func TestComputeLocalScoresMatchesAoC(t *testing.T) {
+	paths, err := filepath.Glob("testdata/aoc/*.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(paths) == 0 {
+		t.Fatal("no AoC responses in testdata/aoc, add one as described in its README")
+	}
+
+	for _, path := range paths {
+		t.Run(filepath.Base(path), func(t *testing.T) {
+			checkLocalScores(t, loadLeaderboard(t, path))
+		})
+	}
+}
+
+// The constructed leaderboards cover the edge cases, their local_score was worked out by hand from
+// AoC's rules. constructed_2025.json has 12 days, tied timestamps on day 1, members missing stars and
+// one without any. constructed_2024.json predates num_days and has stars on days 24 and 25
+func TestComputeLocalScoresConstructed(t *testing.T) {
+	for _, name := range []string{"constructed_2025.json", "constructed_2024.json"} {
+		t.Run(name, func(t *testing.T) {
+			checkLocalScores(t, loadLeaderboard(t, "testdata/"+name))
+		})
+	}
+}
+
+func TestComputeStarPoints(t *testing.T) {
+	leaderboard := loadLeaderboard(t, "testdata/constructed_2025.json")
+	points := types.ComputeStarPoints(leaderboard.ToAOCData(), leaderboard.NumDays, nil)
+
+	tests := []struct {
+		name   string
+		userId int
+		day    int
+		want   *[2]int
+	}{
+		// same timestamps as bob on both stars, the star_index decides
+		{"tie won on star 1", 101, 1, &[2]int{5, 4}},
+		{"tie won on star 2", 102, 1, &[2]int{4, 5}},
+		{"missing star 2", 102, 2, &[2]int{4, 0}},
+		{"only star 1", 103, 1, &[2]int{3, 0}},
+		{"day not solved", 103, 2, nil},
+		{"no stars", 104, 1, nil},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := points[test.userId][test.day]
+			if (got == nil) != (test.want == nil) || (got != nil && *got != *test.want) {
+				t.Errorf("got %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestComputeLocalScoresDays(t *testing.T) {
+	leaderboard := loadLeaderboard(t, "testdata/constructed_2025.json")
+	data := leaderboard.ToAOCData()
+
+	tests := []struct {
+		name         string
+		numDays      int
+		excludedDays []int
+		want         map[int]int
+	}{
+		{"days after num_days", 2, nil, map[int]int{101: 19, 102: 13, 103: 3, 104: 0, 105: 0}},
+		{"excluded day", 12, []int{1}, map[int]int{101: 10, 102: 4, 103: 0, 104: 0, 105: 10}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			scores := types.ComputeLocalScores(data, test.numDays, test.excludedDays)
+			for id, want := range test.want {
+				if scores[id] != want {
+					t.Errorf("member %d: got %d, want %d", id, scores[id], want)
+				}
+			}
+		})
+	}
+}
+
+func TestRescoreAOCData(t *testing.T) {
+	leaderboard := loadLeaderboard(t, "testdata/constructed_2024.json")
+	data := leaderboard.ToAOCData()
+	for _, entry := range data {
+		entry.Score = -1
+	}
+
+	types.RescoreAOCData(data, leaderboard.NumDays, nil)
+
+	for _, member := range leaderboard.Members {
+		if data[member.Id].Score != member.LocalScore {
+			t.Errorf("member %d: got %d, want %d", member.Id, data[member.Id].Score, member.LocalScore)
+		}
+	}
+}
@@ -0,0 +1,30 @@
+package types
+
+// AOCModifierSet is the version of the language modifiers used for a year
+type AOCModifierSet struct {
+	Year   string
+	Locked bool // locked once the season ends so past leaderboards can't be rescored
+}
+
+type AOCModifierAction string
+
+const (
+	ModifierActionAdd      AOCModifierAction = "add"
+	ModifierActionRename   AOCModifierAction = "rename"
+	ModifierActionReweight AOCModifierAction = "reweight"
+	ModifierActionRetire   AOCModifierAction = "retire"
+	ModifierActionRestore  AOCModifierAction = "restore"
+)
+
+// AOCModifierAudit is a change made by an admin to the modifier set of a year
+type AOCModifierAudit struct {
+	Id           int
+	Year         string
+	Timestamp    int
+	UserId       int // aoc id of the admin
+	UserName     string
+	Action       AOCModifierAction
+	LanguageName string // name of the language after the change
+	OldValue     string
+	NewValue     string
+}
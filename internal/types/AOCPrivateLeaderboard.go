@@ -0,0 +1,9 @@
+package types
+
+// AOCPrivateLeaderboard is one of the AOC private leaderboards tracked by the app
+type AOCPrivateLeaderboard struct {
+	Id         string // AOC private leaderboard id
+	Year       string
+	Name       string
+	SessionEnv string // env variable holding the session cookie of a member of the board
+}
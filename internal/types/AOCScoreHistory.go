@@ -0,0 +1,14 @@
+package types
+
+type AOCScorePoint struct {
+	Timestamp     int // unix timestamp
+	Score         int
+	AdjustedScore int
+	Rank          int // 1 is first place
+}
+
+// AOCScoreHistory is the score of a member at different points of the event
+type AOCScoreHistory struct {
+	User   AOCUser
+	Points []AOCScorePoint
+}
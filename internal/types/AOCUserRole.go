@@ -0,0 +1,32 @@
+package types
+
+import "slices"
+
+type AOCUserRole string
+
+const (
+	RoleMember   AOCUserRole = "member"
+	RoleReviewer AOCUserRole = "reviewer"
+	RoleAdmin    AOCUserRole = "admin"
+)
+
+// UserRoles are ordered from the least to the most privileged
+var UserRoles = []AOCUserRole{RoleMember, RoleReviewer, RoleAdmin}
+
+func ParseUserRole(role string) (AOCUserRole, bool) {
+	if slices.Contains(UserRoles, AOCUserRole(role)) {
+		return AOCUserRole(role), true
+	}
+
+	return RoleMember, false
+}
+
+// Includes reports whether the role is allowed everything the other role is, admins are also reviewers
+func (r AOCUserRole) Includes(other AOCUserRole) bool {
+	required := slices.Index(UserRoles, other)
+	if required < 0 {
+		return false
+	}
+
+	return slices.Index(UserRoles, r) >= required
+}
@@ -0,0 +1,40 @@
+package types
+
+import "slices"
+
+// AOCWebhook gets the events it subscribed to as signed JSON posts
+type AOCWebhook struct {
+	Id        int
+	Url       string
+	Secret    string   // key of the HMAC-SHA256 signature of the deliveries
+	Events    []string // event types, see notify.WebhookEvents
+	Active    bool
+	CreatedAt int // unix timestamp
+}
+
+func (w *AOCWebhook) Subscribed(event string) bool {
+	return w.Active && slices.Contains(w.Events, event)
+}
+
+type AOCWebhookDeliveryStatus string
+
+const (
+	DeliveryPending   AOCWebhookDeliveryStatus = "pending"
+	DeliveryDelivered AOCWebhookDeliveryStatus = "delivered"
+	DeliveryFailed    AOCWebhookDeliveryStatus = "failed" // ran out of attempts
+)
+
+// AOCWebhookDelivery is an event sent to a webhook, along with how its attempts went
+type AOCWebhookDelivery struct {
+	Id             int
+	WebhookId      int
+	Event          string
+	Payload        string // JSON body
+	Status         AOCWebhookDeliveryStatus
+	Attempts       int
+	NextAttemptAt  int    // unix timestamp
+	ResponseStatus int    // http status of the last attempt, 0 without a response
+	Error          string // why the last attempt failed
+	CreatedAt      int
+	DeliveredAt    int // 0 until delivered
+}
@@ -0,0 +1,268 @@
+package web
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"uocsclub.net/aoclb/internal/auth"
+	"uocsclub.net/aoclb/internal/database"
+	"uocsclub.net/aoclb/internal/types"
+	"uocsclub.net/aoclb/internal/web/templates"
+)
+
+type adminModifierFormBody struct {
+	LanguageName string `form:"language"` // current name of the language, empty when adding one
+	NewName      string `form:"name"`
+	Modifier     string `form:"modifier"` // percentage like 2.5
+	Retired      bool   `form:"retired"`
+}
+
+func (s *Server) HandleAdminModifiersGet(c *fiber.Ctx) error {
+	year, ok := s.getYear(c)
+	if !ok {
+		return c.SendStatus(http.StatusNotFound)
+	}
+
+	return s.renderAdminModifiers(c, year, "")
+}
+
+func (s *Server) HandleAdminModifiersPost(c *fiber.Ctx) error {
+	year, ok := s.getYear(c)
+	if !ok {
+		return c.SendStatus(http.StatusNotFound)
+	}
+
+	modifier, formErr, err := parseAdminModifierForm(c)
+	if err != nil {
+		log.Println(err)
+		return c.SendStatus(http.StatusUnprocessableEntity)
+	}
+	if len(formErr) != 0 {
+		return s.renderAdminModifiers(c, year, formErr)
+	}
+
+	err = s.db.AddModifier(year, modifier, s.sessionUserId(c))
+	if formErr, ok := adminModifierError(err); ok {
+		return s.renderAdminModifiers(c, year, formErr)
+	}
+	if err != nil {
+		log.Println(err)
+		return c.SendStatus(http.StatusInternalServerError)
+	}
+
+	return s.renderAdminModifiers(c, year, "")
+}
+
+func (s *Server) HandleAdminModifiersPatch(c *fiber.Ctx) error {
+	year, ok := s.getYear(c)
+	if !ok {
+		return c.SendStatus(http.StatusNotFound)
+	}
+
+	modifier, formErr, err := parseAdminModifierForm(c)
+	if err != nil {
+		log.Println(err)
+		return c.SendStatus(http.StatusUnprocessableEntity)
+	}
+	if len(formErr) != 0 {
+		return s.renderAdminModifiers(c, year, formErr)
+	}
+
+	err = s.db.UpdateModifier(year, c.FormValue("language"), modifier, s.sessionUserId(c))
+	if formErr, ok := adminModifierError(err); ok {
+		return s.renderAdminModifiers(c, year, formErr)
+	}
+	if err != nil {
+		log.Println(err)
+		return c.SendStatus(http.StatusInternalServerError)
+	}
+
+	c.Set("HX-Trigger", "refresh-leaderboard")
+	return s.renderAdminModifiers(c, year, "")
+}
+
+func (s *Server) renderAdminModifiers(c *fiber.Ctx, year string, formErr string) error {
+	modifiers, err := s.db.GetModifiers(year)
+	if err != nil {
+		log.Println(err)
+		return c.SendStatus(http.StatusInternalServerError)
+	}
+
+	set, err := s.db.GetModifierSet(year)
+	if err != nil {
+		log.Println(err)
+		return c.SendStatus(http.StatusInternalServerError)
+	}
+
+	audit, err := s.db.GetModifierAudit(year)
+	if err != nil {
+		log.Println(err)
+		return c.SendStatus(http.StatusInternalServerError)
+	}
+
+	locked := set != nil && set.Locked
+	if c.Method() != fiber.MethodGet {
+		return s.Render(c, templates.AdminModifiers(year, modifiers, locked, audit, formErr))
+	}
+
+	return s.Render(c, templates.AdminModifiersPage(year, modifiers, locked, audit))
+}
+
+// parseAdminModifierForm returns the modifier described by the form, or the reason it is invalid
+func parseAdminModifierForm(c *fiber.Ctx) (*types.AOCSubmissionModifier, string, error) {
+	data := &adminModifierFormBody{}
+	err := c.BodyParser(data)
+	if err != nil {
+		return nil, "", err
+	}
+
+	name := strings.TrimSpace(data.NewName)
+	if len(name) == 0 {
+		return nil, "Missing language name", nil
+	}
+
+	percent, err := types.ParseDecPercent(data.Modifier)
+	if err != nil {
+		return nil, "Invalid modifier, use a percentage like 2.5", nil
+	}
+
+	return &types.AOCSubmissionModifier{
+		LanguageName:       name,
+		ModifierDecPercent: percent,
+		Retired:            data.Retired,
+	}, "", nil
+}
+
+// adminModifierError turns the errors caused by the admin into a message for the form
+func adminModifierError(err error) (string, bool) {
+	switch {
+	case errors.Is(err, database.ErrModifierSetLocked):
+		return "The season is over, its modifiers can't change anymore", true
+	case errors.Is(err, database.ErrModifierExists):
+		return "That language already has a modifier", true
+	case errors.Is(err, database.ErrModifierNotFound):
+		return "That language doesn't have a modifier", true
+	default:
+		return "", false
+	}
+}
+
+// sessionUserId is the aoc id of the logged in user, 0 if there is none
+func (s *Server) sessionUserId(c *fiber.Ctx) int {
+	user := s.SessionUser(c)
+	if user == nil {
+		return 0
+	}
+
+	return user.UserId
+}
+
+type adminUserFormBody struct {
+	AocId    int    `form:"aoc-id"`
+	Role     string `form:"role"`
+	Provider string `form:"provider"`
+	Subject  string `form:"subject"`
+}
+
+func (s *Server) HandleAdminUsersGet(c *fiber.Ctx) error {
+	users, err := s.db.GetLinkedUsers()
+	if err != nil {
+		log.Println(err)
+		return c.SendStatus(http.StatusInternalServerError)
+	}
+
+	return s.Render(c, templates.AdminUsersPage(s.config.Year, users, s.config.Providers))
+}
+
+func (s *Server) HandleAdminUsersPatch(c *fiber.Ctx) error {
+	data := &adminUserFormBody{}
+	err := c.BodyParser(data)
+	if err != nil {
+		log.Println(err)
+		return c.SendStatus(http.StatusUnprocessableEntity)
+	}
+
+	role, ok := types.ParseUserRole(data.Role)
+	if !ok {
+		return c.SendStatus(http.StatusUnprocessableEntity)
+	}
+
+	user, err := s.db.GetUserByAocId(data.AocId)
+	if err != nil {
+		log.Println(err)
+		return c.SendStatus(http.StatusInternalServerError)
+	}
+	if user == nil || !user.Identity.Linked() {
+		return c.SendStatus(http.StatusNotFound)
+	}
+
+	// an admin demoting themselves could leave nobody able to undo it
+	if sessionUser := s.SessionUser(c); sessionUser != nil && sessionUser.UserId == user.UserId {
+		return s.Render(c, templates.AdminUser(user, "You can't change your own role"))
+	}
+
+	err = s.db.SetUserRole(user.UserId, role)
+	if err != nil {
+		log.Println(err)
+		return c.SendStatus(http.StatusInternalServerError)
+	}
+	user.Role = role
+
+	return s.Render(c, templates.AdminUser(user, ""))
+}
+
+// HandleAdminUsersLink links accounts without the ownership proof, for users who can't change their AoC name
+func (s *Server) HandleAdminUsersLink(c *fiber.Ctx) error {
+	data := &adminUserFormBody{}
+	err := c.BodyParser(data)
+	data.Subject = strings.TrimSpace(data.Subject)
+	if err != nil || data.AocId == 0 || auth.Find(s.config.Providers, data.Provider) == nil || len(data.Subject) == 0 {
+		return s.renderAdminUsers(c, "Invalid AoC Id, provider or subject")
+	}
+
+	identity := types.AOCIdentity{Provider: data.Provider, Subject: data.Subject}
+	_, err = s.db.ForceLinkUser(identity, data.AocId)
+	if errors.Is(err, database.ErrUserNotFound) {
+		return s.renderAdminUsers(c, "That AoC Id isn't on any of our leaderboards")
+	}
+	if err != nil {
+		log.Println(err)
+		return c.SendStatus(http.StatusInternalServerError)
+	}
+
+	return s.renderAdminUsers(c, "")
+}
+
+func (s *Server) renderAdminUsers(c *fiber.Ctx, formErr string) error {
+	users, err := s.db.GetLinkedUsers()
+	if err != nil {
+		log.Println(err)
+		return c.SendStatus(http.StatusInternalServerError)
+	}
+
+	return s.Render(c, templates.AdminUsers(users, s.config.Providers, formErr))
+}
+
+func (s *Server) HandleAdminUsersUnlink(c *fiber.Ctx) error {
+	data := &adminUserFormBody{}
+	err := c.BodyParser(data)
+	if err != nil {
+		log.Println(err)
+		return c.SendStatus(http.StatusUnprocessableEntity)
+	}
+
+	if sessionUser := s.SessionUser(c); sessionUser != nil && sessionUser.UserId == data.AocId {
+		return s.renderAdminUsers(c, "Unlink your own account from your settings")
+	}
+
+	err = s.db.UnlinkUser(data.AocId)
+	if err != nil {
+		log.Println(err)
+		return c.SendStatus(http.StatusInternalServerError)
+	}
+
+	return s.renderAdminUsers(c, "")
+}
@@ -0,0 +1,225 @@
+package web
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/gofiber/fiber/v2"
+	"uocsclub.net/aoclb/internal/scoring"
+	"uocsclub.net/aoclb/internal/types"
+)
+
+// The api mirrors the pages as JSON for bots and other sites, changing a field means a new version
+
+type apiLeaderboard struct {
+	Year          string               `json:"year"`
+	LeaderboardId string               `json:"leaderboard_id,omitempty"` // empty when every board is merged
+	ScoringMode   types.AOCScoringMode `json:"scoring_mode"`
+	NumDays       int                  `json:"num_days"`
+	Members       []*apiMember         `json:"members"`
+}
+
+type apiMember struct {
+	Rank          int        `json:"rank"`
+	AocId         int        `json:"aoc_id"`
+	Name          string     `json:"name"`
+	Score         int        `json:"score"`          // adjusted by the modifiers of pending and approved submissions
+	VerifiedScore int        `json:"verified_score"` // adjusted by the modifiers of approved submissions only
+	RawScore      int        `json:"raw_score"`      // local score given by AoC
+	StarCount     int        `json:"star_count"`
+	Stars         []*apiStar `json:"stars"`
+}
+
+type apiStar struct {
+	Day            int     `json:"day"`
+	Star           int     `json:"star"`
+	CompletedAt    int     `json:"completed_at"` // unix timestamp
+	Points         int     `json:"points"`
+	Language       string  `json:"language,omitempty"` // language of the submission applied, empty without one
+	Modifier       float64 `json:"modifier"`           // percentage
+	ModifierPoints float64 `json:"modifier_points"`
+}
+
+type apiModifiers struct {
+	Year      string         `json:"year"`
+	Locked    bool           `json:"locked"`
+	Modifiers []*apiModifier `json:"modifiers"`
+}
+
+type apiModifier struct {
+	Language string  `json:"language"`
+	Modifier float64 `json:"modifier"` // percentage
+	Retired  bool    `json:"retired"`
+}
+
+type apiUser struct {
+	AocId    int        `json:"aoc_id"`
+	Name     string     `json:"name"`
+	Year     string     `json:"year"`
+	Standing *apiMember `json:"standing"` // null if the user isn't on the leaderboards of the year
+}
+
+type apiErrorBody struct {
+	Error string `json:"error"`
+}
+
+func apiError(c *fiber.Ctx, status int, message string) error {
+	return c.Status(status).JSON(apiErrorBody{Error: message})
+}
+
+func (s *Server) HandleApiOpenAPI(c *fiber.Ctx) error {
+	c.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+	return c.Send(OpenAPIDocument)
+}
+
+func (s *Server) HandleApiLeaderboard(c *fiber.Ctx) error {
+	year, ok := s.getYear(c)
+	if !ok {
+		return apiError(c, http.StatusNotFound, "unknown year")
+	}
+
+	leaderboard, ok := s.getPrivateLeaderboard(c, year)
+	if !ok {
+		return apiError(c, http.StatusNotFound, "unknown leaderboard")
+	}
+
+	leaderboardId := ""
+	if leaderboard != nil {
+		leaderboardId = leaderboard.Id
+	}
+
+	scored, err := s.scoreLeaderboard(year, leaderboardId)
+	if err != nil {
+		log.Println(err)
+		return apiError(c, http.StatusInternalServerError, "internal error")
+	}
+
+	members := make([]*apiMember, 0, len(scored.Rows))
+	for _, row := range scored.Rows {
+		members = append(members, newApiMember(row, scored.VerifiedScores))
+	}
+
+	return c.JSON(apiLeaderboard{
+		Year:          year,
+		LeaderboardId: leaderboardId,
+		ScoringMode:   scored.Event.ScoringMode,
+		NumDays:       scored.Event.NumDays,
+		Members:       members,
+	})
+}
+
+func (s *Server) HandleApiModifiers(c *fiber.Ctx) error {
+	year, ok := s.getYear(c)
+	if !ok {
+		return apiError(c, http.StatusNotFound, "unknown year")
+	}
+
+	modifiers, err := s.db.GetModifiers(year)
+	if err != nil {
+		log.Println(err)
+		return apiError(c, http.StatusInternalServerError, "internal error")
+	}
+
+	set, err := s.db.GetModifierSet(year)
+	if err != nil {
+		log.Println(err)
+		return apiError(c, http.StatusInternalServerError, "internal error")
+	}
+
+	types.SortSubmissionModifiers(modifiers)
+	output := make([]*apiModifier, 0, len(modifiers))
+	for _, modifier := range modifiers {
+		output = append(output, &apiModifier{
+			Language: modifier.LanguageName,
+			Modifier: decPercent(modifier.ModifierDecPercent),
+			Retired:  modifier.Retired,
+		})
+	}
+
+	return c.JSON(apiModifiers{
+		Year:      year,
+		Locked:    set != nil && set.Locked,
+		Modifiers: output,
+	})
+}
+
+func (s *Server) HandleApiUser(c *fiber.Ctx) error {
+	year, ok := s.getYear(c)
+	if !ok {
+		return apiError(c, http.StatusNotFound, "unknown year")
+	}
+
+	aocId, err := c.ParamsInt("aocId")
+	if err != nil {
+		return apiError(c, http.StatusBadRequest, "invalid aoc id")
+	}
+
+	user, err := s.db.GetUserByAocId(aocId)
+	if err != nil {
+		log.Println(err)
+		return apiError(c, http.StatusInternalServerError, "internal error")
+	}
+	if user == nil {
+		return apiError(c, http.StatusNotFound, "unknown user")
+	}
+
+	scored, err := s.scoreLeaderboard(year, "")
+	if err != nil {
+		log.Println(err)
+		return apiError(c, http.StatusInternalServerError, "internal error")
+	}
+
+	output := apiUser{
+		AocId: user.UserId,
+		Name:  user.Name,
+		Year:  year,
+	}
+	for _, row := range scored.Rows {
+		if row.Entry.User.UserId == user.UserId {
+			output.Standing = newApiMember(row, scored.VerifiedScores)
+		}
+	}
+
+	return c.JSON(output)
+}
+
+func newApiMember(row *scoring.Row, verifiedScores map[int]int) *apiMember {
+	member := &apiMember{
+		Rank:          row.Rank,
+		AocId:         row.Entry.User.UserId,
+		Name:          row.Entry.User.Name,
+		Score:         row.AdjustedScore,
+		VerifiedScore: verifiedScores[row.Entry.User.UserId],
+		RawScore:      row.Entry.Score,
+		StarCount:     len(row.Stars),
+		Stars:         make([]*apiStar, 0, len(row.Stars)),
+	}
+
+	for _, score := range row.Stars {
+		star := &apiStar{
+			Day:            score.Day,
+			Star:           score.Star,
+			Points:         score.Points,
+			Modifier:       decPercent(score.Modifier),
+			ModifierPoints: score.ModifierPoints,
+		}
+		if completion := row.Entry.Completions[score.Day]; completion != nil {
+			star.CompletedAt = completion.Star1TS
+			if score.Star == 2 {
+				star.CompletedAt = completion.Star2TS
+			}
+		}
+		if score.Submission != nil {
+			star.Language = score.Submission.LanguageName
+		}
+
+		member.Stars = append(member.Stars, star)
+	}
+
+	return member
+}
+
+// decPercent turns a %*10 modifier into a percentage
+func decPercent(i int) float64 {
+	return float64(i) / 10
+}
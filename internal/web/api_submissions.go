@@ -0,0 +1,234 @@
+package web
+
+import (
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"uocsclub.net/aoclb/internal/events"
+	"uocsclub.net/aoclb/internal/types"
+)
+
+// apiUserKey holds the user authenticated by RequireApiToken
+const apiUserKey = "api_user"
+
+type apiSubmission struct {
+	Id           int                       `json:"id"`
+	Year         string                    `json:"year"`
+	Day          int                       `json:"day"`
+	Star         int                       `json:"star"`
+	Language     string                    `json:"language"`
+	Modifier     float64                   `json:"modifier"` // percentage
+	Url          string                    `json:"url"`
+	Status       types.AOCSubmissionStatus `json:"status"`
+	RejectReason string                    `json:"reject_reason,omitempty"`
+}
+
+// apiSubmissionBody creates a submission, or changes the fields set of an existing one
+type apiSubmissionBody struct {
+	Day      int    `json:"day"`
+	Star     int    `json:"star"`
+	Language string `json:"language"`
+	Url      string `json:"url"`
+}
+
+// RequireApiToken is a middleware authenticating the requests with the bearer api token of a user,
+// the session cookie is ignored so these routes don't need csrf tokens
+func (s *Server) RequireApiToken(c *fiber.Ctx) error {
+	token, ok := strings.CutPrefix(c.Get(fiber.HeaderAuthorization), "Bearer ")
+	if !ok || len(strings.TrimSpace(token)) == 0 {
+		c.Set(fiber.HeaderWWWAuthenticate, "Bearer")
+		return apiError(c, http.StatusUnauthorized, "missing bearer token")
+	}
+
+	user, err := s.db.GetUserByApiToken(types.HashApiToken(strings.TrimSpace(token)))
+	if err != nil {
+		log.Println(err)
+		return apiError(c, http.StatusInternalServerError, "internal error")
+	}
+	if user == nil {
+		c.Set(fiber.HeaderWWWAuthenticate, "Bearer")
+		return apiError(c, http.StatusUnauthorized, "invalid bearer token")
+	}
+
+	c.Locals(apiUserKey, user)
+	return c.Next()
+}
+
+func tokenUser(c *fiber.Ctx) *types.AOCUser {
+	user, _ := c.Locals(apiUserKey).(*types.AOCUser)
+	return user
+}
+
+func (s *Server) HandleApiSubmissionsGet(c *fiber.Ctx) error {
+	year, ok := s.getYear(c)
+	if !ok {
+		return apiError(c, http.StatusNotFound, "unknown year")
+	}
+
+	submissions, err := s.db.GetUserSubmissions(year, tokenUser(c).UserId)
+	if err != nil {
+		log.Println(err)
+		return apiError(c, http.StatusInternalServerError, "internal error")
+	}
+
+	output := make([]*apiSubmission, 0, len(submissions))
+	for _, submission := range submissions {
+		output = append(output, newApiSubmission(submission))
+	}
+
+	return c.JSON(output)
+}
+
+func (s *Server) HandleApiSubmissionsPost(c *fiber.Ctx) error {
+	year, ok := s.getYear(c)
+	if !ok {
+		return apiError(c, http.StatusNotFound, "unknown year")
+	}
+
+	data := &apiSubmissionBody{}
+	err := c.BodyParser(data)
+	if err != nil {
+		return apiError(c, http.StatusBadRequest, "invalid body")
+	}
+
+	submission := &types.AOCUserSubmission{
+		AOCSubmissionModifier: types.AOCSubmissionModifier{LanguageName: data.Language},
+		AocUserId:             tokenUser(c).UserId,
+		Year:                  year,
+		SubmissionUrl:         data.Url,
+		Date:                  data.Day,
+		Star:                  data.Star,
+	}
+
+	ok, err = s.checkApiSubmission(c, submission, "")
+	if !ok {
+		return err
+	}
+
+	submission, err = s.db.AddUserSubmission(year, submission)
+	if err != nil {
+		log.Println(err)
+		return apiError(c, http.StatusInternalServerError, "internal error")
+	}
+	s.publishSubmission(events.SubmissionCreated, events.NewSubmissionData(tokenUser(c), submission))
+
+	return c.Status(http.StatusCreated).JSON(newApiSubmission(submission))
+}
+
+func (s *Server) HandleApiSubmissionsPatch(c *fiber.Ctx) error {
+	oldSubmission, ok, err := s.getApiSubmission(c)
+	if !ok {
+		return err
+	}
+
+	data := &apiSubmissionBody{}
+	err = c.BodyParser(data)
+	if err != nil {
+		return apiError(c, http.StatusBadRequest, "invalid body")
+	}
+
+	submission := *oldSubmission
+	if data.Day != 0 {
+		submission.Date = data.Day
+	}
+	if data.Star != 0 {
+		submission.Star = data.Star
+	}
+	if len(data.Language) != 0 {
+		submission.LanguageName = data.Language
+	}
+	if len(data.Url) != 0 {
+		submission.SubmissionUrl = data.Url
+	}
+
+	ok, err = s.checkApiSubmission(c, &submission, oldSubmission.LanguageName)
+	if !ok {
+		return err
+	}
+
+	newSubmission, err := s.db.UpdateUserSubmission(&submission)
+	if err != nil {
+		log.Println(err)
+		return apiError(c, http.StatusInternalServerError, "internal error")
+	}
+
+	return c.JSON(newApiSubmission(newSubmission))
+}
+
+func (s *Server) HandleApiSubmissionsDelete(c *fiber.Ctx) error {
+	submission, ok, err := s.getApiSubmission(c)
+	if !ok {
+		return err
+	}
+
+	err = s.db.DeleteUserSubmission(submission.Id)
+	if err != nil {
+		log.Println(err)
+		return apiError(c, http.StatusInternalServerError, "internal error")
+	}
+
+	return c.SendStatus(http.StatusNoContent)
+}
+
+// getApiSubmission returns the submission of the route if it belongs to the user, otherwise ok is false
+// and the error response has been sent
+func (s *Server) getApiSubmission(c *fiber.Ctx) (*types.AOCUserSubmission, bool, error) {
+	id, err := c.ParamsInt("id")
+	if err != nil {
+		return nil, false, apiError(c, http.StatusBadRequest, "invalid submission id")
+	}
+
+	submission, err := s.db.GetUserSubmissionById(id)
+	if err != nil {
+		log.Println(err)
+		return nil, false, apiError(c, http.StatusInternalServerError, "internal error")
+	}
+	// other users' submissions look missing rather than forbidden
+	if submission == nil || submission.AocUserId != tokenUser(c).UserId {
+		return nil, false, apiError(c, http.StatusNotFound, "unknown submission")
+	}
+
+	return submission, true, nil
+}
+
+// checkApiSubmission validates the submission like the submission form does, otherwise ok is false
+// and the error response has been sent
+func (s *Server) checkApiSubmission(c *fiber.Ctx, submission *types.AOCUserSubmission, currentLanguage string) (bool, error) {
+	if submission.Star < 1 || submission.Star > 2 {
+		return false, apiError(c, http.StatusUnprocessableEntity, "Invalid star")
+	}
+
+	event, err := s.getEvent(submission.Year)
+	if err != nil {
+		log.Println(err)
+		return false, apiError(c, http.StatusInternalServerError, "internal error")
+	}
+
+	formErr, err := s.checkSubmission(submission, event.UnlockedDays(time.Now()), currentLanguage)
+	if err != nil {
+		log.Println(err)
+		return false, apiError(c, http.StatusInternalServerError, "internal error")
+	}
+	if len(formErr) != 0 {
+		return false, apiError(c, http.StatusUnprocessableEntity, formErr)
+	}
+
+	return true, nil
+}
+
+func newApiSubmission(submission *types.AOCUserSubmission) *apiSubmission {
+	return &apiSubmission{
+		Id:           submission.Id,
+		Year:         submission.Year,
+		Day:          submission.Date,
+		Star:         submission.Star,
+		Language:     submission.LanguageName,
+		Modifier:     decPercent(submission.ModifierDecPercent),
+		Url:          submission.SubmissionUrl,
+		Status:       submission.Status,
+		RejectReason: submission.RejectReason,
+	}
+}
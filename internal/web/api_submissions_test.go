@@ -0,0 +1,156 @@
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"uocsclub.net/aoclb/internal/types"
+)
+
+func TestRequireApiToken(t *testing.T) {
+	s := testServer(t)
+	s.App.Get("/api/v1/submissions", s.RequireApiToken, s.HandleApiSubmissionsGet)
+
+	testLink(t, s, 1001)
+	err := s.db.CreateApiToken(&types.AOCApiToken{AocId: 1001, Name: "cli", Prefix: "aoclb_alic"}, types.HashApiToken("aoclb_alice"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name          string
+		authorization string
+		cookie        string
+		want          int
+	}{
+		{"token", "Bearer aoclb_alice", "", http.StatusOK},
+		{"surrounding spaces", "Bearer  aoclb_alice ", "", http.StatusOK},
+		{"no header", "", "", http.StatusUnauthorized},
+		{"empty token", "Bearer ", "", http.StatusUnauthorized},
+		{"other scheme", "Basic aoclb_alice", "", http.StatusUnauthorized},
+		{"lowercase scheme", "bearer aoclb_alice", "", http.StatusUnauthorized},
+		{"unknown token", "Bearer aoclb_mallory", "", http.StatusUnauthorized},
+		// the api ignores the session, it has no csrf protection
+		{"session only", "", testLogin(t, s, 1001), http.StatusUnauthorized},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/submissions", nil)
+			if len(test.authorization) != 0 {
+				req.Header.Set("Authorization", test.authorization)
+			}
+			if len(test.cookie) != 0 {
+				req.Header.Set("Cookie", test.cookie)
+			}
+
+			resp, err := s.App.Test(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.StatusCode != test.want {
+				t.Errorf("got %d, want %d", resp.StatusCode, test.want)
+			}
+			if resp.StatusCode == http.StatusUnauthorized && resp.Header.Get("WWW-Authenticate") != "Bearer" {
+				t.Errorf("got WWW-Authenticate %q", resp.Header.Get("WWW-Authenticate"))
+			}
+		})
+	}
+}
+
+func TestApiSubmissionOwnership(t *testing.T) {
+	s := testServer(t)
+	submissions := s.App.Group("/api/v1/submissions", s.RequireApiToken)
+	submissions.Get("", s.HandleApiSubmissionsGet)
+	submissions.Patch("/:id<int>", s.HandleApiSubmissionsPatch)
+	submissions.Delete("/:id<int>", s.HandleApiSubmissionsDelete)
+
+	err := s.db.EnsureModifierSet("2025")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for aocId, token := range map[int]string{1001: "aoclb_alice", 1002: "aoclb_bob"} {
+		testLink(t, s, aocId)
+		err = s.db.CreateApiToken(&types.AOCApiToken{AocId: aocId, Name: "cli"}, types.HashApiToken(token))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	submission, err := s.db.AddUserSubmission("2025", &types.AOCUserSubmission{
+		AOCSubmissionModifier: types.AOCSubmissionModifier{LanguageName: "Haskell"},
+		AocUserId:             1001,
+		SubmissionUrl:         "https://github.com/alice/aoc/blob/main/day01.hs",
+		Date:                  1,
+		Star:                  1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := "/api/v1/submissions/" + strconv.Itoa(submission.Id)
+
+	request := func(method string, path string, token string, body string) *http.Response {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+token)
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, err := s.App.Test(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+	listed := func(token string) int {
+		resp := request(http.MethodGet, "/api/v1/submissions", token, "")
+		output := []*apiSubmission{}
+		err := json.NewDecoder(resp.Body).Decode(&output)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return len(output)
+	}
+
+	if got := listed("aoclb_alice"); got != 1 {
+		t.Errorf("alice: got %d submissions listed, want 1", got)
+	}
+	if got := listed("aoclb_bob"); got != 0 {
+		t.Errorf("bob: got %d submissions listed, want 0", got)
+	}
+
+	// the submissions of others look missing, like the ones that don't exist
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+	}{
+		{"patch", http.MethodPatch, path, `{"url": "https://github.com/bob/aoc"}`},
+		{"delete", http.MethodDelete, path, ""},
+		{"unknown", http.MethodDelete, "/api/v1/submissions/999999", ""},
+	}
+	for _, test := range tests {
+		if resp := request(test.method, test.path, "aoclb_bob", test.body); resp.StatusCode != http.StatusNotFound {
+			t.Errorf("bob %s: got %d, want %d", test.name, resp.StatusCode, http.StatusNotFound)
+		}
+	}
+
+	stored, err := s.db.GetUserSubmissionById(submission.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored == nil || stored.SubmissionUrl != submission.SubmissionUrl {
+		t.Fatalf("got %+v after bob's requests, want it unchanged", stored)
+	}
+
+	if resp := request(http.MethodDelete, path, "aoclb_alice", ""); resp.StatusCode != http.StatusNoContent {
+		t.Errorf("alice delete: got %d, want %d", resp.StatusCode, http.StatusNoContent)
+	}
+	if got := listed("aoclb_alice"); got != 0 {
+		t.Errorf("alice: got %d submissions listed after deleting, want 0", got)
+	}
+}
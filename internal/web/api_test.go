@@ -0,0 +1,153 @@
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+
+	"uocsclub.net/aoclb/internal/types"
+)
+
+// apiGet decodes the JSON body of the api route into a generic value so the test sees the field names
+func apiGet(t *testing.T, s *Server, path string, wantStatus int) map[string]any {
+	t.Helper()
+
+	resp, err := s.App.Test(httptest.NewRequest(http.MethodGet, path, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != wantStatus {
+		t.Fatalf("%s: got %d, want %d", path, resp.StatusCode, wantStatus)
+	}
+
+	body := map[string]any{}
+	err = json.NewDecoder(resp.Body).Decode(&body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return body
+}
+
+// checkKeys fails when the object doesn't have exactly the fields
+func checkKeys(t *testing.T, name string, object any, want ...string) map[string]any {
+	t.Helper()
+
+	fields, ok := object.(map[string]any)
+	if !ok {
+		t.Fatalf("%s: got %T, want an object", name, object)
+	}
+
+	got := []string{}
+	for key := range fields {
+		got = append(got, key)
+	}
+	slices.Sort(got)
+	slices.Sort(want)
+	if !slices.Equal(got, want) {
+		t.Errorf("%s: got fields %v, want %v", name, got, want)
+	}
+
+	return fields
+}
+
+func TestApiResponses(t *testing.T) {
+	s := testServer(t)
+	s.App.Get("/api/v1/leaderboard", s.HandleApiLeaderboard)
+	s.App.Get("/api/v1/leaderboard/:year<int>", s.HandleApiLeaderboard)
+	s.App.Get("/api/v1/modifiers", s.HandleApiModifiers)
+	s.App.Get("/api/v1/users/:aocId<int>", s.HandleApiUser)
+
+	// alice got both stars of the first day with a kodr submission for the first one
+	leaderboard := &types.AOCPrivateLeaderboard{Id: "123456", Year: "2025", Name: "club"}
+	_, err := s.db.StoreLeaderboard(leaderboard, types.AOCData{
+		1001: {Year: "2025", User: types.AOCUser{UserId: 1001, Name: "alice"}, Score: 6, Completions: map[int]*types.AOCCompletion{
+			1: {Star1: true, Star1TS: 1764565200, Star2: true, Star2TS: 1764568800, Star2Index: 1},
+		}},
+		1002: {Year: "2025", User: types.AOCUser{UserId: 1002, Name: "bob"}, Completions: map[int]*types.AOCCompletion{}},
+	}, 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.db.AddModifier("2025", &types.AOCSubmissionModifier{LanguageName: "kodr", ModifierDecPercent: 500}, 1001)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = s.db.AddUserSubmission("2025", &types.AOCUserSubmission{
+		AOCSubmissionModifier: types.AOCSubmissionModifier{LanguageName: "kodr"},
+		AocUserId:             1001,
+		SubmissionUrl:         "https://example.com",
+		Date:                  1,
+		Star:                  1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	memberKeys := []string{"rank", "aoc_id", "name", "score", "verified_score", "raw_score", "star_count", "stars"}
+	starKeys := []string{"day", "star", "completed_at", "points", "language", "modifier", "modifier_points"}
+
+	t.Run("leaderboard", func(t *testing.T) {
+		body := apiGet(t, s, "/api/v1/leaderboard/2025", http.StatusOK)
+		checkKeys(t, "leaderboard", body, "year", "scoring_mode", "num_days", "members")
+
+		members, ok := body["members"].([]any)
+		if !ok || len(members) != 2 {
+			t.Fatalf("got members %v, want alice and bob", body["members"])
+		}
+
+		alice := checkKeys(t, "member", members[0], memberKeys...)
+		if alice["name"] != "alice" || alice["rank"] != 1.0 || alice["raw_score"] != 6.0 || alice["star_count"] != 2.0 {
+			t.Errorf("got first member %v, want alice ranked first with 2 stars", alice)
+		}
+		// the pending submission only counts towards the score
+		if alice["score"] == alice["raw_score"] || alice["verified_score"] != alice["raw_score"] {
+			t.Errorf("got score %v and verified score %v for a raw score of %v", alice["score"], alice["verified_score"], alice["raw_score"])
+		}
+
+		stars := alice["stars"].([]any)
+		first := checkKeys(t, "star", stars[0], starKeys...)
+		if first["language"] != "kodr" || first["modifier"] != 50.0 || first["completed_at"] != 1764565200.0 {
+			t.Errorf("got first star %v", first)
+		}
+		// stars without a submission leave the language out
+		checkKeys(t, "star without submission", stars[1], "day", "star", "completed_at", "points", "modifier", "modifier_points")
+	})
+
+	t.Run("single leaderboard", func(t *testing.T) {
+		body := apiGet(t, s, "/api/v1/leaderboard?board=123456", http.StatusOK)
+		checkKeys(t, "leaderboard", body, "year", "leaderboard_id", "scoring_mode", "num_days", "members")
+		if body["leaderboard_id"] != "123456" {
+			t.Errorf("got leaderboard id %v", body["leaderboard_id"])
+		}
+	})
+
+	t.Run("modifiers", func(t *testing.T) {
+		body := apiGet(t, s, "/api/v1/modifiers?year=2025", http.StatusOK)
+		checkKeys(t, "modifiers", body, "year", "locked", "modifiers")
+
+		modifiers := body["modifiers"].([]any)
+		found := false
+		for _, modifier := range modifiers {
+			fields := checkKeys(t, "modifier", modifier, "language", "modifier", "retired")
+			found = found || (fields["language"] == "kodr" && fields["modifier"] == 50.0)
+		}
+		if !found {
+			t.Errorf("kodr is missing from %v", modifiers)
+		}
+	})
+
+	t.Run("user", func(t *testing.T) {
+		body := apiGet(t, s, "/api/v1/users/1001", http.StatusOK)
+		checkKeys(t, "user", body, "aoc_id", "name", "year", "standing")
+		checkKeys(t, "standing", body["standing"], memberKeys...)
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		checkKeys(t, "unknown user", apiGet(t, s, "/api/v1/users/9999", http.StatusNotFound), "error")
+		checkKeys(t, "unknown year", apiGet(t, s, "/api/v1/leaderboard/1999", http.StatusNotFound), "error")
+		checkKeys(t, "unknown leaderboard", apiGet(t, s, "/api/v1/leaderboard?board=42", http.StatusNotFound), "error")
+	})
+}
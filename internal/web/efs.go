@@ -5,3 +5,7 @@ import "embed"
 //go:embed "assets"
 var AssetsEFS embed.FS
 
+// OpenAPIDocument describes the JSON api, served at /api/v1/openapi.json
+//
+//go:embed "openapi.json"
+var OpenAPIDocument []byte
@@ -0,0 +1,74 @@
+package web
+
+import (
+	"bufio"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"uocsclub.net/aoclb/internal/events"
+)
+
+// sseKeepAlive is how often idle streams get a comment, it also notices the closed ones
+const sseKeepAlive = 30 * time.Second
+
+// HandleEvents streams the updates of the leaderboards of the year to open pages, the leaderboard
+// refreshes itself on the sse:leaderboard event. Every board of the year is sent since members
+// can share stars between boards
+func (s *Server) HandleEvents(c *fiber.Ctx) error {
+	year, ok := s.getYear(c)
+	if !ok {
+		return c.SendStatus(http.StatusNotFound)
+	}
+	// the stream outlives the handler, fiber reuses the memory the params point to
+	year = strings.Clone(year)
+
+	c.Set(fiber.HeaderContentType, "text/event-stream")
+	c.Set(fiber.HeaderCacheControl, "no-cache")
+	c.Set(fiber.HeaderConnection, "keep-alive")
+	c.Set("X-Accel-Buffering", "no") // nginx would hold the events back otherwise
+
+	updates, unsubscribe := s.hub.Subscribe(16, events.LeaderboardUpdated)
+
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		defer unsubscribe()
+
+		keepAlive := time.NewTicker(sseKeepAlive)
+		defer keepAlive.Stop()
+
+		// browsers reconnect on their own, no need to hammer a restarting server
+		fmt.Fprintf(w, "retry: %d\n\n", (10 * time.Second).Milliseconds())
+
+		for {
+			if w.Flush() != nil {
+				return
+			}
+
+			select {
+			case event, ok := <-updates:
+				if !ok {
+					return
+				}
+				if event.Year != year {
+					continue
+				}
+				fmt.Fprintf(w, "event: leaderboard\ndata: %s\n\n", event.LeaderboardId)
+			case <-keepAlive.C:
+				fmt.Fprint(w, ": keep-alive\n\n")
+			}
+		}
+	})
+
+	return nil
+}
+
+// publishSubmission tells the notifiers about a change to a submission
+func (s *Server) publishSubmission(eventType events.EventType, data *events.SubmissionData) {
+	s.hub.Publish(events.Event{
+		Type: eventType,
+		Year: data.Year,
+		Data: data,
+	})
+}
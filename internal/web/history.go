@@ -0,0 +1,189 @@
+package web
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"uocsclub.net/aoclb/internal/fetcher"
+	"uocsclub.net/aoclb/internal/scoring"
+	"uocsclub.net/aoclb/internal/types"
+	"uocsclub.net/aoclb/internal/web/templates"
+)
+
+func (s *Server) HandleHistory(c *fiber.Ctx) error {
+	year, ok := s.getYear(c)
+	if !ok {
+		return c.SendStatus(http.StatusNotFound)
+	}
+
+	leaderboard, ok := s.getPrivateLeaderboard(c, year)
+	if !ok {
+		return c.SendStatus(http.StatusNotFound)
+	}
+
+	histories, start, end, err := s.getScoreHistory(year, leaderboard)
+	if err != nil {
+		log.Println(err)
+		return c.SendStatus(http.StatusInternalServerError)
+	}
+
+	leaderboardId := ""
+	if leaderboard != nil {
+		leaderboardId = leaderboard.Id
+	}
+
+	return s.Render(c, templates.HistoryPage(year, leaderboardId, histories, start, end))
+}
+
+func (s *Server) HandleUserHistory(c *fiber.Ctx) error {
+	year, ok := s.getYear(c)
+	if !ok {
+		return c.SendStatus(http.StatusNotFound)
+	}
+
+	leaderboard, ok := s.getPrivateLeaderboard(c, year)
+	if !ok {
+		return c.SendStatus(http.StatusNotFound)
+	}
+
+	aocId, err := strconv.Atoi(c.Params("aocId"))
+	if err != nil {
+		return c.SendStatus(http.StatusNotFound)
+	}
+
+	histories, start, end, err := s.getScoreHistory(year, leaderboard)
+	if err != nil {
+		log.Println(err)
+		return c.SendStatus(http.StatusInternalServerError)
+	}
+
+	for _, history := range histories {
+		if history.User.UserId == aocId {
+			return s.Render(c, templates.UserHistoryPage(year, history, len(histories), start, end))
+		}
+	}
+
+	return c.SendStatus(http.StatusNotFound)
+}
+
+// getScoreHistory rebuilds the leaderboard from the archived snapshots at the end of every
+// day of the event, and at the time of the latest snapshot. A nil leaderboard merges every board of the year
+func (s *Server) getScoreHistory(year string, leaderboard *types.AOCPrivateLeaderboard) ([]*types.AOCScoreHistory, int, int, error) {
+	event, err := s.getEvent(year)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+
+	snapshots, err := s.db.GetSnapshotsByYear(year)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+
+	submissions, err := s.db.GetSubmissionsByYear(year)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+
+	modifiers, err := s.db.GetModifiers(year)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+
+	engine := scoring.ForEvent(event)
+
+	if len(snapshots) == 0 {
+		return []*types.AOCScoreHistory{}, event.Day1Timestamp, event.Day1Timestamp, nil
+	}
+
+	end := snapshots[len(snapshots)-1].FetchedAt
+	sampleTimes := []int{}
+	for day := 1; day <= event.NumDays; day++ {
+		dayEnd := event.Day1Timestamp + day*int(24*time.Hour/time.Second)
+		if dayEnd >= end {
+			break
+		}
+		sampleTimes = append(sampleTimes, dayEnd)
+	}
+	sampleTimes = append(sampleTimes, end)
+
+	parsed := map[string]types.AOCData{}
+	historyByUser := map[int]*types.AOCScoreHistory{}
+	histories := []*types.AOCScoreHistory{}
+
+	for _, sampleTime := range sampleTimes {
+		// latest snapshot of every board at that time
+		latest := map[string]*types.AOCLeaderboardSnapshot{}
+		for _, snapshot := range snapshots {
+			if snapshot.FetchedAt > sampleTime {
+				break
+			}
+			if leaderboard != nil && snapshot.LeaderboardId != leaderboard.Id {
+				continue
+			}
+			latest[snapshot.LeaderboardId] = snapshot
+		}
+		if len(latest) == 0 {
+			continue
+		}
+
+		boards := []types.AOCData{}
+		for _, snapshot := range latest {
+			if parsed[snapshot.Hash] == nil {
+				payload, err := s.db.GetSnapshotPayload(snapshot)
+				if err != nil {
+					return nil, 0, 0, err
+				}
+				response, err := fetcher.ParseAOCLeaderboard(payload)
+				if err != nil {
+					return nil, 0, 0, err
+				}
+				parsed[snapshot.Hash] = response.ToAOCData()
+			}
+			boards = append(boards, parsed[snapshot.Hash])
+		}
+
+		merged := types.MergeAOCData(boards...)
+		if len(boards) > 1 {
+			types.RescoreAOCData(merged, event.NumDays, nil)
+		}
+
+		// only count the submissions of stars that were already obtained
+		sampledSubmissions := []*types.AOCUserSubmission{}
+		for _, submission := range submissions {
+			entry := merged[submission.AocUserId]
+			if entry != nil && entry.Completions[submission.Date].CompletedBy(submission.Star, sampleTime) {
+				sampledSubmissions = append(sampledSubmissions, submission)
+			}
+		}
+
+		rows := engine.Score(&scoring.Input{
+			Event:       event,
+			Leaderboard: merged,
+			Submissions: sampledSubmissions,
+			Modifiers:   modifiers,
+		})
+
+		for _, row := range rows {
+			entry := row.Entry
+			history := historyByUser[entry.User.UserId]
+			if history == nil {
+				history = &types.AOCScoreHistory{User: entry.User}
+				historyByUser[entry.User.UserId] = history
+				histories = append(histories, history)
+			}
+
+			history.User = entry.User
+			history.Points = append(history.Points, types.AOCScorePoint{
+				Timestamp:     sampleTime,
+				Score:         entry.Score,
+				AdjustedScore: row.AdjustedScore,
+				Rank:          row.Rank,
+			})
+		}
+	}
+
+	return histories, event.Day1Timestamp, end, nil
+}
@@ -0,0 +1,85 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"uocsclub.net/aoclb/internal/types"
+)
+
+func TestForceLinkEndsPreviousSession(t *testing.T) {
+	s := testServer(t)
+	s.App.Get("/me", s.RequireRole(types.RoleMember), func(c *fiber.Ctx) error { return c.SendStatus(http.StatusOK) })
+
+	testLink(t, s, 1001)
+	session := testLogin(t, s, 1001)
+
+	get := func() int {
+		req := httptest.NewRequest(http.MethodGet, "/me", nil)
+		req.Header.Set("Cookie", session)
+
+		resp, err := s.App.Test(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp.StatusCode
+	}
+
+	if got := get(); got != http.StatusOK {
+		t.Fatalf("linked user: got %d, want %d", got, http.StatusOK)
+	}
+
+	// an admin gives alice's aoc account to another account
+	_, err := s.db.ForceLinkUser(types.AOCIdentity{Provider: "github", Subject: "5000"}, 1001)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := get(); got != http.StatusForbidden {
+		t.Errorf("previous owner: got %d, want %d", got, http.StatusForbidden)
+	}
+}
+
+func TestSettingsUnlink(t *testing.T) {
+	s := testServer(t)
+	s.App.Post("/settings/unlink", s.HandleSettingsUnlink)
+
+	testLink(t, s, 1001)
+
+	unlink := func(session string) int {
+		req := httptest.NewRequest(http.MethodPost, "/settings/unlink", nil)
+		if len(session) != 0 {
+			req.Header.Set("Cookie", session)
+		}
+
+		resp, err := s.App.Test(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp.StatusCode
+	}
+
+	if got := unlink(""); got != http.StatusForbidden {
+		t.Errorf("anonymous: got %d, want %d", got, http.StatusForbidden)
+	}
+
+	session := testLogin(t, s, 1001)
+	if got := unlink(session); got >= http.StatusBadRequest {
+		t.Fatalf("linked user: got %d", got)
+	}
+
+	user, err := s.db.GetUserByAocId(1001)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.Identity.Linked() {
+		t.Errorf("aoc account still linked to %s", user.Identity)
+	}
+
+	// the session went with the link
+	if got := unlink(session); got != http.StatusForbidden {
+		t.Errorf("after unlinking: got %d, want %d", got, http.StatusForbidden)
+	}
+}
@@ -0,0 +1,145 @@
+package web
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"uocsclub.net/aoclb/internal/database"
+	"uocsclub.net/aoclb/internal/events"
+	"uocsclub.net/aoclb/internal/types"
+	"uocsclub.net/aoclb/internal/web/templates"
+)
+
+type reviewFormBody struct {
+	SubmissionId int    `form:"id"`
+	Status       string `form:"status"`
+	Reason       string `form:"reason"`
+}
+
+func (s *Server) HandleReviewGet(c *fiber.Ctx) error {
+	year, ok := s.getYear(c)
+	if !ok {
+		return c.SendStatus(http.StatusNotFound)
+	}
+
+	submissions, err := s.db.GetSubmissionsByStatus(year, types.SubmissionPending)
+	if err != nil {
+		log.Println(err)
+		return c.SendStatus(http.StatusInternalServerError)
+	}
+
+	users, err := s.getYearUsers(year)
+	if err != nil {
+		log.Println(err)
+		return c.SendStatus(http.StatusInternalServerError)
+	}
+
+	return s.Render(c, templates.ReviewPage(year, submissions, users))
+}
+
+func (s *Server) HandleReviewPost(c *fiber.Ctx) error {
+	year, ok := s.getYear(c)
+	if !ok {
+		return c.SendStatus(http.StatusNotFound)
+	}
+
+	reviewer := s.SessionUser(c)
+	if reviewer == nil {
+		return c.SendStatus(http.StatusForbidden)
+	}
+
+	data := &reviewFormBody{}
+	err := c.BodyParser(data)
+	if err != nil {
+		log.Println(err)
+		return c.SendStatus(http.StatusUnprocessableEntity)
+	}
+
+	status := types.AOCSubmissionStatus(data.Status)
+	if status != types.SubmissionApproved && status != types.SubmissionRejected {
+		return c.SendStatus(http.StatusUnprocessableEntity)
+	}
+
+	submission, err := s.db.GetUserSubmissionById(data.SubmissionId)
+	if err != nil {
+		log.Println(err)
+		return c.SendStatus(http.StatusInternalServerError)
+	}
+	if submission == nil || submission.Year != year {
+		return c.SendStatus(http.StatusNotFound)
+	}
+
+	users, err := s.getYearUsers(year)
+	if err != nil {
+		log.Println(err)
+		return c.SendStatus(http.StatusInternalServerError)
+	}
+	user := users[submission.AocUserId]
+
+	if submission.AocUserId == reviewer.UserId {
+		return s.Render(c, templates.ReviewSubmission(year, submission, user, "You can't review your own submissions"))
+	}
+
+	// another reviewer got to it first, their decision stands
+	if submission.Status != types.SubmissionPending {
+		return s.Render(c, templates.ReviewSubmission(year, submission, user, alreadyReviewedMessage(submission)))
+	}
+
+	reason := strings.TrimSpace(data.Reason)
+	if status == types.SubmissionRejected && len(reason) == 0 {
+		return s.Render(c, templates.ReviewSubmission(year, submission, user, "Rejections need a reason"))
+	}
+
+	err = s.db.ReviewUserSubmission(submission.Id, status, reason, reviewer.UserId)
+	if errors.Is(err, database.ErrSubmissionReviewed) {
+		submission, err = s.db.GetUserSubmissionById(submission.Id)
+		if err != nil || submission == nil {
+			log.Println(err)
+			return c.SendStatus(http.StatusInternalServerError)
+		}
+		return s.Render(c, templates.ReviewSubmission(year, submission, user, alreadyReviewedMessage(submission)))
+	}
+	if err != nil {
+		log.Println(err)
+		return c.SendStatus(http.StatusInternalServerError)
+	}
+
+	submission.Status = status
+	if status == types.SubmissionRejected {
+		submission.RejectReason = reason
+	}
+	published := events.NewSubmissionData(&user, submission)
+	reviewedBy := events.NewUser(reviewer)
+	published.Reviewer = &reviewedBy
+	s.publishSubmission(events.SubmissionReviewed, published)
+
+	// the submission leaves the queue
+	c.Set("HX-Trigger", "refresh-leaderboard")
+	return c.SendString("")
+}
+
+// alreadyReviewedMessage tells the reviewer what was decided before them
+func alreadyReviewedMessage(submission *types.AOCUserSubmission) string {
+	if submission.Status == types.SubmissionRejected {
+		return "This submission was already rejected: " + submission.RejectReason
+	}
+	return "This submission was already " + string(submission.Status)
+}
+
+// getYearUsers returns the members of every leaderboard of the year indexed by aoc id
+func (s *Server) getYearUsers(year string) (map[int]types.AOCUser, error) {
+	data, err := s.db.GetLeaderboard(year, "")
+	if err != nil {
+		return nil, err
+	}
+
+	users := map[int]types.AOCUser{}
+	for id, entry := range data {
+		users[id] = entry.User
+	}
+
+	return users, nil
+}
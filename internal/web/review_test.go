@@ -0,0 +1,80 @@
+package web
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+
+	"uocsclub.net/aoclb/internal/types"
+)
+
+func TestReviewPostOnlyPending(t *testing.T) {
+	s := testServer(t)
+	s.App.Post("/review", s.RequireRole(types.RoleReviewer), s.HandleReviewPost)
+
+	// bob and carol both review alice's submission
+	testLink(t, s, 1001)
+	testLink(t, s, 1002)
+	testLink(t, s, 1003)
+	for _, id := range []int{1002, 1003} {
+		err := s.db.SetUserRole(id, types.RoleReviewer)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err := s.db.EnsureModifierSet("2025")
+	if err != nil {
+		t.Fatal(err)
+	}
+	submission, err := s.db.AddUserSubmission("2025", &types.AOCUserSubmission{
+		AOCSubmissionModifier: types.AOCSubmissionModifier{LanguageName: "Haskell"},
+		AocUserId:             1001,
+		SubmissionUrl:         "https://github.com/alice/aoc/blob/main/day01.hs",
+		Date:                  1,
+		Star:                  1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	review := func(session string, status types.AOCSubmissionStatus, reason string) string {
+		form := url.Values{"id": {strconv.Itoa(submission.Id)}, "status": {string(status)}, "reason": {reason}}
+		req := httptest.NewRequest(http.MethodPost, "/review", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set("Cookie", session)
+
+		resp, err := s.App.Test(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("got %d, want %d", resp.StatusCode, http.StatusOK)
+		}
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(body)
+	}
+
+	review(testLogin(t, s, 1002), types.SubmissionApproved, "")
+
+	// carol's queue was loaded before bob approved it
+	body := review(testLogin(t, s, 1003), types.SubmissionRejected, "not haskell")
+	if !strings.Contains(body, "already approved") {
+		t.Errorf("got %q, want the earlier decision", body)
+	}
+
+	stored, err := s.db.GetUserSubmissionById(submission.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.Status != types.SubmissionApproved || stored.ReviewerId != 1002 {
+		t.Errorf("got %s by %d, want approved by bob", stored.Status, stored.ReviewerId)
+	}
+}
@@ -0,0 +1,181 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/session"
+	"uocsclub.net/aoclb/internal/database"
+	"uocsclub.net/aoclb/internal/events"
+	"uocsclub.net/aoclb/internal/types"
+)
+
+// testServer is a server without any route over a new database with three aoc users, alice (1001),
+// bob (1002) and carol (1003). Sessions are kept in memory and logged in from /test/login/<aoc id>
+func testServer(t *testing.T) *Server {
+	t.Helper()
+
+	db, err := database.InitDatabase(filepath.Join(t.TempDir(), "aoclb.db"), "../../migrations")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	leaderboard := &types.AOCPrivateLeaderboard{Id: "123456", Year: "2025", Name: "club"}
+	err = db.StorePrivateLeaderboard(leaderboard)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data := types.AOCData{}
+	for id, name := range map[int]string{1001: "alice", 1002: "bob", 1003: "carol"} {
+		data[id] = &types.AOCUserLB{Year: "2025", User: types.AOCUser{UserId: id, Name: name}, Completions: map[int]*types.AOCCompletion{}}
+	}
+	_, err = db.StoreLeaderboard(leaderboard, data, 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := &Server{
+		App:    fiber.New(),
+		db:     db,
+		config: ServerConfig{Year: "2025"},
+		store:  session.New(),
+		hub:    events.NewHub(),
+	}
+
+	// what HandleOAuthRedir leaves in the session of a linked user
+	s.App.Get("/test/login/:aocId<int>", func(c *fiber.Ctx) error {
+		aocId, _ := c.ParamsInt("aocId")
+		user, err := s.db.GetUserByAocId(aocId)
+		if err != nil || user == nil {
+			return c.SendStatus(http.StatusNotFound)
+		}
+
+		sess, err := s.store.Get(c)
+		if err != nil {
+			return err
+		}
+		sess.Set("aoc_id", user.UserId)
+		sess.Set("name", user.Name)
+		setSessionIdentity(sess, &user.Identity)
+
+		return sess.Save()
+	})
+
+	return s
+}
+
+// testLink links the aoc user to a github account, the subject is the aoc id
+func testLink(t *testing.T, s *Server, aocId int) types.AOCIdentity {
+	t.Helper()
+
+	identity := types.AOCIdentity{Provider: "github", Subject: strconv.Itoa(aocId)}
+	_, err := s.db.ForceLinkUser(identity, aocId)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return identity
+}
+
+// testLogin returns the session cookie of the aoc user
+func testLogin(t *testing.T, s *Server, aocId int) string {
+	t.Helper()
+
+	resp, err := s.App.Test(httptest.NewRequest(http.MethodGet, "/test/login/"+strconv.Itoa(aocId), nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, cookie := range resp.Cookies() {
+		if cookie.Name == "session_id" {
+			return cookie.Name + "=" + cookie.Value
+		}
+	}
+
+	t.Fatalf("no session cookie for %d", aocId)
+	return ""
+}
+
+func TestRequireRole(t *testing.T) {
+	s := testServer(t)
+	ok := func(c *fiber.Ctx) error { return c.SendStatus(http.StatusOK) }
+	s.App.Get("/admin/users", s.RequireRole(types.RoleAdmin), ok)
+	s.App.Get("/review", s.RequireRole(types.RoleReviewer), ok)
+
+	alice := testLink(t, s, 1001)
+	testLink(t, s, 1002)
+	testLink(t, s, 1003)
+
+	// alice is an admin from the config, bob a reviewer and carol a member
+	err := s.db.BootstrapAdmins([]types.AOCIdentity{alice})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.db.SetUserRole(1002, types.RoleReviewer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sessions := map[string]string{
+		"anonymous": "",
+		"alice":     testLogin(t, s, 1001),
+		"bob":       testLogin(t, s, 1002),
+		"carol":     testLogin(t, s, 1003),
+	}
+
+	get := func(path string, session string) int {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		if len(session) != 0 {
+			req.Header.Set("Cookie", session)
+		}
+
+		resp, err := s.App.Test(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp.StatusCode
+	}
+
+	tests := []struct {
+		session string
+		path    string
+		want    int
+	}{
+		{"anonymous", "/review", http.StatusForbidden},
+		{"anonymous", "/admin/users", http.StatusForbidden},
+		{"carol", "/review", http.StatusForbidden},
+		{"carol", "/admin/users", http.StatusForbidden},
+		{"bob", "/review", http.StatusOK},
+		{"bob", "/admin/users", http.StatusForbidden},
+		{"alice", "/review", http.StatusOK}, // admins are also reviewers
+		{"alice", "/admin/users", http.StatusOK},
+	}
+
+	for _, test := range tests {
+		if got := get(test.path, sessions[test.session]); got != test.want {
+			t.Errorf("%s %s: got %d, want %d", test.session, test.path, got, test.want)
+		}
+	}
+
+	// the role is read on every request, a demotion applies to the open sessions
+	err = s.db.SetUserRole(1001, types.RoleMember)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := get("/admin/users", sessions["alice"]); got != http.StatusForbidden {
+		t.Errorf("demoted admin: got %d, want %d", got, http.StatusForbidden)
+	}
+
+	// so does losing the aoc account the session was for
+	err = s.db.UnlinkUser(1002)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := get("/review", sessions["bob"]); got != http.StatusForbidden {
+		t.Errorf("unlinked reviewer: got %d, want %d", got, http.StatusForbidden)
+	}
+}
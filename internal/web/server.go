@@ -1,12 +1,14 @@
 package web
 
 import (
-	"encoding/json"
+	"crypto/rand"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"log"
 	"net/http"
 	"net/url"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
@@ -14,11 +16,14 @@ import (
 	"github.com/a-h/templ"
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/cors"
+	"github.com/gofiber/fiber/v2/middleware/csrf"
 	"github.com/gofiber/fiber/v2/middleware/filesystem"
 	"github.com/gofiber/fiber/v2/middleware/session"
 	"github.com/gofiber/storage/sqlite3/v2"
+	"uocsclub.net/aoclb/internal/auth"
 	"uocsclub.net/aoclb/internal/database"
-	"uocsclub.net/aoclb/internal/fetcher"
+	"uocsclub.net/aoclb/internal/events"
+	"uocsclub.net/aoclb/internal/scoring"
 	"uocsclub.net/aoclb/internal/types"
 	"uocsclub.net/aoclb/internal/web/templates"
 )
@@ -28,35 +33,67 @@ type Server struct {
 	db     *database.DatabaseInst
 	config ServerConfig
 	store  *session.Store
+	hub    *events.Hub
 }
 
 type ServerConfig struct {
-	Port                    int
-	Year                    string
-	OAuth2GithubClientId    string
-	OAuth2GithubRedirectURI string
-	OAuth2GithubSecret      string
+	Port            int
+	Year            string
+	BaseURL         string // public url of the app, the providers send users back to <BaseURL>/oauth2
+	Providers       []auth.Provider
+	AdminIdentities []types.AOCIdentity // promoted to admin when they log in
+	CorsOrigins     string              // comma separated origins allowed to read the pages, none if empty
 }
 
-func InitServer(config ServerConfig, db *database.DatabaseInst) *Server {
+const sessionExpiration = 24 * 7 * time.Hour // 7 days expiration
+
+// csrfContextKey holds the csrf token of the request, Render puts it in the htmx headers of the page
+const csrfContextKey = "csrf"
+
+func InitServer(config ServerConfig, db *database.DatabaseInst, hub *events.Hub) *Server {
+	// cookies only go over https when the app is served over it
+	secureCookies := strings.HasPrefix(config.BaseURL, "https://")
+
 	s := &Server{
 		App:    fiber.New(),
 		db:     db,
 		config: config,
+		hub:    hub,
 		store: session.New(session.Config{
-			Expiration: 24 * 7 * time.Hour, // 7 days expiration
+			Expiration:     sessionExpiration,
+			CookieSecure:   secureCookies,
+			CookieHTTPOnly: true,
 			Storage: sqlite3.New(sqlite3.Config{
 				Database: "./fiber_storage.sqlite3",
 			}),
 		}),
 	}
 
-	s.App.Use(cors.New(cors.Config{
-		AllowOrigins:     "*",
-		AllowMethods:     "GET,POST,PUT,DELETE,OPTIONS,PATCH",
-		AllowHeaders:     "Accept,Authorization,Content-Type",
-		AllowCredentials: false, // credentials require explicit origins
-		MaxAge:           300,
+	// same origin only unless other origins are configured, the cookies never go cross-origin
+	if len(strings.TrimSpace(config.CorsOrigins)) != 0 {
+		s.App.Use(cors.New(cors.Config{
+			AllowOrigins:     config.CorsOrigins,
+			AllowMethods:     "GET,HEAD,OPTIONS",
+			AllowHeaders:     "Accept",
+			AllowCredentials: false,
+			MaxAge:           300,
+		}))
+	}
+
+	// every htmx request that changes something has to send the token of the session, the api
+	// doesn't use the session so it is left out
+	s.App.Use(csrf.New(csrf.Config{
+		Next: func(c *fiber.Ctx) bool {
+			return strings.HasPrefix(c.Path(), "/api/")
+		},
+		KeyLookup:      "header:" + csrf.HeaderName,
+		CookieName:     "csrf_",
+		CookieSameSite: "Lax",
+		CookieSecure:   secureCookies,
+		CookieHTTPOnly: true,
+		Expiration:     sessionExpiration,
+		Session:        s.store,
+		ContextKey:     csrfContextKey,
 	}))
 
 	s.App.Use(func(c *fiber.Ctx) error {
@@ -70,37 +107,116 @@ func InitServer(config ServerConfig, db *database.DatabaseInst) *Server {
 		Browse:     false,
 	}))
 
+	s.App.Get("/login/:provider", s.HandleLogin)
 	s.App.Get("/oauth2", s.HandleOAuthRedir)
 	s.App.Post("/oauth2", s.HandleOauthLink)
+	s.App.Post("/oauth2/verify", s.HandleOauthVerify)
+	s.App.Get("/settings", s.HandleSettingsGet)
+	s.App.Post("/settings/unlink", s.HandleSettingsUnlink)
+	s.App.Post("/settings/transfer", s.HandleSettingsTransfer)
+	s.App.Post("/settings/tokens", s.HandleSettingsTokensPost)
+	s.App.Delete("/settings/tokens", s.HandleSettingsTokensDelete)
 	s.App.Get("/logout", s.HandleLogout)
 	s.App.Get("/modifiers", s.HandleModifiers)
+	s.App.Get("/:year<int>/modifiers", s.HandleModifiers)
 	s.App.Get("/about", s.HandleAbout)
+	s.App.Get("/:year<int>/about", s.HandleAbout)
 	s.App.Get("/usermodifiers", s.HandleUserModifiersGet)
 	s.App.Post("/usermodifiers", s.HandleUserModifiersPost)
 	s.App.Patch("/usermodifiers", s.HandleUserModifiersPatch)
 	s.App.Delete("/usermodifiers", s.HandleUserModifiersDelete)
+	s.App.Get("/:year<int>/usermodifiers", s.HandleUserModifiersGet)
+	s.App.Post("/:year<int>/usermodifiers", s.HandleUserModifiersPost)
+	s.App.Patch("/:year<int>/usermodifiers", s.HandleUserModifiersPatch)
+	s.App.Delete("/:year<int>/usermodifiers", s.HandleUserModifiersDelete)
+
+	admin := s.App.Group("/admin", s.RequireRole(types.RoleAdmin))
+	admin.Get("/modifiers", s.HandleAdminModifiersGet)
+	admin.Post("/modifiers", s.HandleAdminModifiersPost)
+	admin.Patch("/modifiers", s.HandleAdminModifiersPatch)
+	admin.Get("/users", s.HandleAdminUsersGet)
+	admin.Patch("/users", s.HandleAdminUsersPatch)
+	admin.Post("/users/link", s.HandleAdminUsersLink)
+	admin.Post("/users/unlink", s.HandleAdminUsersUnlink)
+	admin.Get("/webhooks", s.HandleAdminWebhooksGet)
+	admin.Post("/webhooks", s.HandleAdminWebhooksPost)
+	admin.Get("/webhooks/:id<int>", s.HandleAdminWebhookDeliveriesGet)
+	admin.Patch("/webhooks/:id<int>", s.HandleAdminWebhookPatch)
+	admin.Delete("/webhooks/:id<int>", s.HandleAdminWebhookDelete)
+	admin.Post("/webhooks/:id<int>/ping", s.HandleAdminWebhookPing)
+	admin.Post("/webhooks/:id<int>/deliveries/:delivery<int>/retry", s.HandleAdminWebhookDeliveryRetry)
+	yearAdmin := s.App.Group("/:year<int>/admin", s.RequireRole(types.RoleAdmin))
+	yearAdmin.Get("/modifiers", s.HandleAdminModifiersGet)
+	yearAdmin.Post("/modifiers", s.HandleAdminModifiersPost)
+	yearAdmin.Patch("/modifiers", s.HandleAdminModifiersPatch)
+
+	s.App.Get("/review", s.RequireRole(types.RoleReviewer), s.HandleReviewGet)
+	s.App.Post("/review", s.RequireRole(types.RoleReviewer), s.HandleReviewPost)
+	s.App.Get("/:year<int>/review", s.RequireRole(types.RoleReviewer), s.HandleReviewGet)
+	s.App.Post("/:year<int>/review", s.RequireRole(types.RoleReviewer), s.HandleReviewPost)
+	s.App.Get("/history", s.HandleHistory)
+	s.App.Get("/:year<int>/history", s.HandleHistory)
+	s.App.Get("/user/:aocId<int>/history", s.HandleUserHistory)
+	s.App.Get("/:year<int>/user/:aocId<int>/history", s.HandleUserHistory)
 	s.App.Get("/leaderboard", s.HandleLeaderboard)
+	s.App.Get("/leaderboard/:year<int>", s.HandleLeaderboard)
+	s.App.Get("/events", s.HandleEvents)
+	s.App.Get("/:year<int>/events", s.HandleEvents)
+	s.App.Get("/api/v1/openapi.json", s.HandleApiOpenAPI)
+	s.App.Get("/api/v1/leaderboard", s.HandleApiLeaderboard)
+	s.App.Get("/api/v1/leaderboard/:year<int>", s.HandleApiLeaderboard)
+	s.App.Get("/api/v1/modifiers", s.HandleApiModifiers)
+	s.App.Get("/api/v1/users/:aocId<int>", s.HandleApiUser)
+
+	submissions := s.App.Group("/api/v1/submissions", s.RequireApiToken)
+	submissions.Get("", s.HandleApiSubmissionsGet)
+	submissions.Post("", s.HandleApiSubmissionsPost)
+	submissions.Patch("/:id<int>", s.HandleApiSubmissionsPatch)
+	submissions.Delete("/:id<int>", s.HandleApiSubmissionsDelete)
 	s.App.Get("/", s.HandleRoot)
+	s.App.Get("/:year<int>", s.HandleRoot)
 
 	s.App.Listen(fmt.Sprintf(":%d", s.config.Port))
 	return s
 }
 
 func (s *Server) HandleRoot(c *fiber.Ctx) error {
-	sess, err := s.store.Get(c)
+	year, ok := s.getYear(c)
+	if !ok {
+		return c.SendStatus(http.StatusNotFound)
+	}
+
+	years, err := s.getYears()
 	if err != nil {
+		log.Println(err)
 		return c.SendStatus(http.StatusInternalServerError)
 	}
 
-	redirectUri, err := url.JoinPath(s.config.OAuth2GithubRedirectURI, "/oauth2")
+	leaderboard, ok := s.getPrivateLeaderboard(c, year)
+	if !ok {
+		return c.SendStatus(http.StatusNotFound)
+	}
+
+	leaderboards, err := s.db.GetPrivateLeaderboardsByYear(year)
 	if err != nil {
-		log.Printf("Redirect URI invalid: %s\n", redirectUri)
+		log.Println(err)
+		return c.SendStatus(http.StatusInternalServerError)
+	}
+
+	sess, err := s.store.Get(c)
+	if err != nil {
+		return c.SendStatus(http.StatusInternalServerError)
 	}
 
 	var loginWidget templ.Component
 	loggedIn := false
+	var role types.AOCUserRole
+
+	if user := s.SessionUser(c); user != nil {
+		role = user.Role
+	}
 
-	if s.ValidateGithubLogin(c) {
+	if s.ValidateLogin(c) {
 		loggedIn = true
 		name, ok := sess.Get("name").(string)
 		if ok {
@@ -108,102 +224,241 @@ func (s *Server) HandleRoot(c *fiber.Ctx) error {
 			goto logged_out
 		}
 	}
-	loginWidget = templates.GithubLogin(s.config.OAuth2GithubClientId, redirectUri)
+	loginWidget = templates.LoginWidget(s.config.Providers)
 logged_out:
 
 	return s.Render(c, templates.LandingPage(
 		loginWidget,
 		loggedIn,
+		role,
+		year,
+		years,
+		leaderboards,
+		leaderboard,
 	))
 }
 
 func (s *Server) HandleLeaderboard(c *fiber.Ctx) error {
-	data, err := s.db.GetLeaderboard(s.config.Year)
+	year, ok := s.getYear(c)
+	if !ok {
+		return c.SendStatus(http.StatusNotFound)
+	}
+
+	leaderboard, ok := s.getPrivateLeaderboard(c, year)
+	if !ok {
+		return c.SendStatus(http.StatusNotFound)
+	}
+
+	leaderboardId := ""
+	if leaderboard != nil {
+		leaderboardId = leaderboard.Id
+	}
+
+	scored, err := s.scoreLeaderboard(year, leaderboardId)
+	if err != nil {
+		log.Println(err)
+		return c.SendStatus(http.StatusInternalServerError)
+	}
+
+	statuses, err := s.db.GetFetchStatusesByYear(year)
 	if err != nil {
 		log.Println(err)
 		return c.SendStatus(http.StatusInternalServerError)
 	}
 
-	return s.Render(c, templates.AOCLeaderboard(data, fetcher.EstimateAOCDayCount(s.config.Year)))
+	// the merged view is only as fresh as its stalest leaderboard, never updated if one of them
+	// never succeeded (LastSuccess 0)
+	lastUpdated := 0
+	hasStatus := false
+	staleWarning := ""
+	for _, status := range statuses {
+		if len(leaderboardId) != 0 && status.LeaderboardId != leaderboardId {
+			continue
+		}
+		if !hasStatus || status.LastSuccess < lastUpdated {
+			lastUpdated = status.LastSuccess
+			hasStatus = true
+		}
+		if status.ErrorKind != types.FetchErrorNone && s.HasRole(c, types.RoleAdmin) {
+			staleWarning = fmt.Sprintf("AoC data is stale: %s", status.ErrorKind.Description())
+		}
+	}
+
+	return s.Render(c, templates.AOCLeaderboard(scored.Rows, scored.VerifiedScores, scored.Event.NumDays, year, leaderboardId, lastUpdated, staleWarning))
 }
 
-func (s *Server) HandleOAuthRedir(c *fiber.Ctx) error {
-	oauth2Code := c.Query("code", "")
-	if len(oauth2Code) == 0 {
-		redirect(c, "/")
+type scoredLeaderboard struct {
+	Event          *types.AOCEvent
+	Rows           []*scoring.Row
+	VerifiedScores map[int]int // indexed by aoc id
+}
+
+// scoreLeaderboard ranks the private leaderboard of the year, every board merged together if leaderboardId is empty
+func (s *Server) scoreLeaderboard(year string, leaderboardId string) (*scoredLeaderboard, error) {
+	input, err := scoring.LoadInput(s.db, year, leaderboardId)
+	if err != nil {
+		return nil, err
 	}
 
-	sess, err := s.store.Get(c)
+	engine := scoring.ForEvent(input.Event)
+	rows := engine.Score(input)
+
+	// ranks are provisional, the verified scores only count reviewed submissions
+	input.VerifiedOnly = true
+	verifiedScores := map[int]int{}
+	for _, row := range engine.Score(input) {
+		verifiedScores[row.Entry.User.UserId] = row.AdjustedScore
+	}
+
+	return &scoredLeaderboard{
+		Event:          input.Event,
+		Rows:           rows,
+		VerifiedScores: verifiedScores,
+	}, nil
+}
+
+// getYear returns the year from the route or the year query, or the configured year if there is neither
+func (s *Server) getYear(c *fiber.Ctx) (string, bool) {
+	year := c.Params("year", c.Query("year", s.config.Year))
+	if year == s.config.Year {
+		return year, true
+	}
+
+	years, err := s.db.GetEventYears()
 	if err != nil {
-		return c.SendStatus(http.StatusInternalServerError)
+		log.Println(err)
+		return "", false
 	}
-	defer sess.Save()
 
-	client := &http.Client{}
+	return year, slices.Contains(years, year)
+}
+
+// getPrivateLeaderboard returns the private leaderboard picked by the board query, nil means every board merged together
+func (s *Server) getPrivateLeaderboard(c *fiber.Ctx, year string) (*types.AOCPrivateLeaderboard, bool) {
+	leaderboardId := c.Query("board", "")
+	if len(leaderboardId) == 0 {
+		return nil, true
+	}
+
+	leaderboard, err := s.db.GetPrivateLeaderboard(leaderboardId, year)
+	if err != nil {
+		log.Println(err)
+		return nil, false
+	}
+
+	return leaderboard, leaderboard != nil
+}
+
+// getYears returns every browsable year, newest first
+func (s *Server) getYears() ([]string, error) {
+	years, err := s.db.GetEventYears()
+	if err != nil {
+		return nil, err
+	}
+
+	if !slices.Contains(years, s.config.Year) {
+		years = append(years, s.config.Year)
+	}
+	slices.Sort(years)
+	slices.Reverse(years)
+
+	return years, nil
+}
 
-	body := url.Values{}
-	body.Add("client_id", s.config.OAuth2GithubClientId)
-	body.Add("client_secret", s.config.OAuth2GithubSecret)
-	body.Add("code", oauth2Code)
+// getEvent returns the stored event for the year, its days are estimated from the calendar until it's fetched
+func (s *Server) getEvent(year string) (*types.AOCEvent, error) {
+	return scoring.LoadEvent(s.db, year)
+}
 
-	req, err := http.NewRequest(
-		"POST",
-		"https://github.com/login/oauth/access_token",
-		strings.NewReader(body.Encode()),
-	)
-	req.Header.Add("Accept", "application/json")
+// HandleLogin sends the user to the provider to log in, the provider sends them back to HandleOAuthRedir
+func (s *Server) HandleLogin(c *fiber.Ctx) error {
+	provider := auth.Find(s.config.Providers, c.Params("provider"))
+	if provider == nil {
+		return c.SendStatus(http.StatusNotFound)
+	}
 
+	sess, err := s.store.Get(c)
 	if err != nil {
-		log.Println("WARN: Failed to create github oauth2 request")
 		return c.SendStatus(http.StatusInternalServerError)
 	}
+	defer sess.Save()
 
-	resp, err := client.Do(req)
+	login, err := auth.NewLoginState(provider.Name())
 	if err != nil {
-		log.Printf("ERROR: Failed to fetch github access_token %s\n", err)
+		log.Println(err)
 		return c.SendStatus(http.StatusInternalServerError)
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return redirect(c, "/")
+	authUrl, err := provider.AuthURL(s.redirectUri(), login)
+	if err != nil {
+		log.Println(err)
+		return c.SendStatus(http.StatusBadGateway)
 	}
 
-	parsedBody := struct {
-		AccessToken string `json:"access_token"`
-		Scope       string `json:"scope"`
-		TokenType   string `json:"token_type"`
-	}{}
+	sess.Set("login_provider", login.Provider)
+	sess.Set("login_state", login.State)
+	sess.Set("login_verifier", login.Verifier)
+	sess.Set("login_nonce", login.Nonce)
 
-	decoder := json.NewDecoder(resp.Body)
-	err = decoder.Decode(&parsedBody)
-	if err != nil {
+	return redirect(c, authUrl)
+}
+
+func (s *Server) HandleOAuthRedir(c *fiber.Ctx) error {
+	oauth2Code := c.Query("code", "")
+	if len(oauth2Code) == 0 {
 		return redirect(c, "/")
 	}
 
-	if len(parsedBody.AccessToken) == 0 {
-		return redirect(c, "/")
+	sess, err := s.store.Get(c)
+	if err != nil {
+		return c.SendStatus(http.StatusInternalServerError)
 	}
+	defer sess.Save()
 
-	sess.Set("access_token", parsedBody.AccessToken)
+	// the login state is single use
+	login := &auth.LoginState{}
+	login.Provider, _ = sess.Get("login_provider").(string)
+	login.State, _ = sess.Get("login_state").(string)
+	login.Verifier, _ = sess.Get("login_verifier").(string)
+	login.Nonce, _ = sess.Get("login_nonce").(string)
+	sess.Delete("login_provider")
+	sess.Delete("login_state")
+	sess.Delete("login_verifier")
+	sess.Delete("login_nonce")
+
+	// a callback that wasn't started from this session could log the user into someone else's account
+	if !login.Matches(c.Query("state", "")) {
+		log.Println("WARN: OAuth2 callback with an invalid state")
+		return c.SendStatus(http.StatusForbidden)
+	}
 
-	data, err := FetchGithubOAuthUserEntpoint(parsedBody.AccessToken)
+	provider := auth.Find(s.config.Providers, login.Provider)
+	if provider == nil {
+		return redirect(c, "/")
+	}
+
+	identity, err := provider.Exchange(c.Context(), oauth2Code, s.redirectUri(), login)
+	if errors.Is(err, auth.ErrLoginDenied) {
+		return redirect(c, "/")
+	}
 	if err != nil {
 		log.Println(err)
-		return c.SendStatus(http.StatusInternalServerError)
+		return c.SendStatus(http.StatusBadGateway)
 	}
 
-	user, err := s.db.GetUserByGithubId(data.GithubUserId)
+	setSessionIdentity(sess, identity)
+
+	user, err := s.db.GetUserByIdentity(*identity)
 	if err != nil {
+		log.Println(err)
 		return c.SendStatus(http.StatusInternalServerError)
 	}
 
 	if user == nil {
-		return s.Render(c, templates.OAuthReturn(false))
+		return s.renderLinkRequest(c, *identity, "")
 	}
 
-	sess.Set("aoc_id", user.UserId)
-	sess.Set("name", user.Name)
+	s.login(sess, user)
 
 	return redirect(c, "/")
 }
@@ -215,43 +470,154 @@ func (s *Server) HandleOauthLink(c *fiber.Ctx) error {
 	}
 	defer sess.Save()
 
-	token, ok := sess.Get("access_token").(string)
-	if !ok || len(token) == 0 {
-		fmt.Println("Invalid access token")
+	identity := sessionIdentity(sess)
+	if identity == nil {
 		return redirect(c, "/")
 	}
 
 	aocId_s := c.FormValue("aoc-id", "")
 	if len(aocId_s) == 0 {
-		return s.Render(c, templates.OAuthReturn(true))
+		return s.Render(c, templates.OAuthReturn("Invalid AoC Id"))
 	}
 	aocId_s = strings.TrimPrefix(aocId_s, "#")
-	aocId, err := strconv.ParseInt(aocId_s, 10, 64)
+	aocId, err := strconv.Atoi(aocId_s)
 	if err != nil {
-		return s.Render(c, templates.OAuthReturn(true))
+		return s.Render(c, templates.OAuthReturn("Invalid AoC Id"))
+	}
+
+	// linked users transfer their account from the settings
+	user, err := s.db.GetUserByIdentity(*identity)
+	if err != nil {
+		log.Println(err)
+		return c.SendStatus(http.StatusInternalServerError)
+	}
+	if user != nil {
+		return redirect(c, "/settings")
 	}
 
-	data, err := FetchGithubOAuthUserEntpoint(token)
+	linkToken, err := newLinkToken()
 	if err != nil {
 		log.Println(err)
 		return c.SendStatus(http.StatusInternalServerError)
 	}
 
-	user, err := s.db.LinkGithubUser(data.GithubUserId, data.AvatarUrl, aocId)
+	err = s.db.CreateLinkRequest(&types.AOCLinkRequest{
+		Identity:  *identity,
+		AocId:     aocId,
+		Token:     linkToken,
+		CreatedAt: int(time.Now().Unix()),
+	})
+	switch {
+	case errors.Is(err, database.ErrUserNotFound):
+		return s.Render(c, templates.OAuthReturn("That AoC Id isn't on any of our leaderboards"))
+	case errors.Is(err, database.ErrUserAlreadyLinked):
+		return s.Render(c, templates.OAuthReturn("That AoC Id is already linked to another account, ask an admin if it is yours"))
+	case err != nil:
+		log.Println(err)
+		return c.SendStatus(http.StatusInternalServerError)
+	}
+
+	return s.renderLinkRequest(c, *identity, "")
+}
+
+// HandleOauthVerify checks if a fetch confirmed the link request yet
+func (s *Server) HandleOauthVerify(c *fiber.Ctx) error {
+	sess, err := s.store.Get(c)
+	if err != nil {
+		return c.SendStatus(http.StatusInternalServerError)
+	}
+	defer sess.Save()
+
+	identity := sessionIdentity(sess)
+	if identity == nil {
+		return redirect(c, "/")
+	}
+
+	user, err := s.db.GetUserByIdentity(*identity)
 	if err != nil {
 		log.Println(err)
 		return c.SendStatus(http.StatusInternalServerError)
 	}
 
 	if user == nil {
-		return s.Render(c, templates.OAuthReturn(true))
+		return s.renderLinkRequest(c, *identity, "Not confirmed yet, the leaderboards are only fetched every few minutes")
+	}
+
+	s.login(sess, user)
+
+	return redirect(c, "/")
+}
+
+// renderLinkRequest shows how to confirm the pending link request, or asks for an AoC Id if there is none
+func (s *Server) renderLinkRequest(c *fiber.Ctx, identity types.AOCIdentity, formErr string) error {
+	request, err := s.db.GetLinkRequest(identity)
+	if err != nil {
+		log.Println(err)
+		return c.SendStatus(http.StatusInternalServerError)
+	}
+
+	if request == nil || request.Expired(time.Now()) {
+		return s.Render(c, templates.OAuthReturn(""))
+	}
+
+	return s.Render(c, templates.OAuthVerify(request, formErr))
+}
+
+// login saves the linked user in the session, promoting the configured admins
+func (s *Server) login(sess *session.Session, user *types.AOCUser) {
+	if slices.ContainsFunc(s.config.AdminIdentities, user.Identity.Is) && user.Role != types.RoleAdmin {
+		err := s.db.SetUserRole(user.UserId, types.RoleAdmin)
+		if err != nil {
+			log.Println(err)
+		}
 	}
 
 	sess.Set("aoc_id", user.UserId)
 	sess.Set("name", user.Name)
-	sess.Set("github_id", user.GithubId)
+	setSessionIdentity(sess, &user.Identity)
+}
 
-	return redirect(c, "/")
+// sessionIdentity is the identity the user logged in with, nil if there is none
+func sessionIdentity(sess *session.Session) *types.AOCIdentity {
+	provider, _ := sess.Get("provider").(string)
+	subject, _ := sess.Get("subject").(string)
+	if len(provider) == 0 || len(subject) == 0 {
+		return nil
+	}
+
+	avatarUrl, _ := sess.Get("avatar_url").(string)
+	return &types.AOCIdentity{
+		Provider:  provider,
+		Subject:   subject,
+		AvatarUrl: avatarUrl,
+	}
+}
+
+func setSessionIdentity(sess *session.Session, identity *types.AOCIdentity) {
+	sess.Set("provider", identity.Provider)
+	sess.Set("subject", identity.Subject)
+	sess.Set("avatar_url", identity.AvatarUrl)
+}
+
+// redirectUri is where the providers send the users back once they logged in
+func (s *Server) redirectUri() string {
+	redirectUri, err := url.JoinPath(s.config.BaseURL, "/oauth2")
+	if err != nil {
+		log.Printf("Redirect URI invalid: %s\n", s.config.BaseURL)
+	}
+
+	return redirectUri
+}
+
+// newLinkToken returns the token users put in their AoC name to prove they own the account
+func newLinkToken() (string, error) {
+	b := make([]byte, 4)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+
+	return "aoclb-" + hex.EncodeToString(b), nil
 }
 
 func (s *Server) Render(c *fiber.Ctx, component templ.Component) error {
@@ -261,7 +627,10 @@ func (s *Server) Render(c *fiber.Ctx, component templ.Component) error {
 	renderOrder := []func(templ.Component) templ.Component{}
 
 	if c.Get("HX-Request") != "true" {
-		renderOrder = append(renderOrder, templates.Index)
+		csrfToken, _ := c.Locals(csrfContextKey).(string)
+		renderOrder = append(renderOrder, func(body templ.Component) templ.Component {
+			return templates.Index(csrfToken, body)
+		})
 	}
 
 	// we need to render bottom-up
@@ -283,79 +652,63 @@ func redirect(c *fiber.Ctx, target string) error {
 	return c.Redirect(target)
 }
 
-type GithubUserEndpointData struct {
-	AvatarUrl    string `json:"avatar_url"`
-	GithubUserId int    `json:"id"`
-}
-
-func FetchGithubOAuthUserEntpoint(accessToken string) (*GithubUserEndpointData, error) {
-	client := &http.Client{}
-
-	req, err := http.NewRequest(
-		"GET",
-		"https://api.github.com/user",
-		nil,
-	)
-	req.Header.Add("Accept", "application/json")
-	req.Header.Add("Authorization", "Bearer "+accessToken)
-	req.Header.Add("X-GitHub-Api-Version", "2022-11-28")
-
+func (s *Server) ValidateLogin(c *fiber.Ctx) bool {
+	sess, err := s.store.Get(c)
 	if err != nil {
-		return nil, errors.New("WARN: Failed to create github api request")
+		return false
 	}
 
-	resp, err := client.Do(req)
-	if err != nil {
-		return nil, errors.New(fmt.Sprintf("ERROR: Failed to fetch github user endpoint %s\n", err))
-	}
-	defer resp.Body.Close()
+	// the identity isn't checked with the provider again, fetching it every request makes the app feel slow
+	return sessionIdentity(sess) != nil
+}
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, errors.New(fmt.Sprintf("ERROR: Failed to fetch github user endpoint, status: %d\n", resp.StatusCode))
+// SessionUser returns the aoc user of the logged in user, nil if there is none
+func (s *Server) SessionUser(c *fiber.Ctx) *types.AOCUser {
+	if !s.ValidateLogin(c) {
+		return nil
 	}
 
-	parsedBody := GithubUserEndpointData{}
-
-	decoder := json.NewDecoder(resp.Body)
-	err = decoder.Decode(&parsedBody)
+	sess, err := s.store.Get(c)
 	if err != nil {
-		fmt.Println(err)
-		return nil, errors.New("ERROR: Failed to parse user endpoint body")
+		return nil
 	}
 
-	return &parsedBody, nil
-}
+	aocId, ok := sess.Get("aoc_id").(int)
+	if !ok {
+		return nil
+	}
 
-func (s *Server) ValidateGithubLogin(c *fiber.Ctx) bool {
-	sess, err := s.store.Get(c)
+	user, err := s.db.GetUserByAocId(aocId)
 	if err != nil {
-		return false
+		log.Println(err)
+		return nil
 	}
-	defer sess.Save()
 
-	token, ok := sess.Get("access_token").(string)
-	if !ok || len(token) == 0 {
-		return false
+	// the account might have been unlinked or transferred since the login
+	identity := sessionIdentity(sess)
+	if user == nil || identity == nil || !user.Identity.Is(*identity) {
+		return nil
 	}
 
-	// Fetching gh every api call makes the app feel really slow and unresponsive
+	return user
+}
+
+// HasRole reports whether the logged in user has the role or a more privileged one.
+// The role is read from the database every time so a demotion takes effect right away
+func (s *Server) HasRole(c *fiber.Ctx, role types.AOCUserRole) bool {
+	user := s.SessionUser(c)
+	return user != nil && user.Role.Includes(role)
+}
 
-	// data, err := FetchGithubOAuthUserEntpoint(token)
-	// if err != nil {
-	// 	log.Println(err)
-	// 	return false
-	// }
-	//
-	// user, err := s.db.GetUserByGithubId(data.GithubUserId)
-	// if err != nil || user == nil {
-	// 	return false
-	// }
-	//
-	// sess.Set("aoc_id", user.UserId)
-	// sess.Set("name", user.Name)
-	// sess.Set("github_id", user.GithubId)
+// RequireRole is a middleware refusing the users that don't have the role
+func (s *Server) RequireRole(role types.AOCUserRole) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if !s.HasRole(c, role) {
+			return c.SendStatus(http.StatusForbidden)
+		}
 
-	return true
+		return c.Next()
+	}
 }
 
 func (s *Server) HandleLogout(c *fiber.Ctx) error {
@@ -368,18 +721,40 @@ func (s *Server) HandleLogout(c *fiber.Ctx) error {
 }
 
 func (s *Server) HandleModifiers(c *fiber.Ctx) error {
-	modifiers, err := s.db.GetModifiers()
+	year, ok := s.getYear(c)
+	if !ok {
+		return c.SendStatus(http.StatusNotFound)
+	}
+
+	modifiers, err := s.db.GetModifiers(year)
 	if err != nil {
 		log.Println(err)
 		return c.SendStatus(http.StatusInternalServerError)
 	}
 
-	return s.Render(c, templates.ModifiersPage(modifiers))
+	set, err := s.db.GetModifierSet(year)
+	if err != nil {
+		log.Println(err)
+		return c.SendStatus(http.StatusInternalServerError)
+	}
+
+	return s.Render(c, templates.ModifiersPage(year, modifiers, set != nil && set.Locked))
 }
 
 
 func (s *Server) HandleAbout(c *fiber.Ctx) error {
-	return s.Render(c, templates.About())
+	year, ok := s.getYear(c)
+	if !ok {
+		return c.SendStatus(http.StatusNotFound)
+	}
+
+	event, err := s.getEvent(year)
+	if err != nil {
+		log.Println(err)
+		return c.SendStatus(http.StatusInternalServerError)
+	}
+
+	return s.Render(c, templates.About(year, event.ScoringMode))
 }
 
 type userSubmissionFormBody struct {
@@ -390,27 +765,53 @@ type userSubmissionFormBody struct {
 	SubmissionUrl string `form:"submission-url"`
 }
 
-func (s *Server) HandleUserModifiersGet(c *fiber.Ctx) error {
-	if !s.ValidateGithubLogin(c) {
-		return c.SendStatus(http.StatusForbidden)
+// checkSubmission validates the submission and fills in its modifier, the message explains what is wrong.
+// An edited submission keeps its currentLanguage even if it was retired since
+func (s *Server) checkSubmission(submission *types.AOCUserSubmission, dayCount int, currentLanguage string) (string, error) {
+	if len(submission.SubmissionUrl) == 0 {
+		return "Missing submission url", nil
 	}
 
-	sess, err := s.store.Get(c)
+	if submission.Date <= 0 || submission.Date > dayCount {
+		return "Invalid date", nil
+	}
+
+	langModifier, err := s.db.GetModifiersByLanguageName(submission.Year, submission.LanguageName)
 	if err != nil {
-		return c.SendStatus(http.StatusInternalServerError)
+		return "", err
 	}
-	aocId, ok := sess.Get("aoc_id").(int)
+	if langModifier == nil || (langModifier.Retired && langModifier.LanguageName != currentLanguage) {
+		return "Invalid language selection", nil
+	}
+
+	submission.AOCSubmissionModifier = *langModifier
+
+	return "", nil
+}
+
+func (s *Server) HandleUserModifiersGet(c *fiber.Ctx) error {
+	if !s.ValidateLogin(c) {
+		return c.SendStatus(http.StatusForbidden)
+	}
+
+	year, ok := s.getYear(c)
 	if !ok {
-		return c.SendStatus(http.StatusInternalServerError)
+		return c.SendStatus(http.StatusNotFound)
 	}
 
-	userSubmissions, err := s.db.GetUserSubmissions(s.config.Year, aocId)
+	user := s.SessionUser(c)
+	if user == nil {
+		return c.SendStatus(http.StatusForbidden)
+	}
+	aocId := user.UserId
+
+	userSubmissions, err := s.db.GetUserSubmissions(year, aocId)
 	if err != nil {
 		log.Println(err)
 		return c.SendStatus(http.StatusInternalServerError)
 	}
 
-	modifiers, err := s.db.GetModifiers()
+	modifiers, err := s.db.GetModifiers(year)
 	if err != nil {
 		log.Println(err)
 		return c.SendStatus(http.StatusInternalServerError)
@@ -426,29 +827,37 @@ func (s *Server) HandleUserModifiersGet(c *fiber.Ctx) error {
 	var formPrefill *types.AOCUserSubmission = nil
 	if data.SubmissionId != 0 {
 		submission, err := s.db.GetUserSubmissionById(data.SubmissionId)
-		if err == nil && submission != nil && submission.AocUserId == aocId {
+		if err == nil && submission != nil && submission.AocUserId == aocId && submission.Year == year {
 			formPrefill = submission
 		}
 	}
 
-	return s.Render(c, templates.UserModifiers(userSubmissions, modifiers, fetcher.EstimateAOCDayCount(s.config.Year), formPrefill))
+	event, err := s.getEvent(year)
+	if err != nil {
+		log.Println(err)
+		return c.SendStatus(http.StatusInternalServerError)
+	}
+
+	return s.Render(c, templates.UserModifiers(userSubmissions, modifiers, year, event.UnlockedDays(time.Now()), formPrefill))
 }
 
 func (s *Server) HandleUserModifiersPatch(c *fiber.Ctx) error {
-	if !s.ValidateGithubLogin(c) {
+	if !s.ValidateLogin(c) {
 		return c.SendStatus(http.StatusForbidden)
 	}
 
-	sess, err := s.store.Get(c)
-	if err != nil {
-		return c.SendStatus(http.StatusInternalServerError)
-	}
-	aocId, ok := sess.Get("aoc_id").(int)
+	year, ok := s.getYear(c)
 	if !ok {
-		return c.SendStatus(http.StatusInternalServerError)
+		return c.SendStatus(http.StatusNotFound)
 	}
 
-	modifiers, err := s.db.GetModifiers()
+	user := s.SessionUser(c)
+	if user == nil {
+		return c.SendStatus(http.StatusForbidden)
+	}
+	aocId := user.UserId
+
+	modifiers, err := s.db.GetModifiers(year)
 	if err != nil {
 		return c.SendStatus(http.StatusInternalServerError)
 	}
@@ -463,6 +872,7 @@ func (s *Server) HandleUserModifiersPatch(c *fiber.Ctx) error {
 	submission := &types.AOCUserSubmission{
 		AOCSubmissionModifier: types.AOCSubmissionModifier{LanguageName: data.LanguageName},
 		AocUserId:             aocId,
+		Year:                  year,
 		Id:                    data.SubmissionId,
 		SubmissionUrl:         data.SubmissionUrl,
 		Date:                  data.Day,
@@ -474,7 +884,7 @@ func (s *Server) HandleUserModifiersPatch(c *fiber.Ctx) error {
 		log.Println(err)
 		return c.SendStatus(http.StatusInternalServerError)
 	}
-	if oldSubmission == nil {
+	if oldSubmission == nil || oldSubmission.Year != year {
 		return c.SendStatus(http.StatusNotFound)
 	}
 	if oldSubmission.AocUserId != aocId {
@@ -485,27 +895,22 @@ func (s *Server) HandleUserModifiersPatch(c *fiber.Ctx) error {
 		return c.SendStatus(http.StatusUnprocessableEntity)
 	}
 
-	dayCount := fetcher.EstimateAOCDayCount(s.config.Year)
-
-	if len(submission.SubmissionUrl) == 0 {
-		return s.Render(c, templates.UserModifierForm(modifiers, submission, dayCount, "Missing submission url"))
-	}
-
-	if submission.Date <= 0 || submission.Date > fetcher.EstimateAOCDayCount(s.config.Year) {
-		return s.Render(c, templates.UserModifierForm(modifiers, submission, dayCount, "Invalid date"))
+	event, err := s.getEvent(year)
+	if err != nil {
+		log.Println(err)
+		return c.SendStatus(http.StatusInternalServerError)
 	}
+	dayCount := event.UnlockedDays(time.Now())
 
-	langModifier, err := s.db.GetModifiersByLanguageName(submission.LanguageName)
+	formErr, err := s.checkSubmission(submission, dayCount, oldSubmission.LanguageName)
 	if err != nil {
-		fmt.Println(err)
-		return c.SendStatus(http.StatusUnprocessableEntity)
+		log.Println(err)
+		return c.SendStatus(http.StatusInternalServerError)
 	}
-	if langModifier == nil {
-		return s.Render(c, templates.UserModifierForm(modifiers, submission, dayCount, "Invalid language selection"))
+	if len(formErr) != 0 {
+		return s.Render(c, templates.UserModifierForm(modifiers, submission, year, dayCount, formErr))
 	}
 
-	submission.AOCSubmissionModifier = *langModifier
-
 	newSubmission, err := s.db.UpdateUserSubmission(submission)
 	if err != nil {
 		log.Println(err)
@@ -513,24 +918,26 @@ func (s *Server) HandleUserModifiersPatch(c *fiber.Ctx) error {
 	}
 
 	c.Set("HX-Trigger", "refresh-leaderboard")
-	return s.Render(c, templates.OOBUpdateUserModifier(newSubmission, templates.UserModifierForm(modifiers, nil, dayCount, "")))
+	return s.Render(c, templates.OOBUpdateUserModifier(newSubmission, templates.UserModifierForm(modifiers, nil, year, dayCount, "")))
 }
 
 func (s *Server) HandleUserModifiersPost(c *fiber.Ctx) error {
-	if !s.ValidateGithubLogin(c) {
+	if !s.ValidateLogin(c) {
 		return c.SendStatus(http.StatusForbidden)
 	}
 
-	sess, err := s.store.Get(c)
-	if err != nil {
-		return c.SendStatus(http.StatusInternalServerError)
-	}
-	aocId, ok := sess.Get("aoc_id").(int)
+	year, ok := s.getYear(c)
 	if !ok {
-		return c.SendStatus(http.StatusInternalServerError)
+		return c.SendStatus(http.StatusNotFound)
+	}
+
+	user := s.SessionUser(c)
+	if user == nil {
+		return c.SendStatus(http.StatusForbidden)
 	}
+	aocId := user.UserId
 
-	modifiers, err := s.db.GetModifiers()
+	modifiers, err := s.db.GetModifiers(year)
 	if err != nil {
 		return c.SendStatus(http.StatusInternalServerError)
 	}
@@ -545,6 +952,7 @@ func (s *Server) HandleUserModifiersPost(c *fiber.Ctx) error {
 	submission := &types.AOCUserSubmission{
 		AOCSubmissionModifier: types.AOCSubmissionModifier{LanguageName: data.LanguageName},
 		AocUserId:             aocId,
+		Year:                  year,
 		Id:                    0,
 		SubmissionUrl:         data.SubmissionUrl,
 		Date:                  data.Day,
@@ -555,53 +963,51 @@ func (s *Server) HandleUserModifiersPost(c *fiber.Ctx) error {
 		return c.SendStatus(http.StatusUnprocessableEntity)
 	}
 
-	dayCount := fetcher.EstimateAOCDayCount(s.config.Year)
-
-	if len(submission.SubmissionUrl) == 0 {
-		return s.Render(c, templates.UserModifierForm(modifiers, submission, dayCount, "Missing submission url"))
-	}
-
-	if submission.Date <= 0 || submission.Date > fetcher.EstimateAOCDayCount(s.config.Year) {
-		return s.Render(c, templates.UserModifierForm(modifiers, submission, dayCount, "Invalid date"))
+	event, err := s.getEvent(year)
+	if err != nil {
+		log.Println(err)
+		return c.SendStatus(http.StatusInternalServerError)
 	}
+	dayCount := event.UnlockedDays(time.Now())
 
-	langModifier, err := s.db.GetModifiersByLanguageName(submission.LanguageName)
+	formErr, err := s.checkSubmission(submission, dayCount, "")
 	if err != nil {
-		fmt.Println(err)
-		return c.SendStatus(http.StatusUnprocessableEntity)
+		log.Println(err)
+		return c.SendStatus(http.StatusInternalServerError)
 	}
-	if langModifier == nil {
-		return s.Render(c, templates.UserModifierForm(modifiers, submission, dayCount, "Invalid language selection"))
+	if len(formErr) != 0 {
+		return s.Render(c, templates.UserModifierForm(modifiers, submission, year, dayCount, formErr))
 	}
 
-	submission.AOCSubmissionModifier = *langModifier
-
-	submission, err = s.db.AddUserSubmission(s.config.Year, submission)
+	submission, err = s.db.AddUserSubmission(year, submission)
 	if err != nil {
 		return c.SendStatus(http.StatusInternalServerError)
 	}
+	s.publishSubmission(events.SubmissionCreated, events.NewSubmissionData(user, submission))
 
 	c.Set("HX-Trigger", "refresh-leaderboard")
 
-	return s.Render(c, templates.OOBAppendUserModifier(submission, templates.UserModifierForm(modifiers, nil, dayCount, "")))
+	return s.Render(c, templates.OOBAppendUserModifier(submission, templates.UserModifierForm(modifiers, nil, year, dayCount, "")))
 }
 
 func (s *Server) HandleUserModifiersDelete(c *fiber.Ctx) error {
-	if !s.ValidateGithubLogin(c) {
+	if !s.ValidateLogin(c) {
 		return c.SendStatus(http.StatusForbidden)
 	}
 
-	sess, err := s.store.Get(c)
-	if err != nil {
-		return c.SendStatus(http.StatusInternalServerError)
-	}
-	aocId, ok := sess.Get("aoc_id").(int)
+	year, ok := s.getYear(c)
 	if !ok {
-		return c.SendStatus(http.StatusInternalServerError)
+		return c.SendStatus(http.StatusNotFound)
+	}
+
+	user := s.SessionUser(c)
+	if user == nil {
+		return c.SendStatus(http.StatusForbidden)
 	}
+	aocId := user.UserId
 
 	data := &userSubmissionFormBody{}
-	err = c.QueryParser(data) // delete requests don't have bodies
+	err := c.QueryParser(data) // delete requests don't have bodies
 	if err != nil {
 		fmt.Println(err)
 		return c.SendStatus(http.StatusUnprocessableEntity)
@@ -612,7 +1018,7 @@ func (s *Server) HandleUserModifiersDelete(c *fiber.Ctx) error {
 		log.Println(err)
 		return c.SendStatus(http.StatusInternalServerError)
 	}
-	if submission == nil {
+	if submission == nil || submission.Year != year {
 		return c.SendStatus(http.StatusNotFound)
 	}
 	if submission.AocUserId != aocId {
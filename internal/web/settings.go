@@ -0,0 +1,231 @@
+package web
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"uocsclub.net/aoclb/internal/auth"
+	"uocsclub.net/aoclb/internal/database"
+	"uocsclub.net/aoclb/internal/types"
+	"uocsclub.net/aoclb/internal/web/templates"
+)
+
+func (s *Server) HandleSettingsGet(c *fiber.Ctx) error {
+	if !s.ValidateLogin(c) {
+		return redirect(c, "/")
+	}
+
+	return s.renderSettings(c, "")
+}
+
+func (s *Server) HandleSettingsUnlink(c *fiber.Ctx) error {
+	user := s.SessionUser(c)
+	if user == nil {
+		return c.SendStatus(http.StatusForbidden)
+	}
+
+	err := s.db.UnlinkUser(user.UserId)
+	if err != nil {
+		log.Println(err)
+		return c.SendStatus(http.StatusInternalServerError)
+	}
+
+	return s.HandleLogout(c)
+}
+
+// HandleSettingsTransfer starts moving the identity to another aoc account, the
+// current link stays until the new one is confirmed like a first link
+func (s *Server) HandleSettingsTransfer(c *fiber.Ctx) error {
+	user := s.SessionUser(c)
+	if user == nil {
+		return c.SendStatus(http.StatusForbidden)
+	}
+
+	aocId, err := strconv.Atoi(strings.TrimPrefix(c.FormValue("aoc-id", ""), "#"))
+	if err != nil {
+		return s.renderSettings(c, "Invalid AoC Id")
+	}
+	if aocId == user.UserId {
+		return s.renderSettings(c, "You are already linked to that AoC Id")
+	}
+
+	linkToken, err := newLinkToken()
+	if err != nil {
+		log.Println(err)
+		return c.SendStatus(http.StatusInternalServerError)
+	}
+
+	err = s.db.CreateLinkRequest(&types.AOCLinkRequest{
+		Identity:  user.Identity,
+		AocId:     aocId,
+		Token:     linkToken,
+		CreatedAt: int(time.Now().Unix()),
+	})
+	switch {
+	case errors.Is(err, database.ErrUserNotFound):
+		return s.renderSettings(c, "That AoC Id isn't on any of our leaderboards")
+	case errors.Is(err, database.ErrUserAlreadyLinked):
+		return s.renderSettings(c, "That AoC Id is already linked to another account, ask an admin if it is yours")
+	case err != nil:
+		log.Println(err)
+		return c.SendStatus(http.StatusInternalServerError)
+	}
+
+	return s.renderSettings(c, "")
+}
+
+func (s *Server) renderSettings(c *fiber.Ctx, formErr string) error {
+	sess, err := s.store.Get(c)
+	if err != nil {
+		return c.SendStatus(http.StatusInternalServerError)
+	}
+	defer sess.Save()
+
+	identity := sessionIdentity(sess)
+	if identity == nil {
+		return redirect(c, "/")
+	}
+
+	user, err := s.db.GetUserByIdentity(*identity)
+	if err != nil {
+		log.Println(err)
+		return c.SendStatus(http.StatusInternalServerError)
+	}
+	if user == nil {
+		return s.renderLinkRequest(c, *identity, "")
+	}
+
+	// a transfer was confirmed since the login
+	if aocId, _ := sess.Get("aoc_id").(int); aocId != user.UserId {
+		s.login(sess, user)
+	}
+
+	request, err := s.db.GetLinkRequest(*identity)
+	if err != nil {
+		log.Println(err)
+		return c.SendStatus(http.StatusInternalServerError)
+	}
+	if request != nil && request.Expired(time.Now()) {
+		request = nil
+	}
+
+	// the provider might have been removed from the config since the user linked
+	providerName := user.Identity.Provider
+	if provider := auth.Find(s.config.Providers, providerName); provider != nil {
+		providerName = provider.DisplayName()
+	}
+
+	tokens, err := s.db.GetApiTokens(user.UserId)
+	if err != nil {
+		log.Println(err)
+		return c.SendStatus(http.StatusInternalServerError)
+	}
+
+	return s.Render(c, templates.SettingsPage(user, providerName, request, formErr, tokens))
+}
+
+// maxApiTokens keeps a leaked session from minting tokens forever
+const maxApiTokens = 10
+
+type apiTokenFormBody struct {
+	Id   int    `form:"id" query:"id"`
+	Name string `form:"name"`
+}
+
+// HandleSettingsTokensPost creates an api token, it is only shown once
+func (s *Server) HandleSettingsTokensPost(c *fiber.Ctx) error {
+	user := s.SessionUser(c)
+	if user == nil {
+		return c.SendStatus(http.StatusForbidden)
+	}
+
+	data := &apiTokenFormBody{}
+	err := c.BodyParser(data)
+	if err != nil {
+		return c.SendStatus(http.StatusUnprocessableEntity)
+	}
+
+	data.Name = strings.TrimSpace(data.Name)
+	if len(data.Name) == 0 || len(data.Name) > 50 {
+		return s.renderApiTokens(c, user, "", "Token names are 1 to 50 characters long")
+	}
+
+	tokens, err := s.db.GetApiTokens(user.UserId)
+	if err != nil {
+		log.Println(err)
+		return c.SendStatus(http.StatusInternalServerError)
+	}
+	if len(tokens) >= maxApiTokens {
+		return s.renderApiTokens(c, user, "", "Revoke a token before creating another one")
+	}
+
+	token, err := newApiToken()
+	if err != nil {
+		log.Println(err)
+		return c.SendStatus(http.StatusInternalServerError)
+	}
+
+	err = s.db.CreateApiToken(&types.AOCApiToken{
+		AocId:     user.UserId,
+		Name:      data.Name,
+		Prefix:    token[:len(apiTokenPrefix)+6],
+		CreatedAt: int(time.Now().Unix()),
+	}, types.HashApiToken(token))
+	if err != nil {
+		log.Println(err)
+		return c.SendStatus(http.StatusInternalServerError)
+	}
+
+	return s.renderApiTokens(c, user, token, "")
+}
+
+func (s *Server) HandleSettingsTokensDelete(c *fiber.Ctx) error {
+	user := s.SessionUser(c)
+	if user == nil {
+		return c.SendStatus(http.StatusForbidden)
+	}
+
+	data := &apiTokenFormBody{}
+	err := c.QueryParser(data) // delete requests don't have bodies
+	if err != nil {
+		return c.SendStatus(http.StatusUnprocessableEntity)
+	}
+
+	err = s.db.DeleteApiToken(user.UserId, data.Id)
+	if err != nil {
+		log.Println(err)
+		return c.SendStatus(http.StatusInternalServerError)
+	}
+
+	return s.renderApiTokens(c, user, "", "")
+}
+
+func (s *Server) renderApiTokens(c *fiber.Ctx, user *types.AOCUser, newToken string, formErr string) error {
+	tokens, err := s.db.GetApiTokens(user.UserId)
+	if err != nil {
+		log.Println(err)
+		return c.SendStatus(http.StatusInternalServerError)
+	}
+
+	return s.Render(c, templates.ApiTokens(tokens, newToken, formErr))
+}
+
+const apiTokenPrefix = "aoclb_"
+
+// newApiToken returns a token for the api, the prefix makes leaked tokens easy to search for
+func newApiToken() (string, error) {
+	b := make([]byte, 24)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+
+	return apiTokenPrefix + base64.RawURLEncoding.EncodeToString(b), nil
+}
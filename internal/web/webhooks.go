@@ -0,0 +1,260 @@
+package web
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"uocsclub.net/aoclb/internal/events"
+	"uocsclub.net/aoclb/internal/notify"
+	"uocsclub.net/aoclb/internal/types"
+	"uocsclub.net/aoclb/internal/web/templates"
+)
+
+// webhookLogSize is how many deliveries the log shows
+const webhookLogSize = 100
+
+type adminWebhookFormBody struct {
+	Url    string   `form:"url"`
+	Events []string `form:"events"`
+	Active bool     `form:"active"`
+}
+
+func (s *Server) HandleAdminWebhooksGet(c *fiber.Ctx) error {
+	webhooks, err := s.db.GetWebhooks()
+	if err != nil {
+		log.Println(err)
+		return c.SendStatus(http.StatusInternalServerError)
+	}
+
+	return s.Render(c, templates.AdminWebhooksPage(s.config.Year, webhooks, webhookEventNames()))
+}
+
+func (s *Server) HandleAdminWebhooksPost(c *fiber.Ctx) error {
+	data := &adminWebhookFormBody{}
+	err := c.BodyParser(data)
+	if err != nil {
+		log.Println(err)
+		return c.SendStatus(http.StatusUnprocessableEntity)
+	}
+
+	webhook := &types.AOCWebhook{Active: true, CreatedAt: int(time.Now().Unix())}
+	formErr := parseAdminWebhookForm(data, webhook)
+	if len(formErr) != 0 {
+		return s.renderAdminWebhooks(c, formErr)
+	}
+
+	webhook.Secret, err = newWebhookSecret()
+	if err != nil {
+		log.Println(err)
+		return c.SendStatus(http.StatusInternalServerError)
+	}
+
+	err = s.db.CreateWebhook(webhook)
+	if err != nil {
+		log.Println(err)
+		return c.SendStatus(http.StatusInternalServerError)
+	}
+
+	return s.renderAdminWebhooks(c, "")
+}
+
+func (s *Server) HandleAdminWebhookPatch(c *fiber.Ctx) error {
+	webhook, err := s.paramWebhook(c)
+	if err != nil {
+		log.Println(err)
+		return c.SendStatus(http.StatusInternalServerError)
+	}
+	if webhook == nil {
+		return c.SendStatus(http.StatusNotFound)
+	}
+
+	data := &adminWebhookFormBody{}
+	err = c.BodyParser(data)
+	if err != nil {
+		log.Println(err)
+		return c.SendStatus(http.StatusUnprocessableEntity)
+	}
+
+	formErr := parseAdminWebhookForm(data, webhook)
+	if len(formErr) != 0 {
+		return s.Render(c, templates.AdminWebhook(webhook, webhookEventNames(), formErr))
+	}
+	webhook.Active = data.Active
+
+	err = s.db.UpdateWebhook(webhook)
+	if err != nil {
+		log.Println(err)
+		return c.SendStatus(http.StatusInternalServerError)
+	}
+
+	return s.Render(c, templates.AdminWebhook(webhook, webhookEventNames(), ""))
+}
+
+func (s *Server) HandleAdminWebhookDelete(c *fiber.Ctx) error {
+	webhook, err := s.paramWebhook(c)
+	if err != nil {
+		log.Println(err)
+		return c.SendStatus(http.StatusInternalServerError)
+	}
+	if webhook == nil {
+		return c.SendStatus(http.StatusNotFound)
+	}
+
+	err = s.db.DeleteWebhook(webhook.Id)
+	if err != nil {
+		log.Println(err)
+		return c.SendStatus(http.StatusInternalServerError)
+	}
+
+	return s.renderAdminWebhooks(c, "")
+}
+
+// HandleAdminWebhookPing queues a ping for the webhook and shows its deliveries, where it appears
+func (s *Server) HandleAdminWebhookPing(c *fiber.Ctx) error {
+	webhook, err := s.paramWebhook(c)
+	if err != nil {
+		log.Println(err)
+		return c.SendStatus(http.StatusInternalServerError)
+	}
+	if webhook == nil {
+		return c.SendStatus(http.StatusNotFound)
+	}
+
+	err = notify.QueueWebhookEvent(s.db, webhook.Id, events.Event{
+		Type: events.Ping,
+		Data: &events.PingData{WebhookId: webhook.Id},
+	})
+	if err != nil {
+		log.Println(err)
+		return c.SendStatus(http.StatusInternalServerError)
+	}
+
+	c.Set("HX-Location", templates.AdminWebhookUrl(webhook.Id))
+	return c.SendString("")
+}
+
+func (s *Server) HandleAdminWebhookDeliveriesGet(c *fiber.Ctx) error {
+	webhook, err := s.paramWebhook(c)
+	if err != nil {
+		log.Println(err)
+		return c.SendStatus(http.StatusInternalServerError)
+	}
+	if webhook == nil {
+		return c.SendStatus(http.StatusNotFound)
+	}
+
+	return s.renderAdminWebhookDeliveries(c, webhook)
+}
+
+// HandleAdminWebhookDeliveryRetry sends a delivery again, failed or not
+func (s *Server) HandleAdminWebhookDeliveryRetry(c *fiber.Ctx) error {
+	webhook, err := s.paramWebhook(c)
+	if err != nil {
+		log.Println(err)
+		return c.SendStatus(http.StatusInternalServerError)
+	}
+	if webhook == nil {
+		return c.SendStatus(http.StatusNotFound)
+	}
+
+	deliveryId, err := c.ParamsInt("delivery")
+	if err != nil {
+		return c.SendStatus(http.StatusNotFound)
+	}
+
+	err = s.db.RetryWebhookDelivery(webhook.Id, deliveryId, int(time.Now().Unix()))
+	if err != nil {
+		log.Println(err)
+		return c.SendStatus(http.StatusInternalServerError)
+	}
+
+	return s.renderAdminWebhookDeliveries(c, webhook)
+}
+
+func (s *Server) renderAdminWebhooks(c *fiber.Ctx, formErr string) error {
+	webhooks, err := s.db.GetWebhooks()
+	if err != nil {
+		log.Println(err)
+		return c.SendStatus(http.StatusInternalServerError)
+	}
+
+	return s.Render(c, templates.AdminWebhooks(webhooks, webhookEventNames(), formErr))
+}
+
+func (s *Server) renderAdminWebhookDeliveries(c *fiber.Ctx, webhook *types.AOCWebhook) error {
+	deliveries, err := s.db.GetWebhookDeliveries(webhook.Id, webhookLogSize)
+	if err != nil {
+		log.Println(err)
+		return c.SendStatus(http.StatusInternalServerError)
+	}
+
+	if c.Method() != fiber.MethodGet || c.Get("HX-Target") == "webhook-deliveries" {
+		return s.Render(c, templates.AdminWebhookDeliveries(webhook, deliveries))
+	}
+
+	return s.Render(c, templates.AdminWebhookDeliveriesPage(s.config.Year, webhook, deliveries))
+}
+
+// paramWebhook is the webhook of the id in the path, nil if there is none
+func (s *Server) paramWebhook(c *fiber.Ctx) (*types.AOCWebhook, error) {
+	id, err := c.ParamsInt("id")
+	if err != nil {
+		return nil, nil
+	}
+
+	return s.db.GetWebhook(id)
+}
+
+// parseAdminWebhookForm sets the url and events of the webhook from the form, or returns the reason it is invalid
+func parseAdminWebhookForm(data *adminWebhookFormBody, webhook *types.AOCWebhook) string {
+	target := strings.TrimSpace(data.Url)
+	parsed, err := url.Parse(target)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || len(parsed.Host) == 0 {
+		return "Invalid url, it must start with http:// or https://"
+	}
+
+	names := webhookEventNames()
+	subscribed := []string{}
+	for _, event := range data.Events {
+		if !slices.Contains(names, event) {
+			return fmt.Sprintf("Unknown event %s", event)
+		}
+		if !slices.Contains(subscribed, event) {
+			subscribed = append(subscribed, event)
+		}
+	}
+	if len(subscribed) == 0 {
+		return "Pick at least one event"
+	}
+
+	webhook.Url = target
+	webhook.Events = subscribed
+	return ""
+}
+
+func webhookEventNames() []string {
+	names := []string{}
+	for _, event := range notify.WebhookEvents {
+		names = append(names, string(event))
+	}
+
+	return names
+}
+
+func newWebhookSecret() (string, error) {
+	b := make([]byte, 32)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(b), nil
+}
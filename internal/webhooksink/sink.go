@@ -0,0 +1,78 @@
+package webhooksink
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Request is a webhook received by the sink
+type Request struct {
+	ReceivedAt int               `json:"received_at"` // unix timestamp
+	Path       string            `json:"path"`
+	Headers    map[string]string `json:"headers"`
+	Body       json.RawMessage   `json:"body"`
+}
+
+// maxRequests is how many requests GET / lists, the oldest are dropped
+const maxRequests = 100
+
+// NewHandler logs every POST and answers it with status, GET / lists the latest requests as JSON
+func NewHandler(status int) http.Handler {
+	var lock sync.Mutex
+	requests := []*Request{}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet && r.URL.Path == "/" {
+			lock.Lock()
+			defer lock.Unlock()
+
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(requests)
+			return
+		}
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		request := &Request{
+			ReceivedAt: int(time.Now().Unix()),
+			Path:       r.URL.Path,
+			Headers:    map[string]string{},
+			Body:       body,
+		}
+		for name := range r.Header {
+			if name == "Content-Type" || strings.HasPrefix(name, "X-") {
+				request.Headers[name] = r.Header.Get(name)
+			}
+		}
+		if !json.Valid(body) {
+			request.Body, _ = json.Marshal(string(body))
+		}
+
+		pretty := &bytes.Buffer{}
+		json.Indent(pretty, request.Body, "", "  ")
+		log.Printf("POST %s %v\n%s\n", request.Path, request.Headers, pretty)
+
+		lock.Lock()
+		requests = append(requests, request)
+		if len(requests) > maxRequests {
+			requests = requests[1:]
+		}
+		lock.Unlock()
+
+		w.WriteHeader(status)
+	})
+}
@@ -0,0 +1,9 @@
+ALTER TABLE leaderboard_entry ADD COLUMN day_completions TEXT;
+
+UPDATE leaderboard_entry SET day_completions = (
+    SELECT group_concat(printf('%02dd%d', day, star), ',')
+    FROM star_completion
+    WHERE star_completion.year = leaderboard_entry.year AND star_completion.user_id = leaderboard_entry.user_id
+);
+
+DROP TABLE star_completion;
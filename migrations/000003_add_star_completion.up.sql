@@ -0,0 +1,28 @@
+CREATE TABLE star_completion (
+    year VARCHAR(5) NOT NULL,
+    user_id INTEGER NOT NULL REFERENCES aoc_user(aoc_id),
+    day INTEGER NOT NULL,
+    star INTEGER NOT NULL, -- 1 or 2
+    star_ts INTEGER NOT NULL, -- unix timestamp the star was obtained at (get_star_ts)
+    star_index INTEGER NOT NULL, -- AoC's star_index, breaks ties between equal timestamps
+
+    PRIMARY KEY(year, user_id, day, star)
+);
+
+-- carry over the existing completions (01d1,01d2,...), the old format has no timestamps
+-- so they stay 0 until the next fetch overwrites them
+WITH RECURSIVE split(year, user_id, item, rest) AS (
+    SELECT year, user_id, '', day_completions || ','
+    FROM leaderboard_entry
+    WHERE day_completions IS NOT NULL AND day_completions != ''
+    UNION ALL
+    SELECT year, user_id, substr(rest, 1, instr(rest, ',') - 1), substr(rest, instr(rest, ',') + 1)
+    FROM split
+    WHERE rest != ''
+)
+INSERT OR IGNORE INTO star_completion (year, user_id, day, star, star_ts, star_index)
+SELECT year, user_id, CAST(substr(item, 1, instr(item, 'd') - 1) AS INTEGER), CAST(substr(item, instr(item, 'd') + 1) AS INTEGER), 0, 0
+FROM split
+WHERE item GLOB '[0-9]*d[12]';
+
+ALTER TABLE leaderboard_entry DROP COLUMN day_completions;
@@ -0,0 +1,5 @@
+CREATE TABLE event (
+    year VARCHAR(5) PRIMARY KEY NOT NULL,
+    num_days INTEGER NOT NULL, -- num_days from the AoC API, 25 before 2025, 12 since
+    day1_ts INTEGER NOT NULL -- unix timestamp of when day 1 unlocks
+);
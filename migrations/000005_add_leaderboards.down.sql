@@ -0,0 +1,16 @@
+CREATE TABLE global_leaderboard_entry (
+    year VARCHAR(5),
+    user_id INTEGER NOT NULL REFERENCES aoc_user(id),
+    score INTEGER NOT NULL,
+
+    PRIMARY KEY(year, user_id)
+);
+
+-- the boards are merged back together, members of several boards keep their best score
+INSERT INTO global_leaderboard_entry (year, user_id, score)
+    SELECT year, user_id, MAX(score) FROM leaderboard_entry GROUP BY year, user_id;
+
+DROP TABLE leaderboard_entry;
+ALTER TABLE global_leaderboard_entry RENAME TO leaderboard_entry;
+
+DROP TABLE leaderboard;
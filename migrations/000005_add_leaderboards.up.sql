@@ -0,0 +1,30 @@
+CREATE TABLE leaderboard (
+    id TEXT NOT NULL, -- AoC private leaderboard id
+    year VARCHAR(5) NOT NULL,
+    name TEXT NOT NULL,
+    session_env TEXT NOT NULL, -- name of the env variable holding the session cookie, never the cookie itself
+
+    PRIMARY KEY(id, year)
+);
+
+-- local scores depend on who else is on the board, so entries are tracked per board.
+-- existing entries all came from the LEADERBOARD_ID board, they are kept under a board without id
+-- until the app hands them over to it on startup
+INSERT INTO leaderboard (id, year, name, session_env)
+    SELECT DISTINCT '', year, 'Leaderboard', 'SESSION_ID' FROM leaderboard_entry WHERE year IS NOT NULL;
+
+CREATE TABLE board_leaderboard_entry (
+    leaderboard_id TEXT NOT NULL,
+    year VARCHAR(5) NOT NULL,
+    user_id INTEGER NOT NULL REFERENCES aoc_user(aoc_id),
+    score INTEGER NOT NULL,
+
+    PRIMARY KEY(leaderboard_id, year, user_id),
+    FOREIGN KEY(leaderboard_id, year) REFERENCES leaderboard(id, year)
+);
+
+INSERT INTO board_leaderboard_entry (leaderboard_id, year, user_id, score)
+    SELECT '', year, user_id, score FROM leaderboard_entry WHERE year IS NOT NULL;
+
+DROP TABLE leaderboard_entry;
+ALTER TABLE board_leaderboard_entry RENAME TO leaderboard_entry;
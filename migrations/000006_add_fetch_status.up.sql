@@ -0,0 +1,15 @@
+CREATE TABLE fetch_status (
+    leaderboard_id TEXT NOT NULL,
+    year VARCHAR(5) NOT NULL,
+    last_attempt_ts INTEGER NOT NULL DEFAULT 0,
+    last_success_ts INTEGER NOT NULL DEFAULT 0,
+    status_code INTEGER NOT NULL DEFAULT 0,
+    error TEXT NOT NULL DEFAULT '',
+    consecutive_failures INTEGER NOT NULL DEFAULT 0,
+    etag TEXT NOT NULL DEFAULT '',
+    last_modified TEXT NOT NULL DEFAULT '',
+    retry_after_ts INTEGER NOT NULL DEFAULT 0, -- unix timestamp AOC asked us to wait until with Retry-After, 0 when it didn't
+
+    PRIMARY KEY(leaderboard_id, year),
+    FOREIGN KEY(leaderboard_id, year) REFERENCES leaderboard(id, year)
+);
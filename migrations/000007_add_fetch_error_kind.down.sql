@@ -0,0 +1 @@
+ALTER TABLE fetch_status DROP COLUMN error_kind;
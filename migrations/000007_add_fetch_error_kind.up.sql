@@ -0,0 +1 @@
+ALTER TABLE fetch_status ADD COLUMN error_kind TEXT NOT NULL DEFAULT '';
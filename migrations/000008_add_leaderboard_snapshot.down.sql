@@ -0,0 +1,2 @@
+DROP TABLE leaderboard_snapshot;
+DROP TABLE snapshot_payload;
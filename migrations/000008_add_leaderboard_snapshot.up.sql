@@ -0,0 +1,16 @@
+CREATE TABLE snapshot_payload (
+    hash TEXT PRIMARY KEY NOT NULL, -- sha256 of the raw payload, identical fetches share their payload
+    payload BLOB NOT NULL -- gzip compressed json from the AoC API
+);
+
+CREATE TABLE leaderboard_snapshot (
+    id INTEGER PRIMARY KEY NOT NULL,
+    leaderboard_id TEXT NOT NULL,
+    year VARCHAR(5) NOT NULL,
+    fetched_ts INTEGER NOT NULL,
+    hash TEXT NOT NULL REFERENCES snapshot_payload(hash),
+
+    FOREIGN KEY(leaderboard_id, year) REFERENCES leaderboard(id, year)
+);
+
+CREATE INDEX leaderboard_snapshot_fetched ON leaderboard_snapshot(leaderboard_id, year, fetched_ts);
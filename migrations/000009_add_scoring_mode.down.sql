@@ -0,0 +1 @@
+ALTER TABLE event DROP COLUMN scoring_mode;
@@ -0,0 +1 @@
+ALTER TABLE event ADD COLUMN scoring_mode TEXT NOT NULL DEFAULT 'total'; -- total or per_star
@@ -0,0 +1,30 @@
+-- only the newest set survives going back to a global one, the defaults when there's no set
+CREATE TABLE global_modifiers (
+    language_name TEXT PRIMARY KEY NOT NULL,
+    modifier_dec_percent INTEGER
+);
+
+INSERT INTO global_modifiers (language_name, modifier_dec_percent)
+    SELECT language_name, modifier_dec_percent FROM modifiers WHERE year = (SELECT MAX(year) FROM modifiers);
+
+INSERT INTO global_modifiers (language_name, modifier_dec_percent)
+    SELECT language_name, modifier_dec_percent FROM default_modifiers WHERE NOT EXISTS (SELECT 1 FROM modifiers);
+
+CREATE TABLE global_modifier_submission (
+    id INTEGER PRIMARY KEY NOT NULL,
+    year VARCHAR(5),
+    user_id INTEGER NOT NULL REFERENCES aoc_user(id),
+    day TEXT NOT NULL,
+    submission_url TEXT NOT NULL,
+    language_name TEXT NOT NULL REFERENCES modifiers(language_name)
+);
+
+INSERT INTO global_modifier_submission (id, year, user_id, day, submission_url, language_name)
+    SELECT id, year, user_id, day, submission_url, language_name FROM modifier_submission;
+
+DROP TABLE modifier_submission;
+DROP TABLE modifiers;
+DROP TABLE modifier_set;
+DROP TABLE default_modifiers;
+ALTER TABLE global_modifiers RENAME TO modifiers;
+ALTER TABLE global_modifier_submission RENAME TO modifier_submission;
@@ -0,0 +1,50 @@
+-- modifiers are versioned per year so changing a bonus doesn't rescore past seasons
+CREATE TABLE modifier_set (
+    year VARCHAR(5) PRIMARY KEY NOT NULL,
+    locked INTEGER NOT NULL DEFAULT 0 -- set once the season ends, a locked set never changes again
+);
+
+-- every year already known gets a copy of the global set
+INSERT INTO modifier_set (year)
+    SELECT year FROM event
+    UNION SELECT year FROM leaderboard
+    UNION SELECT year FROM modifier_submission WHERE year IS NOT NULL;
+
+CREATE TABLE year_modifiers (
+    year VARCHAR(5) NOT NULL REFERENCES modifier_set(year),
+    language_name TEXT NOT NULL,
+    modifier_dec_percent INTEGER,
+
+    PRIMARY KEY(year, language_name)
+);
+
+INSERT INTO year_modifiers (year, language_name, modifier_dec_percent)
+    SELECT s.year, m.language_name, m.modifier_dec_percent FROM modifiers AS m CROSS JOIN modifier_set AS s;
+
+-- the global set is kept for the first year when no year is known yet
+CREATE TABLE default_modifiers (
+    language_name TEXT PRIMARY KEY NOT NULL,
+    modifier_dec_percent INTEGER
+);
+
+INSERT INTO default_modifiers (language_name, modifier_dec_percent)
+    SELECT language_name, modifier_dec_percent FROM modifiers;
+
+CREATE TABLE year_modifier_submission (
+    id INTEGER PRIMARY KEY NOT NULL,
+    year VARCHAR(5),
+    user_id INTEGER NOT NULL REFERENCES aoc_user(aoc_id),
+    day TEXT NOT NULL, -- 03d2 format 2nd star of 3rd day
+    submission_url TEXT NOT NULL,
+    language_name TEXT NOT NULL,
+
+    FOREIGN KEY(year, language_name) REFERENCES modifiers(year, language_name)
+);
+
+INSERT INTO year_modifier_submission (id, year, user_id, day, submission_url, language_name)
+    SELECT id, year, user_id, day, submission_url, language_name FROM modifier_submission;
+
+DROP TABLE modifier_submission;
+DROP TABLE modifiers;
+ALTER TABLE year_modifiers RENAME TO modifiers;
+ALTER TABLE year_modifier_submission RENAME TO modifier_submission;
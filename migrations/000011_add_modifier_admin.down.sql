@@ -0,0 +1,3 @@
+DROP INDEX modifier_audit_year;
+DROP TABLE modifier_audit;
+ALTER TABLE modifiers DROP COLUMN retired;
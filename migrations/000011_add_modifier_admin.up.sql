@@ -0,0 +1,15 @@
+-- retired languages can't be picked for new submissions, the ones already submitted still count
+ALTER TABLE modifiers ADD COLUMN retired INTEGER NOT NULL DEFAULT 0;
+
+CREATE TABLE modifier_audit (
+    id INTEGER PRIMARY KEY NOT NULL,
+    year VARCHAR(5) NOT NULL,
+    changed_ts INTEGER NOT NULL,
+    github_id INTEGER NOT NULL, -- admin who made the change
+    action TEXT NOT NULL, -- add, rename, reweight, retire or restore
+    language_name TEXT NOT NULL, -- name of the language after the change
+    old_value TEXT NOT NULL DEFAULT '',
+    new_value TEXT NOT NULL DEFAULT ''
+);
+
+CREATE INDEX modifier_audit_year ON modifier_audit(year, changed_ts);
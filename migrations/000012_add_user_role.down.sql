@@ -0,0 +1 @@
+ALTER TABLE aoc_user DROP COLUMN role;
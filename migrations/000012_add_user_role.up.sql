@@ -0,0 +1 @@
+ALTER TABLE aoc_user ADD COLUMN role TEXT NOT NULL DEFAULT 'member'; -- member, reviewer or admin
@@ -0,0 +1,4 @@
+ALTER TABLE modifier_submission DROP COLUMN reviewed_ts;
+ALTER TABLE modifier_submission DROP COLUMN reviewer_id;
+ALTER TABLE modifier_submission DROP COLUMN reject_reason;
+ALTER TABLE modifier_submission DROP COLUMN status;
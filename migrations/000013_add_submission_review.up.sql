@@ -0,0 +1,4 @@
+ALTER TABLE modifier_submission ADD COLUMN status TEXT NOT NULL DEFAULT 'pending'; -- pending, approved or rejected
+ALTER TABLE modifier_submission ADD COLUMN reject_reason TEXT NOT NULL DEFAULT '';
+ALTER TABLE modifier_submission ADD COLUMN reviewer_id INTEGER DEFAULT NULL REFERENCES aoc_user(aoc_id);
+ALTER TABLE modifier_submission ADD COLUMN reviewed_ts INTEGER NOT NULL DEFAULT 0;
@@ -0,0 +1,8 @@
+-- a github account waiting to prove it owns an aoc account by putting the token in its aoc name
+CREATE TABLE link_request (
+    github_id INTEGER PRIMARY KEY NOT NULL,
+    aoc_id INTEGER NOT NULL REFERENCES aoc_user(aoc_id),
+    token TEXT NOT NULL,
+    avatar_url TEXT NOT NULL DEFAULT '',
+    created_ts INTEGER NOT NULL
+);
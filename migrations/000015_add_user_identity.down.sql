@@ -0,0 +1,23 @@
+ALTER TABLE aoc_user ADD COLUMN github_id INTEGER DEFAULT NULL;
+ALTER TABLE aoc_user ADD COLUMN avatar_url TEXT NOT NULL DEFAULT '';
+
+-- only github identities can go back
+UPDATE aoc_user SET
+    github_id = (SELECT CAST(subject AS INTEGER) FROM user_identity AS i WHERE i.aoc_id = aoc_user.aoc_id AND provider = 'github'),
+    avatar_url = COALESCE((SELECT avatar_url FROM user_identity AS i WHERE i.aoc_id = aoc_user.aoc_id AND provider = 'github'), '');
+
+ALTER TABLE modifier_audit ADD COLUMN github_id INTEGER NOT NULL DEFAULT 0;
+UPDATE modifier_audit SET github_id = COALESCE((SELECT github_id FROM aoc_user WHERE aoc_id = modifier_audit.user_id), 0);
+ALTER TABLE modifier_audit DROP COLUMN user_id;
+
+DROP TABLE link_request;
+
+CREATE TABLE link_request (
+    github_id INTEGER PRIMARY KEY NOT NULL,
+    aoc_id INTEGER NOT NULL REFERENCES aoc_user(aoc_id),
+    token TEXT NOT NULL,
+    avatar_url TEXT NOT NULL DEFAULT '',
+    created_ts INTEGER NOT NULL
+);
+
+DROP TABLE user_identity;
@@ -0,0 +1,34 @@
+-- accounts of the identity providers (github, oidc) linked to aoc users, an aoc user has at most one
+CREATE TABLE user_identity (
+    provider TEXT NOT NULL, -- github, or the name of the oidc provider
+    subject TEXT NOT NULL, -- id of the account at the provider
+    aoc_id INTEGER NOT NULL UNIQUE REFERENCES aoc_user(aoc_id),
+    avatar_url TEXT NOT NULL DEFAULT '',
+
+    PRIMARY KEY(provider, subject)
+);
+
+INSERT INTO user_identity (provider, subject, aoc_id, avatar_url)
+    SELECT 'github', CAST(github_id AS TEXT), aoc_id, avatar_url FROM aoc_user WHERE github_id IS NOT NULL;
+
+-- audits point to the aoc user of the admin instead of their github account
+ALTER TABLE modifier_audit ADD COLUMN user_id INTEGER NOT NULL DEFAULT 0;
+UPDATE modifier_audit SET user_id = COALESCE((SELECT aoc_id FROM aoc_user WHERE github_id = modifier_audit.github_id), 0);
+ALTER TABLE modifier_audit DROP COLUMN github_id;
+
+-- pending requests are short lived, they are dropped instead of converted
+DROP TABLE link_request;
+
+CREATE TABLE link_request (
+    provider TEXT NOT NULL,
+    subject TEXT NOT NULL,
+    aoc_id INTEGER NOT NULL REFERENCES aoc_user(aoc_id),
+    token TEXT NOT NULL,
+    avatar_url TEXT NOT NULL DEFAULT '',
+    created_ts INTEGER NOT NULL,
+
+    PRIMARY KEY(provider, subject)
+);
+
+ALTER TABLE aoc_user DROP COLUMN github_id;
+ALTER TABLE aoc_user DROP COLUMN avatar_url;
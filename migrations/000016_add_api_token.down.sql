@@ -0,0 +1,2 @@
+DROP INDEX api_token_aoc_id;
+DROP TABLE api_token;
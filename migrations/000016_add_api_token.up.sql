@@ -0,0 +1,12 @@
+-- personal tokens users manage their submissions with from scripts, only the sha256 of the token is kept
+CREATE TABLE api_token (
+    id INTEGER PRIMARY KEY NOT NULL,
+    aoc_id INTEGER NOT NULL REFERENCES aoc_user(aoc_id),
+    name TEXT NOT NULL,
+    token_hash TEXT NOT NULL UNIQUE,
+    prefix TEXT NOT NULL, -- start of the token so users can tell them apart
+    created_ts INTEGER NOT NULL,
+    last_used_ts INTEGER NOT NULL DEFAULT 0
+);
+
+CREATE INDEX api_token_aoc_id ON api_token(aoc_id);
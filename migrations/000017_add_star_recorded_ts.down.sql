@@ -0,0 +1 @@
+ALTER TABLE star_completion DROP COLUMN recorded_ts;
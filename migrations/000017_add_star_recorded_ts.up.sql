@@ -0,0 +1,3 @@
+-- unix timestamp of the fetch that first saw the star, 0 for the stars members had when they joined
+-- and the ones recorded before this column
+ALTER TABLE star_completion ADD COLUMN recorded_ts INTEGER NOT NULL DEFAULT 0;
@@ -0,0 +1,4 @@
+DROP INDEX webhook_delivery_webhook_id;
+DROP INDEX webhook_delivery_due;
+DROP TABLE webhook_delivery;
+DROP TABLE webhook;
@@ -0,0 +1,27 @@
+-- outgoing webhooks managed by the admins, the secret signs the deliveries
+CREATE TABLE webhook (
+    id INTEGER PRIMARY KEY NOT NULL,
+    url TEXT NOT NULL,
+    secret TEXT NOT NULL,
+    events TEXT NOT NULL, -- comma separated event types the webhook gets
+    active INTEGER NOT NULL DEFAULT 1,
+    created_ts INTEGER NOT NULL
+);
+
+-- every event sent to a webhook, pending ones are retried until they run out of attempts
+CREATE TABLE webhook_delivery (
+    id INTEGER PRIMARY KEY NOT NULL,
+    webhook_id INTEGER NOT NULL REFERENCES webhook(id),
+    event TEXT NOT NULL,
+    payload TEXT NOT NULL,
+    status TEXT NOT NULL DEFAULT 'pending', -- pending, delivered or failed
+    attempts INTEGER NOT NULL DEFAULT 0,
+    next_attempt_ts INTEGER NOT NULL,
+    response_status INTEGER NOT NULL DEFAULT 0, -- http status of the last attempt, 0 without a response
+    error TEXT NOT NULL DEFAULT '',
+    created_ts INTEGER NOT NULL,
+    delivered_ts INTEGER NOT NULL DEFAULT 0
+);
+
+CREATE INDEX webhook_delivery_due ON webhook_delivery(status, next_attempt_ts);
+CREATE INDEX webhook_delivery_webhook_id ON webhook_delivery(webhook_id);